@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --config 未指定時にカレントディレクトリから読む設定ファイル
+const defaultConfigFile = ".cdk-destroy.yaml"
+
+// 設定ファイル (YAML / JSON) の値をフラグの既定値として反映する
+// キーはフラグ名と同じ (例: stack, cdk-app-path)。コマンドラインで指定したフラグが優先される
+// explicit が false (自動検出) の場合、ファイルが無ければ何もしない
+func applyConfigFile(fset *flag.FlagSet, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON は YAML のサブセットなので YAML として読む
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	explicitFlags := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var unknown []string
+	for _, key := range keys {
+		if key == "config" || fset.Lookup(key) == nil {
+			unknown = append(unknown, key)
+			continue
+		}
+		if explicitFlags[key] {
+			continue
+		}
+		if err := setFlagFromConfig(fset, key, values[key]); err != nil {
+			return fmt.Errorf("config file %s: invalid value for %q: %w", path, key, err)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("config file %s: unknown key(s): %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// 設定値をフラグに反映 (リストは要素ごとに Set する)
+func setFlagFromConfig(fset *flag.FlagSet, name string, value any) error {
+	if list, ok := value.([]any); ok {
+		for _, v := range list {
+			// stack は {name: ..., region: ...} でも書ける (--stack name@region と同じ)
+			if entry, ok := v.(map[string]any); ok && name == "stack" {
+				spec, err := stackSpecFromConfig(entry)
+				if err != nil {
+					return err
+				}
+				v = spec
+			}
+			if err := fset.Set(name, fmt.Sprint(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if value == nil {
+		return errors.New("value is empty")
+	}
+	return fset.Set(name, fmt.Sprint(value))
+}
+
+// 設定ファイルの stack の要素 {name, region} を "name@region" にする
+func stackSpecFromConfig(entry map[string]any) (string, error) {
+	for key := range entry {
+		if key != "name" && key != "region" {
+			return "", fmt.Errorf("unknown key %q in stack entry (want name, region)", key)
+		}
+	}
+	name, _ := entry["name"].(string)
+	if name == "" {
+		return "", errors.New("stack entry needs a name")
+	}
+	if region, _ := entry["region"].(string); region != "" {
+		return name + "@" + region, nil
+	}
+	return name, nil
+}
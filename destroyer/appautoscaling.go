@@ -0,0 +1,52 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	aas "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aastypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+)
+
+// Application Auto Scaling でのサービスのリソース ID
+func serviceResourceID(clusterName, svcName string) string {
+	return fmt.Sprintf("service/%s/%s", clusterName, svcName)
+}
+
+// サービスのスケーラブルターゲットを登録解除する (登録されていなければ何もしない)
+// スケーリングポリシーが残っていると DesiredCount=0 がすぐ戻され、サービスが止まらない
+func removeServiceAutoscaling(ctx context.Context, svcLog appLogger, aasClient appAutoscalingAPI, clusterName, svcName string, opts cleanupOptions) error {
+	resourceID := serviceResourceID(clusterName, svcName)
+	out, err := withRetry(ctx, opts.Retry, "DescribeScalableTargets", func() (*aas.DescribeScalableTargetsOutput, error) {
+		return aasClient.DescribeScalableTargets(ctx, &aas.DescribeScalableTargetsInput{
+			ServiceNamespace:  aastypes.ServiceNamespaceEcs,
+			ResourceIds:       []string{resourceID},
+			ScalableDimension: aastypes.ScalableDimensionECSServiceDesiredCount,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("DescribeScalableTargets error: %w", err)
+	}
+	if len(out.ScalableTargets) == 0 {
+		svcLog.Debugf("No scalable target registered")
+		return nil
+	}
+
+	svcLog.Infof("Deregistering scalable target (Application Auto Scaling)...")
+	_, err = withRetry(ctx, opts.Retry, "DeregisterScalableTarget", func() (*aas.DeregisterScalableTargetOutput, error) {
+		return aasClient.DeregisterScalableTarget(ctx, &aas.DeregisterScalableTargetInput{
+			ServiceNamespace:  aastypes.ServiceNamespaceEcs,
+			ResourceId:        &resourceID,
+			ScalableDimension: aastypes.ScalableDimensionECSServiceDesiredCount,
+		})
+	})
+	if err != nil {
+		var notFound *aastypes.ObjectNotFoundException
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("DeregisterScalableTarget error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// コンテナインスタンスの登録解除を確認する間隔
+const instancePollInterval = 15 * time.Second
+
+// クラスターのキャパシティプロバイダーが管理する Auto Scaling Group の名前を取得
+// FARGATE / FARGATE_SPOT のように ASG を持たないプロバイダーは除く
+func listClusterAutoScalingGroups(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) ([]string, error) {
+	clusters, err := withRetry(ctx, retry, "DescribeClusters", func() (*ecs.DescribeClustersOutput, error) {
+		return ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+			Clusters: []string{clusterName},
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeClusters error: %w", err)
+	}
+	var providerNames []string
+	for _, c := range clusters.Clusters {
+		providerNames = append(providerNames, c.CapacityProviders...)
+	}
+	if len(providerNames) == 0 {
+		return nil, nil
+	}
+
+	providers, err := withRetry(ctx, retry, "DescribeCapacityProviders", func() (*ecs.DescribeCapacityProvidersOutput, error) {
+		return ecsClient.DescribeCapacityProviders(ctx, &ecs.DescribeCapacityProvidersInput{
+			CapacityProviders: providerNames,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeCapacityProviders error: %w", err)
+	}
+	var asgNames []string
+	for _, p := range providers.CapacityProviders {
+		if p.AutoScalingGroupProvider == nil {
+			continue
+		}
+		// ARN (…:autoScalingGroupName/<name>) でも名前でも末尾が ASG 名になる
+		asgNames = append(asgNames, arnToName(aws.ToString(p.AutoScalingGroupProvider.AutoScalingGroupArn)))
+	}
+	return asgNames, nil
+}
+
+// クラスターの ASG を最小・希望キャパシティ 0 にし、コンテナインスタンスの登録解除を待つ
+// (dryRun 時は対象の表示のみ)。スケールダウンした ASG の数を返す
+func scaleDownClusterASGs(ctx context.Context, ecsClient ecsAPI, asgClient autoscalingAPI, clusterName string, opts cleanupOptions) (int, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	asgNames, err := listClusterAutoScalingGroups(ctx, ecsClient, clusterName, opts.Retry)
+	if err != nil {
+		return 0, err
+	}
+	if len(asgNames) == 0 {
+		clusterLog.Infof("No Auto Scaling Group capacity providers in cluster: %s", clusterName)
+		return 0, nil
+	}
+
+	if opts.DryRun {
+		for _, asgName := range asgNames {
+			clusterLog.With("asg", asgName).Infof("[DryRun] Would set min size and desired capacity to 0")
+		}
+		return len(asgNames), nil
+	}
+
+	var scaled int
+	var errs []error
+	for _, asgName := range asgNames {
+		asgLog := clusterLog.With("asg", asgName)
+		asgLog.Infof("Setting min size and desired capacity to 0...")
+		_, err := withRetry(ctx, opts.Retry, "UpdateAutoScalingGroup", func() (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+			return asgClient.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+				AutoScalingGroupName: aws.String(asgName),
+				MinSize:              aws.Int32(0),
+				DesiredCapacity:      aws.Int32(0),
+			})
+		})
+		if err != nil {
+			asgLog.Errorf("Failed to scale down: %v", err)
+			errs = append(errs, fmt.Errorf("asg(%s): %w", asgName, err))
+			continue
+		}
+		scaled++
+	}
+
+	// 1つもスケールダウンできなければインスタンスは減らないので待たない
+	if scaled > 0 {
+		clusterLog.Infof("Waiting for container instances to deregister...")
+		if err := waitForContainerInstancesDeregistered(ctx, ecsClient, clusterName, opts.InstanceWaitTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("waitForContainerInstancesDeregistered failed: %w", err))
+		}
+	}
+	return scaled, errors.Join(errs...)
+}
+
+// クラスターのコンテナインスタンスが0になるまで待機 (最大 maxWait)
+func waitForContainerInstancesDeregistered(ctx context.Context, ecsClient ecsAPI, clusterName string, maxWait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	ticker := time.NewTicker(instancePollInterval)
+	defer ticker.Stop()
+	for {
+		out, err := ecsClient.ListContainerInstances(ctx, &ecs.ListContainerInstancesInput{
+			Cluster: &clusterName,
+		})
+		switch {
+		case err == nil && len(out.ContainerInstanceArns) == 0:
+			return nil
+		case err == nil:
+			loggerFrom(ctx).With("cluster", clusterName).Debugf("%d container instance(s) still registered", len(out.ContainerInstanceArns))
+		case !isThrottlingError(err) && ctx.Err() == nil:
+			// スロットリングは次の確認で再試行する
+			return fmt.Errorf("ListContainerInstances error: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return fmt.Errorf("exceeded max wait time %s", maxWait)
+			}
+			return wrapCancelled(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package destroyer
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aas "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// このツールが使う AWS API だけを切り出したインターフェース
+// 実際には各サービスの *Client を渡すが、差し替えれば AWS なしで動かせる
+
+// ECSAPI は ECS の操作 (サービス削除・タスク停止・タスク定義の登録解除)。*ecs.Client が満たす
+// Drain にはこれを渡す
+type ECSAPI interface {
+	ListClusters(ctx context.Context, params *ecs.ListClustersInput, optFns ...func(*ecs.Options)) (*ecs.ListClustersOutput, error)
+	DescribeClusters(ctx context.Context, params *ecs.DescribeClustersInput, optFns ...func(*ecs.Options)) (*ecs.DescribeClustersOutput, error)
+	DescribeCapacityProviders(ctx context.Context, params *ecs.DescribeCapacityProvidersInput, optFns ...func(*ecs.Options)) (*ecs.DescribeCapacityProvidersOutput, error)
+	ListContainerInstances(ctx context.Context, params *ecs.ListContainerInstancesInput, optFns ...func(*ecs.Options)) (*ecs.ListContainerInstancesOutput, error)
+	ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error)
+	DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error)
+	UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error)
+	DeleteService(ctx context.Context, params *ecs.DeleteServiceInput, optFns ...func(*ecs.Options)) (*ecs.DeleteServiceOutput, error)
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error)
+	DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error)
+	StopTask(ctx context.Context, params *ecs.StopTaskInput, optFns ...func(*ecs.Options)) (*ecs.StopTaskOutput, error)
+	ListTaskDefinitions(ctx context.Context, params *ecs.ListTaskDefinitionsInput, optFns ...func(*ecs.Options)) (*ecs.ListTaskDefinitionsOutput, error)
+	DeregisterTaskDefinition(ctx context.Context, params *ecs.DeregisterTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DeregisterTaskDefinitionOutput, error)
+	DeleteCluster(ctx context.Context, params *ecs.DeleteClusterInput, optFns ...func(*ecs.Options)) (*ecs.DeleteClusterOutput, error)
+}
+
+// パッケージ内では他の API と同じ小文字の名前で使う
+type ecsAPI interface {
+	ECSAPI
+}
+
+// CloudFormation の操作 (スタック内リソースとテンプレートの参照、削除保護の解除、--use-cloudformation の削除)
+type cfnAPI interface {
+	DescribeStacks(ctx context.Context, params *cfn.DescribeStacksInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStacksOutput, error)
+	UpdateTerminationProtection(ctx context.Context, params *cfn.UpdateTerminationProtectionInput, optFns ...func(*cfn.Options)) (*cfn.UpdateTerminationProtectionOutput, error)
+	ListStackResources(ctx context.Context, params *cfn.ListStackResourcesInput, optFns ...func(*cfn.Options)) (*cfn.ListStackResourcesOutput, error)
+	DescribeStackResource(ctx context.Context, params *cfn.DescribeStackResourceInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackResourceOutput, error)
+	GetTemplate(ctx context.Context, params *cfn.GetTemplateInput, optFns ...func(*cfn.Options)) (*cfn.GetTemplateOutput, error)
+	DescribeStackEvents(ctx context.Context, params *cfn.DescribeStackEventsInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackEventsOutput, error)
+	DeleteStack(ctx context.Context, params *cfn.DeleteStackInput, optFns ...func(*cfn.Options)) (*cfn.DeleteStackOutput, error)
+}
+
+// S3 の操作 (バケットを空にする)
+type s3API interface {
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// ECR の操作 (リポジトリを空にする)
+type ecrAPI interface {
+	ListImages(ctx context.Context, params *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+	BatchDeleteImage(ctx context.Context, params *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+}
+
+// Auto Scaling の操作 (EC2 キャパシティのスケールダウン)
+type autoscalingAPI interface {
+	UpdateAutoScalingGroup(ctx context.Context, params *autoscaling.UpdateAutoScalingGroupInput, optFns ...func(*autoscaling.Options)) (*autoscaling.UpdateAutoScalingGroupOutput, error)
+}
+
+// Application Auto Scaling の操作 (サービスのスケーラブルターゲットの登録解除)
+type appAutoscalingAPI interface {
+	DescribeScalableTargets(ctx context.Context, params *aas.DescribeScalableTargetsInput, optFns ...func(*aas.Options)) (*aas.DescribeScalableTargetsOutput, error)
+	DeregisterScalableTarget(ctx context.Context, params *aas.DeregisterScalableTargetInput, optFns ...func(*aas.Options)) (*aas.DeregisterScalableTargetOutput, error)
+}
+
+// CloudWatch Logs の操作 (ロググループの削除)
+type logsAPI interface {
+	DeleteLogGroup(ctx context.Context, params *cloudwatchlogs.DeleteLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteLogGroupOutput, error)
+}
+
+// ELBv2 の操作 (ターゲットの登録解除)
+type elbv2API interface {
+	DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error)
+	DeregisterTargets(ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.DeregisterTargetsOutput, error)
+}
+
+// EventBridge の操作 (スケジュールされたタスクのルールの無効化)
+type eventsAPI interface {
+	ListRules(ctx context.Context, params *eventbridge.ListRulesInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListRulesOutput, error)
+	ListTargetsByRule(ctx context.Context, params *eventbridge.ListTargetsByRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListTargetsByRuleOutput, error)
+	DisableRule(ctx context.Context, params *eventbridge.DisableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DisableRuleOutput, error)
+	EnableRule(ctx context.Context, params *eventbridge.EnableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.EnableRuleOutput, error)
+}
+
+// EC2 の操作 (停止したタスクの ENI の削除)
+type ec2API interface {
+	ec2.DescribeNetworkInterfacesAPIClient
+	DeleteNetworkInterface(ctx context.Context, params *ec2.DeleteNetworkInterfaceInput, optFns ...func(*ec2.Options)) (*ec2.DeleteNetworkInterfaceOutput, error)
+}
+
+// IAM の操作 (--check-permissions のポリシーシミュレーション)
+type iamAPI interface {
+	iam.SimulatePrincipalPolicyAPIClient
+}
+
+// DynamoDB の操作 (削除保護の無効化)
+type dynamodbAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+// EFS の操作 (マウントターゲットの削除)
+type efsAPI interface {
+	efs.DescribeMountTargetsAPIClient
+	DeleteMountTarget(ctx context.Context, params *efs.DeleteMountTargetInput, optFns ...func(*efs.Options)) (*efs.DeleteMountTargetOutput, error)
+}
+
+// STS の操作 (認証情報の確認)
+type stsAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// SNS の操作 (--notify、--delete-topic-subscriptions)
+type snsAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+	sns.ListSubscriptionsByTopicAPIClient
+	Unsubscribe(ctx context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error)
+}
+
+// SQS の操作 (--purge-queues)
+type sqsAPI interface {
+	PurgeQueue(ctx context.Context, params *sqs.PurgeQueueInput, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error)
+}
+
+// run で使う各サービスのクライアント
+type awsClients struct {
+	CFN    cfnAPI
+	ECS    ecsAPI
+	ASG    autoscalingAPI
+	AAS    appAutoscalingAPI
+	S3     s3API
+	ECR    ecrAPI
+	Logs   logsAPI
+	STS    stsAPI
+	ELB    elbv2API
+	Events eventsAPI
+	EC2    ec2API
+	IAM    iamAPI
+	DDB    dynamodbAPI
+	EFS    efsAPI
+	SNS    snsAPI
+	SQS    sqsAPI
+}
+
+func newAWSClients(cfg aws.Config) awsClients {
+	return awsClients{
+		CFN:    cfn.NewFromConfig(cfg),
+		ECS:    ecs.NewFromConfig(cfg),
+		ASG:    autoscaling.NewFromConfig(cfg),
+		AAS:    aas.NewFromConfig(cfg),
+		S3:     newS3Client(cfg),
+		ECR:    ecr.NewFromConfig(cfg),
+		Logs:   cloudwatchlogs.NewFromConfig(cfg),
+		STS:    sts.NewFromConfig(cfg),
+		ELB:    elbv2.NewFromConfig(cfg),
+		Events: eventbridge.NewFromConfig(cfg),
+		EC2:    ec2.NewFromConfig(cfg),
+		IAM:    iam.NewFromConfig(cfg),
+		DDB:    dynamodb.NewFromConfig(cfg),
+		EFS:    efs.NewFromConfig(cfg),
+		SNS:    sns.NewFromConfig(cfg),
+		SQS:    sqs.NewFromConfig(cfg),
+	}
+}
+
+var (
+	_ ecsAPI            = (*ecs.Client)(nil)
+	_ cfnAPI            = (*cfn.Client)(nil)
+	_ s3API             = (*s3.Client)(nil)
+	_ ecrAPI            = (*ecr.Client)(nil)
+	_ autoscalingAPI    = (*autoscaling.Client)(nil)
+	_ appAutoscalingAPI = (*aas.Client)(nil)
+	_ logsAPI           = (*cloudwatchlogs.Client)(nil)
+	_ stsAPI            = (*sts.Client)(nil)
+	_ elbv2API          = (*elbv2.Client)(nil)
+	_ eventsAPI         = (*eventbridge.Client)(nil)
+	_ ec2API            = (*ec2.Client)(nil)
+	_ iamAPI            = (*iam.Client)(nil)
+	_ dynamodbAPI       = (*dynamodb.Client)(nil)
+	_ efsAPI            = (*efs.Client)(nil)
+	_ snsAPI            = (*sns.Client)(nil)
+	_ sqsAPI            = (*sqs.Client)(nil)
+)
@@ -0,0 +1,178 @@
+package destroyer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+)
+
+// AssumeRole の設定 (RoleArn が空なら AssumeRole しない)
+type assumeRoleOptions struct {
+	RoleArn     string
+	ExternalID  string
+	SessionName string
+}
+
+// AWS Config ロードの設定
+type awsConfigOptions struct {
+	Profile     string
+	Region      string // 空なら profile / 環境変数の region
+	EndpointURL string // LocalStack などのカスタムエンドポイント (空なら既定のエンドポイント)
+	Role        assumeRoleOptions
+}
+
+// region がどこにも指定されていないときに使うリージョン
+const fallbackRegion = "us-east-1"
+
+// AWS Config ロード (profile / region / endpoint / assume role を考慮)
+func loadAWSConfig(ctx context.Context, o awsConfigOptions) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{}
+	if o.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(o.Profile))
+	}
+	if o.Region != "" {
+		opts = append(opts, config.WithRegion(o.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Region == "" {
+		// --region も profile・環境変数の region も無いときだけ既定のリージョンを使う
+		logger.Warnf("No region from --region, the profile (%s) or the environment, using %s", effectiveProfile(o.Profile), fallbackRegion)
+		cfg.Region = fallbackRegion
+	}
+	cfg.APIOptions = append(cfg.APIOptions, addRequestIDDebugLog)
+	if o.EndpointURL != "" {
+		// 全サービスのクライアントがこのエンドポイントを使う
+		cfg.BaseEndpoint = aws.String(o.EndpointURL)
+	}
+	role := o.Role
+	if role.RoleArn == "" {
+		return cfg, nil
+	}
+
+	// profile の認証情報で AssumeRole し、以降のクライアントはその認証情報を使う
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = role.SessionName
+		if role.ExternalID != "" {
+			o.ExternalID = aws.String(role.ExternalID)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return cfg, nil
+}
+
+// AWS Config をロードし、認証情報を確認する
+// SSO のトークンが期限切れなら、ssoLogin が true のときだけ aws sso login を実行してやり直す
+func connectAWS(ctx context.Context, o awsConfigOptions, ssoLogin bool, retry retryPolicy) (awsClients, awsTarget, error) {
+	for loggedIn := false; ; loggedIn = true {
+		cfg, err := loadAWSConfig(ctx, o)
+		if err != nil {
+			return awsClients{}, awsTarget{}, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		clients := newAWSClients(cfg)
+		target, err := verifyCredentials(ctx, clients.STS, cfg, o.Profile, retry)
+		if err == nil {
+			return clients, target, nil
+		}
+		if !isSSOTokenError(err) || loggedIn {
+			return awsClients{}, awsTarget{}, err
+		}
+		profile := effectiveProfile(o.Profile)
+		if !ssoLogin {
+			return awsClients{}, awsTarget{}, fmt.Errorf("SSO session for profile %s has expired. Run `aws sso login --profile %s` or pass --sso-login: %w", profile, profile, err)
+		}
+		if err := runSSOLogin(ctx, profile); err != nil {
+			return awsClients{}, awsTarget{}, err
+		}
+	}
+}
+
+// 操作対象のアカウントと region
+type awsTarget struct {
+	Account   string
+	Region    string
+	CallerArn string
+}
+
+// AWS アカウント ID (12桁の数字)
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// 認証情報が有効か GetCallerIdentity で確認し、操作対象のアカウントを返す
+// 期限切れなどは最初の削除操作より前に分かりやすいエラーにする
+func verifyCredentials(ctx context.Context, stsClient stsAPI, cfg aws.Config, profile string, retry retryPolicy) (awsTarget, error) {
+	out, err := withRetry(ctx, retry, "GetCallerIdentity", func() (*sts.GetCallerIdentityOutput, error) {
+		return stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	})
+	if isInterrupted(err) {
+		return awsTarget{}, err
+	}
+	if err != nil {
+		return awsTarget{}, fmt.Errorf("AWS credentials are invalid or expired (profile: %s). Check --profile or refresh your credentials: %w", effectiveProfile(profile), err)
+	}
+	logger.Infof("AWS account: %s, caller: %s, region: %s (profile: %s)", aws.ToString(out.Account), aws.ToString(out.Arn), cfg.Region, effectiveProfile(profile))
+	return awsTarget{Account: aws.ToString(out.Account), Region: cfg.Region, CallerArn: aws.ToString(out.Arn)}, nil
+}
+
+// 実際に使われる profile 名 (--profile → AWS_PROFILE → default の順)
+func effectiveProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if env := os.Getenv("AWS_PROFILE"); env != "" {
+		return env
+	}
+	return "default"
+}
+
+// 全 AWS API 呼び出しの結果とリクエスト ID を debug ログに出すミドルウェア
+func addRequestIDDebugLog(stack *smithymiddleware.Stack) error {
+	return stack.Initialize.Add(smithymiddleware.InitializeMiddlewareFunc("DebugRequestIDLog",
+		func(ctx context.Context, in smithymiddleware.InitializeInput, next smithymiddleware.InitializeHandler) (smithymiddleware.InitializeOutput, smithymiddleware.Metadata, error) {
+			out, md, err := next.HandleInitialize(ctx, in)
+			requestID, _ := awsmiddleware.GetRequestIDMetadata(md)
+			op := awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx)
+			if err != nil {
+				logger.Debugf("%s failed (request id: %s): %v", op, requestID, err)
+			} else {
+				logger.Debugf("%s succeeded (request id: %s)", op, requestID)
+			}
+			return out, md, err
+		}), smithymiddleware.After)
+}
+
+// IAM ロール ARN の形式チェック (arn:<partition>:iam::<account>:role/<name>)
+func validateRoleArn(roleArn string) error {
+	parsed, err := arn.Parse(roleArn)
+	if err != nil {
+		return err
+	}
+	if parsed.Service != "iam" || parsed.AccountID == "" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return fmt.Errorf("not an IAM role ARN: %s", roleArn)
+	}
+	return nil
+}
+
+// --endpoint-url の形式チェック (http / https の URL)
+func validateEndpointURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an http(s) URL such as http://localhost:4566: %s", endpoint)
+	}
+	return nil
+}
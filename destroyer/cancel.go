@@ -0,0 +1,32 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// シグナル (ctx のキャンセル) による中断を表すエラー
+var errCancelledBySignal = errors.New("cancelled by signal")
+
+// --max-total-timeout を超えたことによる中断を表すエラー (run の ctx の Cause)
+var errTotalTimeout = errors.New("exceeded --max-total-timeout")
+
+// 中断された場合は分かりやすいエラーに置き換える
+func wrapCancelled(ctx context.Context, err error) error {
+	if err == nil || isInterrupted(err) {
+		return err
+	}
+	switch {
+	case errors.Is(context.Cause(ctx), errTotalTimeout):
+		return fmt.Errorf("%w: %w", errTotalTimeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", errCancelledBySignal, err)
+	}
+	return err
+}
+
+// シグナルまたは --max-total-timeout で中断されたか (残りの処理は行わない)
+func isInterrupted(err error) bool {
+	return errors.Is(err, errCancelledBySignal) || errors.Is(err, errTotalTimeout)
+}
@@ -0,0 +1,29 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWrapCancelledKeepsCause(t *testing.T) {
+	apiErr := errors.New("DescribeServices error")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errTotalTimeout)
+	err := wrapCancelled(ctx, apiErr)
+	if !errors.Is(err, errTotalTimeout) || !errors.Is(err, apiErr) {
+		t.Errorf("total timeout: got %v, want both errTotalTimeout and the original error", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	err = wrapCancelled(ctx, apiErr)
+	if !errors.Is(err, errCancelledBySignal) || !errors.Is(err, apiErr) {
+		t.Errorf("signal: got %v, want both errCancelledBySignal and the original error", err)
+	}
+
+	if err := wrapCancelled(context.Background(), apiErr); err != apiErr {
+		t.Errorf("not cancelled: got %v, want the original error", err)
+	}
+}
@@ -0,0 +1,316 @@
+package destroyer
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 中断時に cdk の終了を待つ時間 (過ぎたら強制終了)
+const cdkCancelWaitDelay = 30 * time.Second
+
+// cdk destroy の再実行までの待ち時間 (試行ごとに倍、最大 cdkRetryMaxDelay)
+const (
+	cdkRetryBaseDelay = 30 * time.Second
+	cdkRetryMaxDelay  = 5 * time.Minute
+)
+
+// cdk destroy の実行設定
+type cdkOptions struct {
+	Bin     string // "cdk" / "npx cdk" / パスなど (空白区切りで引数も指定可)
+	Profile string
+	Region  string // 空なら profile / 環境変数の region
+	AppRoot string
+	Dir     string // cdk を実行するディレクトリ (空なら AppRoot)
+	AppPath string
+	AppCmd  string // --app にそのまま渡すコマンド (空なら AppPath の拡張子から決める)
+	Timeout time.Duration
+	Stacks  []string // destroy するスタック (空なら --all)
+	Args    []string // --cdk-arg (固定の引数の後ろに追加する)
+	DryRun  bool
+}
+
+// ツールが自分で指定する cdk の引数 (--cdk-arg で重ねて指定すると動作が変わるので受け付けない)
+var reservedCdkArgs = map[string]string{
+	"--all":     "all stacks are always destroyed",
+	"--force":   "the tool confirms by itself (--yes)",
+	"-f":        "the tool confirms by itself (--yes)",
+	"--profile": "use --profile",
+	"--app":     "use --cdk-app-path / --cdk-app-command",
+	"-a":        "use --cdk-app-path / --cdk-app-command",
+}
+
+// --cdk-arg がツールの指定する引数と衝突しないか確認する
+func validateCdkArgs(args []string) error {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if reason, ok := reservedCdkArgs[name]; ok {
+			return fmt.Errorf("%s is set by the tool (%s)", name, reason)
+		}
+		if arg == "destroy" {
+			return errors.New("destroy is set by the tool")
+		}
+	}
+	return nil
+}
+
+// ログに出すため、空白などを含む引数だけ引用符で囲む
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$`\\") {
+			quoted[i] = strconv.Quote(arg)
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runCdkDestroy の結果から cdk の終了コードを求める (dry-run や起動できなかった場合は nil)
+func cdkExitCode(err error, dryRun bool) *int {
+	var exitErr *exec.ExitError
+	switch {
+	case dryRun:
+		return nil
+	case err == nil:
+		code := 0
+		return &code
+	case errors.As(err, &exitErr):
+		code := exitErr.ExitCode()
+		return &code
+	}
+	return nil
+}
+
+// cdk destroy を最大 attempts 回実行する。cdk が非ゼロで終了したときだけ待ってから再実行する
+// (タイムアウト・中断・起動失敗は再実行しない)。返す出力は最後の試行のもの
+func runCdkDestroyWithRetries(ctx context.Context, opts cdkOptions, attempts int) ([]byte, error) {
+	delay := cdkRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		if attempts > 1 && !opts.DryRun {
+			logger.Infof("cdk destroy attempt %d/%d", attempt, attempts)
+		}
+		output, err := runCdkDestroyWithOutput(ctx, opts)
+		var exitErr *exec.ExitError
+		if err == nil || attempt >= attempts || isInterrupted(err) || !errors.As(err, &exitErr) || exitErr.ExitCode() <= 0 {
+			return output, err
+		}
+		logger.Warnf("cdk destroy failed (attempt %d/%d), retrying in %s: %v", attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return output, wrapCancelled(ctx, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, cdkRetryMaxDelay)
+	}
+}
+
+// コマンド実行 (dryRun 時はコマンドの表示のみ)
+func runCdkDestroy(ctx context.Context, opts cdkOptions) error {
+	_, err := runCdkDestroyWithOutput(ctx, opts)
+	return err
+}
+
+// runCdkDestroy と同じく実行し、cdk の出力 (stdout / stderr を出力順に結合したもの) も返す
+// 出力はそのままコンソールにも流すので、長い実行でも進捗が見える
+// ctx がキャンセルされたら cdk に SIGINT を送り、後片付けの時間を与える
+// opts.Timeout を過ぎたらプロセスグループごと強制終了する
+func runCdkDestroyWithOutput(ctx context.Context, opts cdkOptions) ([]byte, error) {
+	command, err := resolveCdkCommand(opts.Bin, opts.AppRoot)
+	if err != nil {
+		return nil, err
+	}
+	c := buildCdkCommand(opts, command)
+	if opts.DryRun {
+		logger.Infof("[DryRun] Would execute (in %s): %s", c.Dir, c.CommandLine)
+		return nil, nil
+	}
+	logger.Infof("Executing (in %s): %s", c.Dir, c.CommandLine)
+
+	cdkCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(cdkCtx, c.Path, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	var output syncBuffer
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		if ctx.Err() == nil {
+			// タイムアウト: 後片付けを待たずに止める
+			return signalProcessGroup(cmd, os.Kill)
+		}
+		return signalProcessGroup(cmd, os.Interrupt)
+	}
+	cmd.WaitDelay = cdkCancelWaitDelay
+	err = cmd.Run()
+	if err != nil && ctx.Err() == nil && errors.Is(cdkCtx.Err(), context.DeadlineExceeded) {
+		return output.Bytes(), fmt.Errorf("cdk destroy timed out after %s (--cdk-timeout): %w", opts.Timeout, err)
+	}
+	return output.Bytes(), wrapCancelled(ctx, err)
+}
+
+// 実行する cdk destroy のコマンド
+type cdkCommand struct {
+	Path        string   // 実行ファイル (--cdk-bin の最初の要素)
+	Args        []string // Path に渡す引数 (--cdk-bin の残り + cdk の引数)
+	Dir         string
+	Env         []string // 環境変数に追加する "KEY=value"
+	CommandLine string   // ログに出すコマンドライン
+}
+
+// opts と解決済みの --cdk-bin (command) から cdk destroy のコマンドを組み立てる (実行はしない)
+func buildCdkCommand(opts cdkOptions, command []string) cdkCommand {
+	cdkArgs := []string{"destroy", "--all", "--force"}
+	if len(opts.Stacks) > 0 {
+		cdkArgs = append([]string{"destroy"}, append(slices.Clone(opts.Stacks), "--force")...)
+	}
+	if opts.Profile != "" {
+		cdkArgs = append(cdkArgs, "--profile", opts.Profile)
+	}
+
+	// --app 引数 (エントリファイルは cdk を実行するディレクトリからの相対パスにする)
+	dir := cmp.Or(opts.Dir, opts.AppRoot)
+	cdkArgs = append(cdkArgs, "--app", cdkAppCommand(opts.AppCmd, relativeAppPath(opts.AppRoot, dir, opts.AppPath)))
+	cdkArgs = append(cdkArgs, opts.Args...)
+
+	c := cdkCommand{
+		Path:        command[0],
+		Args:        append(slices.Clone(command[1:]), cdkArgs...),
+		Dir:         dir,
+		CommandLine: opts.Bin + " " + quoteArgs(cdkArgs),
+	}
+	if opts.Region != "" {
+		// cdk と CDK アプリ (env 未指定のスタック) の両方に region を伝える
+		c.Env = []string{"AWS_REGION=" + opts.Region, "AWS_DEFAULT_REGION=" + opts.Region}
+	}
+	return c
+}
+
+// stdout / stderr の両方から書き込まれるバッファ
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// エントリファイルのパスを cdk を実行するディレクトリ (dir) からの相対パスにする
+// 相対パスは CDK プロジェクトのルート (appRoot) からのパスとして扱う。dir の外なら絶対パスのまま
+func relativeAppPath(appRoot, dir, appPath string) string {
+	if appPath == "" {
+		return ""
+	}
+	abs := appPath
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(appRoot, abs)
+	}
+	abs, err := filepath.Abs(abs)
+	if err != nil {
+		return appPath
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
+
+// --app に渡すコマンドを決める
+// appCmd が指定されていればそのまま使い、なければ CDK アプリのエントリファイルの拡張子から推測する
+func cdkAppCommand(appCmd, appPath string) string {
+	if appCmd != "" {
+		return appCmd
+	}
+	switch strings.ToLower(filepath.Ext(appPath)) {
+	case ".py":
+		return fmt.Sprintf("python3 %s", appPath)
+	case ".js":
+		return fmt.Sprintf("node %s", appPath)
+	case ".java":
+		// Java は cdk init と同じく Maven 経由で実行する (エントリファイルは pom.xml から決まる)
+		return "mvn -e -q compile exec:java"
+	case ".go":
+		return fmt.Sprintf("go mod download && go run %s", appPath)
+	default:
+		return fmt.Sprintf("npx ts-node %s", appPath)
+	}
+}
+
+// CDK プロジェクトのルートがディレクトリとして存在するか確認する
+// cdk.json が無いと cdk が分かりにくいエラーになるので、警告する (strict ならエラー)
+func validateCdkAppRoot(appRoot string, strict bool) error {
+	info, err := os.Stat(appRoot)
+	if err != nil {
+		return fmt.Errorf("%s does not exist: %w", appRoot, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", appRoot)
+	}
+	if _, err := os.Stat(filepath.Join(appRoot, "cdk.json")); err != nil {
+		if strict {
+			return fmt.Errorf("no cdk.json in %s (is this the CDK project root?)", appRoot)
+		}
+		logger.Warnf("No cdk.json in --cdk-app-root %s; is this the CDK project root? (--strict makes this an error)", appRoot)
+	}
+	return nil
+}
+
+// --cdk-bin を空白で分割し、実行ファイルの存在を確認する (先頭要素は解決済みのパス)
+func resolveCdkCommand(bin, appRoot string) ([]string, error) {
+	fields := strings.Fields(bin)
+	if len(fields) == 0 {
+		return nil, errors.New("cdk command is empty")
+	}
+
+	program := fields[0]
+	if strings.ContainsRune(program, filepath.Separator) || strings.Contains(program, "/") {
+		// パス指定の場合は CDK プロジェクトのルートからの相対パスとして扱う
+		if !filepath.IsAbs(program) {
+			program = filepath.Join(appRoot, program)
+		}
+		info, err := os.Stat(program)
+		if err != nil {
+			return nil, fmt.Errorf("%s not found: %w", fields[0], err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", fields[0])
+		}
+	} else {
+		resolved, err := exec.LookPath(program)
+		if err != nil {
+			return nil, fmt.Errorf("%q was not found in PATH; install the AWS CDK CLI or pass --cdk-bin (e.g. \"npx cdk\"): %w", program, err)
+		}
+		program = resolved
+	}
+	return append([]string{program}, fields[1:]...), nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package destroyer
+
+import (
+	"os"
+	"os/exec"
+)
+
+// プロセスグループが無い環境では何もしない
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// プロセスグループが無い環境では cdk 本体にだけシグナルを送る
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	if sig == os.Kill {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,192 @@
+package destroyer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCdkAppCommand(t *testing.T) {
+	tests := []struct {
+		appCmd, appPath string
+		want            string
+	}{
+		{"", "bin/app.ts", "npx ts-node bin/app.ts"},
+		{"", "app.py", "python3 app.py"},
+		{"", "bin/app.js", "node bin/app.js"},
+		{"", "src/main/java/App.java", "mvn -e -q compile exec:java"},
+		{"", "app.go", "go mod download && go run app.go"},
+		{"", "APP.PY", "python3 APP.PY"},
+		{"poetry run python app.py", "app.py", "poetry run python app.py"},
+	}
+	for _, tt := range tests {
+		if got := cdkAppCommand(tt.appCmd, tt.appPath); got != tt.want {
+			t.Errorf("cdkAppCommand(%q, %q) = %q, want %q", tt.appCmd, tt.appPath, got, tt.want)
+		}
+	}
+}
+
+func TestResolveCdkCommand(t *testing.T) {
+	root := t.TempDir()
+	bin := filepath.Join(root, "node_modules", ".bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "cdk"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := resolveCdkCommand("node_modules/.bin/cdk --verbose", root)
+	if err != nil {
+		t.Fatalf("relative path: %v", err)
+	}
+	if want := []string{filepath.Join(bin, "cdk"), "--verbose"}; !slices.Equal(got, want) {
+		t.Errorf("relative path = %v, want %v", got, want)
+	}
+
+	t.Setenv("PATH", bin)
+	got, err = resolveCdkCommand("cdk", root)
+	if err != nil {
+		t.Fatalf("PATH lookup: %v", err)
+	}
+	if want := []string{filepath.Join(bin, "cdk")}; !slices.Equal(got, want) {
+		t.Errorf("PATH lookup = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"", "  ", "./missing/cdk", "node_modules", "no-such-cdk"} {
+		if _, err := resolveCdkCommand(bad, root); err == nil {
+			t.Errorf("resolveCdkCommand(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestValidateCdkAppRoot(t *testing.T) {
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "cdk.json"), []byte(`{"app": "npx ts-node bin/app.ts"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	noCdkJSON := t.TempDir()
+	file := filepath.Join(project, "cdk.json")
+	missing := filepath.Join(project, "missing")
+
+	tests := []struct {
+		name    string
+		appRoot string
+		strict  bool
+		wantErr string // 空なら成功
+	}{
+		{"project root", project, true, ""},
+		{"missing directory", missing, false, "does not exist"},
+		{"file", file, false, "is not a directory"},
+		{"no cdk.json", noCdkJSON, false, ""},
+		{"no cdk.json strict", noCdkJSON, true, "no cdk.json"},
+	}
+	for _, tt := range tests {
+		err := validateCdkAppRoot(tt.appRoot, tt.strict)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRelativeAppPath(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name         string
+		dir, appPath string
+		want         string
+	}{
+		{"no app path", root, "", ""},
+		{"relative to the root", root, "bin/app.ts", filepath.Join("bin", "app.ts")},
+		{"run in a subdirectory", filepath.Join(root, "infra"), "infra/bin/app.ts", filepath.Join("bin", "app.ts")},
+		{"outside the run directory", filepath.Join(root, "infra"), "bin/app.ts", filepath.Join(root, "bin", "app.ts")},
+		{"absolute", root, filepath.Join(root, "bin", "app.ts"), filepath.Join("bin", "app.ts")},
+	}
+	for _, tt := range tests {
+		if got := relativeAppPath(root, tt.dir, tt.appPath); got != tt.want {
+			t.Errorf("%s: relativeAppPath(%q, %q) = %q, want %q", tt.name, tt.dir, tt.appPath, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCdkCommand(t *testing.T) {
+	root := t.TempDir()
+	npx := []string{"/usr/bin/npx", "cdk"}
+	tests := []struct {
+		name     string
+		opts     cdkOptions
+		command  []string
+		wantArgs []string
+		wantDir  string
+		wantEnv  []string
+	}{
+		{
+			name:     "all stacks",
+			opts:     cdkOptions{Bin: "npx cdk", AppRoot: root, AppPath: "bin/app.ts"},
+			command:  npx,
+			wantArgs: []string{"cdk", "destroy", "--all", "--force", "--app", "npx ts-node " + filepath.Join("bin", "app.ts")},
+			wantDir:  root,
+		},
+		{
+			name:     "stacks, profile and region",
+			opts:     cdkOptions{Bin: "cdk", AppRoot: root, AppPath: "app.py", Profile: "dev", Region: "eu-west-1", Stacks: []string{"StackA", "StackB"}},
+			command:  []string{"/usr/local/bin/cdk"},
+			wantArgs: []string{"destroy", "StackA", "StackB", "--force", "--profile", "dev", "--app", "python3 app.py"},
+			wantDir:  root,
+			wantEnv:  []string{"AWS_REGION=eu-west-1", "AWS_DEFAULT_REGION=eu-west-1"},
+		},
+		{
+			name:     "app command and extra args",
+			opts:     cdkOptions{Bin: "npx cdk", AppRoot: root, AppPath: "bin/app.ts", AppCmd: "npx tsx bin/app.ts", Args: []string{"--verbose", "--context", "env=dev"}},
+			command:  npx,
+			wantArgs: []string{"cdk", "destroy", "--all", "--force", "--app", "npx tsx bin/app.ts", "--verbose", "--context", "env=dev"},
+			wantDir:  root,
+		},
+		{
+			name:     "run in a subdirectory",
+			opts:     cdkOptions{Bin: "npx cdk", AppRoot: root, Dir: filepath.Join(root, "infra"), AppPath: "infra/bin/app.js"},
+			command:  npx,
+			wantArgs: []string{"cdk", "destroy", "--all", "--force", "--app", "node " + filepath.Join("bin", "app.js")},
+			wantDir:  filepath.Join(root, "infra"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := buildCdkCommand(tt.opts, tt.command)
+			if c.Path != tt.command[0] {
+				t.Errorf("Path = %q, want %q", c.Path, tt.command[0])
+			}
+			if !slices.Equal(c.Args, tt.wantArgs) {
+				t.Errorf("Args = %q, want %q", c.Args, tt.wantArgs)
+			}
+			if c.Dir != tt.wantDir {
+				t.Errorf("Dir = %q, want %q", c.Dir, tt.wantDir)
+			}
+			if !slices.Equal(c.Env, tt.wantEnv) {
+				t.Errorf("Env = %q, want %q", c.Env, tt.wantEnv)
+			}
+			if !strings.HasPrefix(c.CommandLine, tt.opts.Bin+" destroy ") {
+				t.Errorf("CommandLine = %q, want it to start with %q", c.CommandLine, tt.opts.Bin+" destroy")
+			}
+		})
+	}
+}
+
+func TestCdkGroupOptionsSingleRegionStacks(t *testing.T) {
+	root := t.TempDir()
+	cfg := Config{CdkBin: "cdk", CdkAppRoot: root, CdkAppPath: "app.py"}
+	g := regionGroup{Stacks: []string{"StackA", "arn:aws:cloudformation:us-east-1:123456789012:stack/StackB/0f1e2d3c"}}
+	c := buildCdkCommand(cdkGroupOptions(cfg, g, "us-east-1"), []string{"/usr/local/bin/cdk"})
+	want := []string{"destroy", "StackA", "StackB", "--force", "--app", "python3 app.py"}
+	if !slices.Equal(c.Args, want) {
+		t.Errorf("Args = %q, want %q", c.Args, want)
+	}
+	if !slices.Equal(c.Env, []string{"AWS_REGION=us-east-1", "AWS_DEFAULT_REGION=us-east-1"}) {
+		t.Errorf("Env = %q, want the connected region", c.Env)
+	}
+}
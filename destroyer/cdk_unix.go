@@ -0,0 +1,23 @@
+//go:build unix
+
+package destroyer
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// cdk を別のプロセスグループで起動する (node などの子プロセスもまとめて止められるように)
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// cdk のプロセスグループ全体にシグナルを送る
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-cmd.Process.Pid, s)
+}
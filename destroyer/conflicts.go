@@ -0,0 +1,103 @@
+package destroyer
+
+import "strings"
+
+// 同時に指定できないフラグの組み合わせ
+// どちらかが黙って優先されると意図と違う動作になるので、実行前にまとめて確かめる
+type flagConflict struct {
+	A, B   string
+	Reason string // 補足 (無ければ空)
+}
+
+var flagConflicts = []flagConflict{
+	{"--verbose", "--quiet", ""},
+	{"--cleanup-only", "--destroy-only", ""},
+	{"--tasks-only", "--destroy-only", ""},
+	{"--skip-ecs", "--cleanup-only", ""},
+	{"--skip-ecs", "--tasks-only", ""},
+	{"--skip-ecs", "--retain-cluster", ""},
+	{"--skip-ecs", "--delete-cluster", ""},
+	{"--skip-ecs", "--inspect", "--inspect は何も変更しません"},
+	{"--plan-out", "--plan-in", ""},
+	{"--plan-out", "--cluster", ""},
+	{"--plan-in", "--cluster", ""},
+	{"--plan-in", "--stack", "スタックは計画ファイルから読みます"},
+	{"--retain-cluster", "--destroy-only", ""},
+	{"--retain-cluster", "--tasks-only", "--tasks-only ではサービスを削除しません"},
+	{"--retain-cluster", "--scale-down-asg", "残すクラスターの他のサービスまで止めてしまいます"},
+	{"--retain-cluster", "--disable-scheduled-tasks", "残すクラスターの他のサービスまで止めてしまいます"},
+	{"--delete-cluster", "--destroy-only", ""},
+	{"--delete-cluster", "--tasks-only", ""},
+	{"--delete-cluster", "--retain-cluster", ""},
+	{"--cluster-logical-id", "--cluster", "--cluster ではスタックからクラスターを探しません"},
+	{"--use-cloudformation", "--cdk-arg", ""},
+	{"--inspect", "--check-permissions", ""},
+	{"--inspect", "--plan-out", ""},
+	{"--inspect", "--plan-in", ""},
+	{"--inspect", "--cleanup-only", "--inspect は何も変更しません"},
+	{"--inspect", "--destroy-only", "--inspect は何も変更しません"},
+	{"--inspect", "--tasks-only", "--inspect は何も変更しません"},
+	{"--inspect", "--use-cloudformation", "--inspect は何も変更しません"},
+	{"--parallel-stacks", "--plan-out", ""},
+	{"--cross-account-bucket", "--plan-out", "計画ファイルには別アカウントのリソースを記録しません"},
+	{"--cross-account-bucket", "--plan-in", "計画ファイルには別アカウントのリソースを記録しません"},
+	{"--cross-account-repo", "--plan-out", "計画ファイルには別アカウントのリソースを記録しません"},
+	{"--cross-account-repo", "--plan-in", "計画ファイルには別アカウントのリソースを記録しません"},
+}
+
+// flagConflicts に出てくるフラグが指定されているか
+func conflictingFlagsSet(cfg Config) map[string]bool {
+	return map[string]bool{
+		"--verbose":                 cfg.Verbose,
+		"--quiet":                   cfg.Quiet,
+		"--cleanup-only":            cfg.CleanupOnly,
+		"--destroy-only":            cfg.DestroyOnly,
+		"--skip-ecs":                cfg.SkipECS,
+		"--tasks-only":              cfg.TasksOnly,
+		"--plan-out":                cfg.PlanOut != "",
+		"--plan-in":                 cfg.PlanIn != "",
+		"--cluster":                 len(cfg.Clusters) > 0,
+		"--stack":                   len(cfg.Stacks) > 0,
+		"--cluster-logical-id":      len(cfg.ClusterLogicalIDs) > 0,
+		"--retain-cluster":          cfg.RetainCluster,
+		"--delete-cluster":          cfg.DeleteCluster,
+		"--scale-down-asg":          cfg.ScaleDownASG,
+		"--disable-scheduled-tasks": cfg.DisableScheduledTasks,
+		"--use-cloudformation":      cfg.UseCloudFormation,
+		"--cdk-arg":                 len(cfg.CdkArgs) > 0,
+		"--inspect":                 cfg.Inspect,
+		"--check-permissions":       cfg.CheckPermissions,
+		"--parallel-stacks":         cfg.ParallelStacks > 1,
+		"--cross-account-bucket":    len(cfg.CrossAccountBuckets) > 0,
+		"--cross-account-repo":      len(cfg.CrossAccountRepos) > 0,
+	}
+}
+
+// 同時に指定できないフラグがあれば、見つかった組み合わせをすべて挙げた ExitInvalidFlags のエラーを返す
+func checkFlagConflicts(cfg Config) error {
+	set := conflictingFlagsSet(cfg)
+	var found []flagConflict
+	for _, c := range flagConflicts {
+		if set[c.A] && set[c.B] {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil
+	case 1:
+		c := found[0]
+		if c.Reason != "" {
+			return exitErrorf(ExitInvalidFlags, "Error: %s と %s は同時に指定できません。(%s)", c.A, c.B, c.Reason)
+		}
+		return exitErrorf(ExitInvalidFlags, "Error: %s と %s は同時に指定できません。", c.A, c.B)
+	}
+	lines := make([]string, len(found))
+	for i, c := range found {
+		lines[i] = "  " + c.A + " と " + c.B
+		if c.Reason != "" {
+			lines[i] += " (" + c.Reason + ")"
+		}
+	}
+	return exitErrorf(ExitInvalidFlags, "Error: 同時に指定できないフラグがあります:\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,63 @@
+package destroyer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckFlagConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  func(*Config)
+		want []string // エラーに含まれる文字列 (空なら成功)
+	}{
+		{"no conflicts", func(c *Config) { c.Verbose = true; c.SkipECS = true }, nil},
+		{"verbose and quiet", func(c *Config) { c.Verbose = true; c.Quiet = true }, []string{"--verbose と --quiet は同時に指定できません。"}},
+		{"with reason", func(c *Config) { c.SkipECS = true; c.Inspect = true }, []string{"--skip-ecs と --inspect", "(--inspect は何も変更しません)"}},
+		{"plan in and stack", func(c *Config) { c.PlanIn = "plan.json"; c.Stacks = []string{"StackA"} }, []string{"--plan-in と --stack"}},
+		{"retain and delete cluster", func(c *Config) { c.RetainCluster = true; c.DeleteCluster = true }, []string{"--delete-cluster と --retain-cluster"}},
+		{"single parallel stack is not parallel", func(c *Config) { c.ParallelStacks = 1; c.PlanOut = "plan.json" }, nil},
+		{"parallel stacks and plan out", func(c *Config) { c.ParallelStacks = 2; c.PlanOut = "plan.json" }, []string{"--parallel-stacks と --plan-out"}},
+		{"cross account and plan in", func(c *Config) { c.CrossAccountRepos = []string{"repo"}; c.PlanIn = "plan.json" }, []string{"--cross-account-repo と --plan-in"}},
+		{"several conflicts", func(c *Config) {
+			c.Verbose, c.Quiet = true, true
+			c.CleanupOnly, c.DestroyOnly = true, true
+		}, []string{"同時に指定できないフラグがあります", "  --verbose と --quiet", "  --cleanup-only と --destroy-only"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			tt.cfg(&cfg)
+			err := checkFlagConflicts(cfg)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("checkFlagConflicts: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkFlagConflicts succeeded, want %q", tt.want)
+			}
+			if code := ExitCode(err); code != ExitInvalidFlags {
+				t.Errorf("exit code %d, want %d", code, ExitInvalidFlags)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// flagConflicts のフラグは全て conflictingFlagsSet で判定できること
+func TestFlagConflictsKnownFlags(t *testing.T) {
+	set := conflictingFlagsSet(Config{})
+	for _, c := range flagConflicts {
+		for _, name := range []string{c.A, c.B} {
+			if _, ok := set[name]; !ok {
+				t.Errorf("%s is not in conflictingFlagsSet", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 別アカウントのリソースの種類
+const (
+	crossAccountBucket     = "bucket"
+	crossAccountRepository = "repository"
+)
+
+// --cross-account-bucket / --cross-account-repo で指定された、別アカウントで空にするリソース
+// Profile か RoleArn のどちらか一方の認証情報で操作する
+type crossAccountResource struct {
+	Kind    string
+	Name    string
+	Profile string // 共有設定ファイルの profile
+	RoleArn string // --profile の認証情報で AssumeRole するロール
+}
+
+// 認証情報ごとにまとめるためのキー
+func (r crossAccountResource) credentials() string {
+	if r.RoleArn != "" {
+		return r.RoleArn
+	}
+	return "profile " + r.Profile
+}
+
+// "<name>=<profile | role ARN>" の指定を分解する
+func parseCrossAccountResource(kind, s string) (crossAccountResource, error) {
+	name, cred, ok := strings.Cut(s, "=")
+	name, cred = strings.TrimSpace(name), strings.TrimSpace(cred)
+	if !ok || name == "" || cred == "" {
+		return crossAccountResource{}, fmt.Errorf("expected <name>=<profile or role ARN>, got %q", s)
+	}
+	r := crossAccountResource{Kind: kind, Name: name}
+	if strings.HasPrefix(cred, "arn:") {
+		if err := validateRoleArn(cred); err != nil {
+			return r, err
+		}
+		r.RoleArn = cred
+	} else {
+		r.Profile = cred
+	}
+	return r, nil
+}
+
+// --cross-account-bucket / --cross-account-repo の指定を指定順に並べる
+func parseCrossAccountResources(buckets, repos []string) ([]crossAccountResource, error) {
+	var resources []crossAccountResource
+	for _, s := range buckets {
+		r, err := parseCrossAccountResource(crossAccountBucket, s)
+		if err != nil {
+			return nil, fmt.Errorf("--cross-account-bucket: %w", err)
+		}
+		resources = append(resources, r)
+	}
+	for _, s := range repos {
+		r, err := parseCrossAccountResource(crossAccountRepository, s)
+		if err != nil {
+			return nil, fmt.Errorf("--cross-account-repo: %w", err)
+		}
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+// 別アカウントの処理結果をサマリーに載せるときの名前
+const crossAccountSummaryName = "(cross-account)"
+
+// 別アカウントのバケット・リポジトリを、それぞれに指定された認証情報で空にする
+// 認証情報 (profile / ロール) ごとに一度だけ接続し、base の region・エンドポイントを使う
+func cleanupCrossAccountResources(ctx context.Context, resources []crossAccountResource, base awsConfigOptions, ssoLogin bool, opts cleanupOptions) (result stackSummary, err error) {
+	result = stackSummary{Stack: crossAccountSummaryName, Status: statusNotRun}
+	defer func() {
+		result.Failures = failureMessages(err)
+		switch {
+		case errors.Is(err, errAbortedByUser):
+		case err != nil:
+			result.Status = statusFailed
+		default:
+			result.Status = statusSucceeded
+		}
+	}()
+
+	var order []string
+	byCredentials := map[string][]crossAccountResource{}
+	for _, r := range resources {
+		key := r.credentials()
+		if _, ok := byCredentials[key]; !ok {
+			order = append(order, key)
+		}
+		byCredentials[key] = append(byCredentials[key], r)
+	}
+
+	errs := phaseErrors{continueOnError: opts.ContinueOnError}
+	for _, key := range order {
+		group := byCredentials[key]
+		o := base
+		if group[0].RoleArn != "" {
+			o.Role = assumeRoleOptions{RoleArn: group[0].RoleArn, SessionName: base.Role.SessionName}
+		} else {
+			o.Profile, o.Role = group[0].Profile, assumeRoleOptions{}
+		}
+		clients, target, err := connectAWS(ctx, o, ssoLogin, opts.Retry)
+		if err != nil {
+			if errs.add(fmt.Errorf("%s: %w", key, err)) {
+				break
+			}
+			continue
+		}
+		credLog := loggerFrom(ctx).With("credentials", key)
+		credLog.Infof("Cross-account cleanup in account %s / %s", target.Account, target.Region)
+
+		var buckets []string
+		var repos []ecrRepository
+		for _, r := range group {
+			switch r.Kind {
+			case crossAccountBucket:
+				buckets = append(buckets, r.Name)
+			case crossAccountRepository:
+				repos = append(repos, ecrRepository{Name: r.Name})
+			}
+		}
+
+		if opts.Confirm && !opts.DryRun {
+			plan := destroyPlan{Account: target.Account, Region: target.Region, Buckets: buckets, Repos: repos}
+			if !confirmDestroy(opts.Prompt, os.Stdout, plan) {
+				return result, errAbortedByUser
+			}
+		}
+
+		if len(buckets) > 0 {
+			n, err := emptyS3Buckets(ctx, clients.S3, buckets, opts)
+			result.Buckets += len(buckets)
+			result.Objects += n
+			if err != nil && errs.add(fmt.Errorf("%s: Failed to empty S3 buckets: %w", key, err)) {
+				break
+			}
+		}
+		if len(repos) > 0 {
+			n, err := emptyEcrRepositories(ctx, clients.ECR, repos, opts)
+			result.Repositories += len(repos)
+			result.Images += n
+			if err != nil && errs.add(fmt.Errorf("%s: Failed to delete ECR images: %w", key, err)) {
+				break
+			}
+		}
+	}
+	return result, errs.err()
+}
@@ -0,0 +1,55 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// --delete-cluster: サービス・タスクが残っていて DeleteCluster が失敗したときに、空にし直して試す回数
+const deleteClusterAttempts = 3
+
+// --delete-cluster: 空にしたクラスターを DeleteCluster で削除する (cdk が管理しない --cluster のクラスター用)
+// 後から起動したサービス・タスクで失敗したら、クラスターを空にし直してから再試行する
+// 削除した (dryRun なら削除する) か、空にし直した分の件数を返す
+func deleteDrainedCluster(ctx context.Context, clients awsClients, clusterName string, opts cleanupOptions) (deleted bool, redrained clusterResult, err error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+	redrained = clusterResult{Name: clusterName}
+	if opts.DryRun {
+		clusterLog.Infof("[DryRun] Would delete the cluster (DeleteCluster)")
+		return true, redrained, nil
+	}
+	for attempt := 1; ; attempt++ {
+		clusterLog.Infof("Deleting cluster (DeleteCluster)...")
+		_, err := withRetry(ctx, opts.Retry, "DeleteCluster", func() (*ecs.DeleteClusterOutput, error) {
+			return clients.ECS.DeleteCluster(ctx, &ecs.DeleteClusterInput{Cluster: &clusterName})
+		})
+		var notFound *ecstypes.ClusterNotFoundException
+		var hasServices *ecstypes.ClusterContainsServicesException
+		var hasTasks *ecstypes.ClusterContainsTasksException
+		switch {
+		case err == nil:
+			clusterLog.Infof("Cluster deleted.")
+			return true, redrained, nil
+		case errors.As(err, &notFound):
+			clusterLog.Infof("Cluster does not exist, nothing to delete")
+			return false, redrained, nil
+		case !errors.As(err, &hasServices) && !errors.As(err, &hasTasks), attempt >= deleteClusterAttempts:
+			// コンテナインスタンスが残っている場合 (--scale-down-asg で解消する) などは再試行しない
+			return false, redrained, fmt.Errorf("DeleteCluster error: %w", err)
+		}
+
+		clusterLog.Warnf("Cluster still has services or tasks (attempt %d/%d), draining it again: %v", attempt, deleteClusterAttempts, err)
+		again, err := drainCluster(ctx, clients, clusterName, opts)
+		redrained.add(again.drainStats)
+		redrained.ServiceResults = append(redrained.ServiceResults, again.ServiceResults...)
+		redrained.StoppedTasks = append(redrained.StoppedTasks, again.StoppedTasks...)
+		redrained.StuckTasks = again.StuckTasks
+		if err != nil {
+			return false, redrained, fmt.Errorf("failed to drain the cluster again: %w", err)
+		}
+	}
+}
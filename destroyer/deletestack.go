@@ -0,0 +1,76 @@
+package destroyer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// --use-cloudformation: cdk を使わず CloudFormation の DeleteStack でスタックを削除する
+// 依存される側のスタックは先に --stack に書かれることが多いので、--stack の逆順に1つずつ削除する
+// 各スタックの削除完了を最大 timeout 待ち、DELETE_FAILED なら attempts 回まで DeleteStack をやり直す
+// concurrency が 2 以上 (--parallel-stacks) ならスタックは互いに独立しているとみなし、同時に削除する
+func deleteStacksViaCloudFormation(ctx context.Context, cfnClient cfnAPI, stackNames []string, timeout time.Duration, attempts, concurrency int, opts cleanupOptions) error {
+	deleteOne := func(stackName string) error {
+		stackLog := logger.With("stack", stackDisplayName(stackName))
+		if opts.DryRun {
+			stackLog.Infof("[DryRun] Would delete the stack with CloudFormation DeleteStack")
+			return nil
+		}
+		if err := deleteStackWithRetries(ctx, stackLog, cfnClient, stackName, timeout, attempts, opts.Retry); err != nil {
+			return fmt.Errorf("stack(%s): %w", stackDisplayName(stackName), err)
+		}
+		return nil
+	}
+	if concurrency > 1 {
+		return runConcurrently(stackNames, concurrency, deleteOne)
+	}
+	for _, stackName := range slices.Backward(stackNames) {
+		if err := deleteOne(stackName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteStackWithRetries(ctx context.Context, stackLog appLogger, cfnClient cfnAPI, stackName string, timeout time.Duration, attempts int, retry retryPolicy) error {
+	delay := cdkRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		if attempts > 1 {
+			stackLog.Infof("DeleteStack attempt %d/%d", attempt, attempts)
+		}
+		err := deleteStack(ctx, stackLog, cfnClient, stackName, timeout, retry)
+		if err == nil || attempt >= attempts || isInterrupted(err) {
+			return err
+		}
+		stackLog.Warnf("Stack deletion failed (attempt %d/%d), retrying in %s: %v", attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return wrapCancelled(ctx, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, cdkRetryMaxDelay)
+	}
+}
+
+// DeleteStack を呼び、DELETE_COMPLETE になるまで待つ (既に無いスタックは成功扱い)
+func deleteStack(ctx context.Context, stackLog appLogger, cfnClient cfnAPI, stackName string, timeout time.Duration, retry retryPolicy) error {
+	stackLog.Infof("Deleting stack (CloudFormation DeleteStack)...")
+	_, err := withRetry(ctx, retry, "DeleteStack", func() (*cfn.DeleteStackOutput, error) {
+		return cfnClient.DeleteStack(ctx, &cfn.DeleteStackInput{StackName: &stackName})
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteStack error: %w", err)
+	}
+
+	stackLog.Infof("Waiting for stack deletion to complete...")
+	waiter := cfn.NewStackDeleteCompleteWaiter(cfnClient)
+	if err := waiter.Wait(ctx, &cfn.DescribeStacksInput{StackName: &stackName}, timeout); err != nil {
+		return wrapCancelled(ctx, fmt.Errorf("stack deletion did not complete: %w", err))
+	}
+	stackLog.Infof("Stack deleted.")
+	return nil
+}
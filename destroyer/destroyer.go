@@ -0,0 +1,1384 @@
+// Package destroyer は ECS サービス・タスクなど cdk destroy を妨げるリソースを片付けてから
+// cdk destroy を実行する処理をまとめたパッケージ。CLI (main パッケージ) はこの薄いラッパー
+package destroyer
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 処理全体の設定。各フィールドは CLI の同名フラグ (コメント参照) に 1:1 で対応する
+// 既定値は DefaultConfig を使うこと (ゼロ値のままだと Concurrency などが不正になる)
+type Config struct {
+	Stacks                       []string      // --stack
+	Clusters                     []string      // --cluster (指定時はスタックからクラスターを探さない)
+	CrossAccountBuckets          []string      // --cross-account-bucket (<bucket>=<profile | role ARN>)
+	CrossAccountRepos            []string      // --cross-account-repo (<repository>=<profile | role ARN>)
+	Services                     []string      // --service (名前または ARN。指定時はそのサービスだけを処理する)
+	Profile                      string        // --profile
+	SSOLogin                     bool          // --sso-login
+	Region                       string        // --region
+	AllowUnknownRegion           bool          // --allow-unknown-region
+	CdkAppPath                   string        // --cdk-app-path
+	CdkAppCommand                string        // --cdk-app-command
+	CdkAppRoot                   string        // --cdk-app-root
+	CdkCwd                       string        // --cdk-cwd (cdk を実行するディレクトリ、空なら CdkAppRoot)
+	Strict                       bool          // --strict (CdkAppRoot に cdk.json が無ければエラーにする)
+	CdkBin                       string        // --cdk-bin
+	CdkArgs                      []string      // --cdk-arg / "--" 以降 (cdk destroy に追加する引数)
+	CdkTimeout                   time.Duration // --cdk-timeout
+	UseCloudFormation            bool          // --use-cloudformation (cdk destroy の代わりに DeleteStack で削除する)
+	MaxTotalTimeout              time.Duration // --max-total-timeout (0 なら無制限)
+	DryRun                       bool          // --dry-run
+	CheckPermissions             bool          // --check-permissions
+	Inspect                      bool          // --inspect (クラスター・サービス・タスクを出力するだけ)
+	Concurrency                  int           // --concurrency
+	ClusterConcurrency           int           // --cluster-concurrency (同時に処理するクラスター数)
+	ParallelStacks               int           // --parallel-stacks (同じリージョンで同時にクリーンアップするスタック数)
+	ServiceWaitTimeout           time.Duration // --service-wait-timeout
+	ProgressInterval             time.Duration // --progress-interval (0 なら表示しない)
+	CleanupOnly                  bool          // --cleanup-only
+	DestroyOnly                  bool          // --destroy-only
+	SkipECS                      bool          // --skip-ecs (--destroy-only と同じ)
+	TasksOnly                    bool          // --tasks-only (サービスは触らず、残ったタスクだけを止める)
+	LogFormat                    string        // --log-format ("text" / "json")
+	NoColor                      bool          // --no-color (NO_COLOR 環境変数でも無効になる)
+	Verbose                      bool          // --verbose
+	Quiet                        bool          // --quiet
+	MaxRetries                   int           // --max-retries
+	QPS                          float64       // --qps (ECS API 呼び出しの全体の上限、0 なら無制限)
+	DestroyRetries               int           // --destroy-retries (cdk destroy の最大試行回数)
+	FailedEvents                 int           // --failed-events (cdk destroy 失敗時に表示する DELETE_FAILED イベント数、0 なら表示しない)
+	AssumeRoleArn                string        // --assume-role-arn
+	ExpectedAccountID            string        // --expected-account-id
+	ExternalID                   string        // --external-id
+	RoleSessionName              string        // --role-session-name
+	EndpointURL                  string        // --endpoint-url
+	EmptyS3Buckets               bool          // --empty-s3-buckets
+	EmptyEcrRepos                bool          // --empty-ecr-repos
+	DeleteLogGroups              bool          // --delete-log-groups
+	DisableTableProtection       bool          // --disable-table-protection
+	CleanupEFS                   bool          // --cleanup-efs
+	PurgeQueues                  bool          // --purge-queues
+	DeleteTopicSubscriptions     bool          // --delete-topic-subscriptions
+	TaskWaitTimeout              time.Duration // --task-wait-timeout
+	ForceStop                    bool          // --force-stop (止まらないタスクに StopTask をやり直す)
+	StopReason                   string        // --stop-reason (空ならスタック名入りの既定値)
+	DeregisterTaskDefs           bool          // --deregister-task-defs
+	ScaleDownASG                 bool          // --scale-down-asg
+	DrainTargets                 bool          // --drain-targets
+	ContinueOnError              bool          // --continue-on-error
+	RetainCluster                bool          // --retain-cluster (スタックのサービスだけを削除し、cdk destroy はしない)
+	DeleteCluster                bool          // --delete-cluster (--cluster のクラスターを空にした後に削除する)
+	RemoveAutoscaling            bool          // --remove-autoscaling
+	DisableScheduledTasks        bool          // --disable-scheduled-tasks
+	CleanupENIs                  bool          // --cleanup-enis
+	StepDown                     bool          // --step-down
+	StepDownSize                 int           // --step-down-size
+	StepDownDelay                time.Duration // --step-down-delay
+	InstanceWaitTimeout          time.Duration // --instance-wait-timeout
+	WaitForStableStack           bool          // --wait-for-stable-stack
+	StackWaitTimeout             time.Duration // --stack-wait-timeout
+	RequireStack                 bool          // --require-stack
+	ClusterTag                   string        // --cluster-tag (key=value)
+	ClusterLogicalIDs            []string      // --cluster-logical-id
+	ServiceInclude               string        // --service-include (正規表現)
+	ServiceExclude               string        // --service-exclude (正規表現)
+	TasksStartedAfter            string        // --tasks-started-after (RFC3339 の時刻、または "2h" のような時間)
+	Output                       string        // --output (結果の JSON ファイル)
+	PlanOut                      string        // --plan-out (実行計画を書き出して何も変更しない)
+	PlanIn                       string        // --plan-in (保存した実行計画のとおりに実行する)
+	Notify                       string        // --notify (SNS トピック ARN か Slack Webhook URL)
+	DisableTerminationProtection bool          // --disable-termination-protection
+	MaxStackDepth                int           // --max-stack-depth
+	Yes                          bool          // --yes
+	Force                        bool          // --force (--yes に加えて --min-stack-age の確認も省く)
+	MinStackAge                  time.Duration // --min-stack-age (これより最近更新されたスタックは削除しない、0 なら確認しない)
+
+	// ログの出力先 (nil なら os.Stderr)。対応するフラグはない
+	LogOutput io.Writer
+}
+
+// DefaultConfig は CLI のフラグ既定値と同じ設定を返す
+func DefaultConfig() Config {
+	return Config{
+		CdkAppRoot:          ".",
+		CdkBin:              "cdk",
+		CdkTimeout:          30 * time.Minute,
+		Concurrency:         5,
+		ClusterConcurrency:  1,
+		ParallelStacks:      1,
+		ServiceWaitTimeout:  10 * time.Minute,
+		ProgressInterval:    30 * time.Second,
+		LogFormat:           "text",
+		MaxRetries:          5,
+		DestroyRetries:      1,
+		FailedEvents:        5,
+		StepDownSize:        1,
+		StepDownDelay:       30 * time.Second,
+		RoleSessionName:     "cdk-destroy-with-running-ecs",
+		TaskWaitTimeout:     5 * time.Minute,
+		InstanceWaitTimeout: 10 * time.Minute,
+		StackWaitTimeout:    30 * time.Minute,
+		MaxStackDepth:       5,
+	}
+}
+
+// ECS クリーンアップの動作設定
+type cleanupOptions struct {
+	DryRun                bool
+	Concurrency           int
+	ClusterConcurrency    int
+	ServiceWaitTimeout    time.Duration
+	ProgressInterval      time.Duration // 待機中の進捗ログの間隔
+	TaskWaitTimeout       time.Duration
+	ForceStop             bool // 待っても止まらないタスクを再度停止し、もう一度待つ
+	StopReason            string
+	DeregisterTaskDefs    bool
+	ScaleDownASG          bool
+	StepDown              bool // DesiredCount を StepDownSize ずつ減らしてから 0 にする
+	StepDownSize          int
+	StepDownDelay         time.Duration
+	CleanupENIs           bool // タスク停止後に残ったクラスターの ENI を削除する
+	DisableScheduledTasks bool // クラスターでタスクを起動する EventBridge ルールを先に無効化する
+	DrainTargets          bool // サービス削除前にロードバランサーのターゲットを登録解除する
+	ContinueOnError       bool // クリーンアップが失敗しても残りの処理を続け、cdk destroy も実行する
+	RetainCluster         bool // クラスターを残す (Services はスタックの AWS::ECS::Service に絞る)
+	DeleteCluster         bool // --cluster のクラスターを空にした後 DeleteCluster で削除する
+	RemoveAutoscaling     bool // DesiredCount=0 の前にサービスのスケーラブルターゲットを登録解除する
+	InstanceWaitTimeout   time.Duration
+	WaitForStableStack    bool // 進行中の操作 (*_IN_PROGRESS) が終わるまで待ってからクリーンアップする
+	StackWaitTimeout      time.Duration
+	EmptyS3Buckets        bool
+	EmptyEcrRepos         bool
+	DeleteLogGroups       bool
+	DisableTableProtect   bool // DynamoDB テーブルの削除保護を無効にする
+	CleanupEFS            bool // クラスターを空にした後、スタックの EFS マウントターゲットを削除する
+	PurgeQueues           bool // スタックの SQS キューのメッセージを削除する
+	DeleteSubscriptions   bool // スタックの SNS トピックのサブスクリプションを削除する
+	MaxStackDepth         int
+	SkipECS               bool // --destroy-only
+	TasksOnly             bool // --tasks-only
+	SkipDestroy           bool // --cleanup-only
+	Force                 bool // --min-stack-age の確認を省く
+	MinStackAge           time.Duration
+	UseCloudFormation     bool // cdk destroy の代わりに DeleteStack で削除する
+	DisableTermProtect    bool
+	RequireStack          bool
+	Clusters              []string // --cluster で直接指定されたクラスター
+	Services              []string // --service で指定されたサービス (空ならクラスターの全サービス)
+	Tasks                 []string // --plan-in: サービスに加えて止める、計画にあるサービスに属さないタスク ARN
+	ServiceFilter         serviceFilter
+	TasksStartedAfter     time.Time     // これより前に起動したタスクは直接止めない (ゼロ値なら全て)
+	ClusterTag            string        // スタックに ECS::Cluster が無いときに探すタグ (key=value、空なら探さない)
+	ClusterLogicalIDs     []string      // スタックに ECS::Cluster が無いときにクラスターとみなすリソースの論理 ID
+	Confirm               bool          // スタックごとに確認プロンプトを出す
+	Prompt                *bufio.Reader // 確認プロンプトの入力 (先読みした入力を失わないよう、実行全体で1つを使う)
+	Plan                  *savedPlan    // --plan-in: 計画にある削除対象だけを処理する
+	PlanOut               *savedPlan    // --plan-out: 探索結果をここに記録し、何も変更しない
+	Retry                 retryPolicy
+	Timings               *phaseTimings // 段階ごとの所要時間 (nil なら計測しない)
+	ClusterLocks          *keyedLocks   // --parallel-stacks: 同じクラスターは1スタックずつ処理する (nil なら排他しない)
+}
+
+// 処理するサービスが決まっているか (--service・計画・--retain-cluster)。決まっていればクラスターの一覧は使わない
+func (o cleanupOptions) servicesSelected() bool {
+	return len(o.Services) > 0 || o.Plan != nil || o.RetainCluster
+}
+
+// スタック stackName のクラスター clusterName で処理するサービスとタスクに絞った opts
+func (o cleanupOptions) forCluster(stackName, clusterName string) cleanupOptions {
+	switch {
+	case o.Plan != nil:
+		// 計画にあるサービスとタスクだけを処理する (計画後に増えたものは触らない)
+		sp := o.Plan.stack(stackName)
+		o.Services, o.Tasks = sp.services(clusterName), sp.tasks(clusterName)
+	case o.RetainCluster:
+		// スタックのサービスのうち、このクラスターのものだけ
+		o.Services = servicesInCluster(o.Services, clusterName)
+	}
+	return o
+}
+
+// Run は cfg に従ってスタックのクリーンアップと cdk destroy を実行する
+// 失敗時は ExitCode で終了コードに変換できるエラーを返す (エラーはログにも出力する)
+// ログの出力先をパッケージ全体で共有するため、同時に複数回呼ばないこと
+func Run(ctx context.Context, cfg Config) error {
+	_, err := RunWithOutput(ctx, cfg)
+	return err
+}
+
+// RunWithOutput は Run と同じ処理を行い、cdk destroy の出力 (stdout / stderr を結合したもの) も返す
+// cdk destroy を実行しなかった場合の出力は nil
+func RunWithOutput(ctx context.Context, cfg Config) ([]byte, error) {
+	output, err := run(ctx, cfg)
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+	return output, err
+}
+
+// ログの設定と各項目の検証 (不正な場合は ExitInvalidFlags のエラー)
+func validateConfig(cfg Config) error {
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return exitErrorf(ExitInvalidFlags, "Error: --log-format は text か json を指定してください。(got %q)", cfg.LogFormat)
+	}
+	if err := checkFlagConflicts(cfg); err != nil {
+		return err
+	}
+	level := slog.LevelInfo
+	if cfg.Verbose {
+		level = slog.LevelDebug
+	} else if cfg.Quiet {
+		level = slog.LevelWarn
+	}
+	out := cfg.LogOutput
+	if out == nil {
+		out = os.Stderr
+	}
+	logger = newLogger(out, cfg.LogFormat, level, cfg.LogFormat == "text" && useColor(out, cfg.NoColor))
+
+	if len(cfg.Stacks) == 0 && len(cfg.Clusters) == 0 && cfg.PlanIn == "" {
+		return exitErrorf(ExitInvalidFlags, "Error: --stack または --cluster を指定してください。")
+	}
+	if cfg.RetainCluster && len(cfg.Stacks) == 0 && cfg.PlanIn == "" {
+		return exitErrorf(ExitInvalidFlags, "Error: --retain-cluster では --stack を指定してください。(削除するサービスをスタックから探します)")
+	}
+	// スタックのクラスターは cdk destroy が削除する
+	if cfg.DeleteCluster && len(cfg.Clusters) == 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --delete-cluster は --cluster のクラスターにだけ使えます。")
+	}
+	if cfg.UseCloudFormation && !cfg.CleanupOnly && len(cfg.Stacks) == 0 && cfg.PlanIn == "" {
+		return exitErrorf(ExitInvalidFlags, "Error: --use-cloudformation では削除するスタックを --stack で指定してください。")
+	}
+	// --inspect では cdk を使わない
+	needCdk := !cfg.CleanupOnly && !cfg.RetainCluster && !cfg.UseCloudFormation && !cfg.Inspect
+	if cfg.CdkAppPath == "" && cfg.CdkAppCommand == "" && needCdk {
+		return exitErrorf(ExitInvalidFlags, "Error: --cdk-app-path か --cdk-app-command を指定してください。")
+	}
+	if needCdk {
+		if err := validateCdkAppRoot(cfg.CdkAppRoot, cfg.Strict); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --cdk-app-root が不正です: %w", err)
+		}
+		if _, err := resolveCdkCommand(cfg.CdkBin, cfg.CdkAppRoot); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --cdk-bin が不正です: %w", err)
+		}
+		if cfg.CdkCwd != "" {
+			if info, err := os.Stat(cfg.CdkCwd); err != nil || !info.IsDir() {
+				return exitErrorf(ExitInvalidFlags, "Error: --cdk-cwd にはディレクトリを指定してください。(got %q)", cfg.CdkCwd)
+			}
+		}
+		if err := validateCdkArgs(cfg.CdkArgs); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --cdk-arg が不正です: %w", err)
+		}
+	}
+	if cfg.Concurrency < 1 {
+		return exitErrorf(ExitInvalidFlags, "Error: --concurrency は 1 以上を指定してください。")
+	}
+	if cfg.ClusterConcurrency < 1 {
+		return exitErrorf(ExitInvalidFlags, "Error: --cluster-concurrency は 1 以上を指定してください。")
+	}
+	if cfg.ParallelStacks < 1 {
+		return exitErrorf(ExitInvalidFlags, "Error: --parallel-stacks は 1 以上を指定してください。")
+	}
+	// 確認プロンプトが同時に出ると答えられない
+	if cfg.ParallelStacks > 1 && !cfg.Yes && !cfg.Force && !cfg.DryRun {
+		return exitErrorf(ExitInvalidFlags, "Error: --parallel-stacks では --yes (または --dry-run) を指定してください。")
+	}
+	if cfg.ProgressInterval < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --progress-interval は 0 以上を指定してください。(got %s)", cfg.ProgressInterval)
+	}
+	if cfg.ServiceWaitTimeout <= 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --service-wait-timeout は正の値を指定してください。(got %s)", cfg.ServiceWaitTimeout)
+	}
+	if cfg.TaskWaitTimeout <= 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --task-wait-timeout は正の値を指定してください。(got %s)", cfg.TaskWaitTimeout)
+	}
+	if cfg.MaxTotalTimeout < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --max-total-timeout は 0 以上を指定してください。(got %s)", cfg.MaxTotalTimeout)
+	}
+	if cfg.CdkTimeout <= 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --cdk-timeout は正の値を指定してください。(got %s)", cfg.CdkTimeout)
+	}
+	if cfg.InstanceWaitTimeout <= 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --instance-wait-timeout は正の値を指定してください。(got %s)", cfg.InstanceWaitTimeout)
+	}
+	if cfg.StackWaitTimeout <= 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --stack-wait-timeout は正の値を指定してください。(got %s)", cfg.StackWaitTimeout)
+	}
+	if cfg.Region != "" && !cfg.AllowUnknownRegion {
+		if err := validateRegion(cfg.Region); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --region が不正です: %w (新しいリージョンや独自パーティションなら --allow-unknown-region を指定してください)", err)
+		}
+	}
+	for _, spec := range cfg.Stacks {
+		if _, region := splitStackSpec(spec); region != "" && !cfg.AllowUnknownRegion {
+			if err := validateRegion(region); err != nil {
+				return exitErrorf(ExitInvalidFlags, "Error: --stack %s のリージョンが不正です: %w (新しいリージョンや独自パーティションなら --allow-unknown-region を指定してください)", spec, err)
+			}
+		}
+	}
+	if cfg.EndpointURL != "" {
+		if err := validateEndpointURL(cfg.EndpointURL); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --endpoint-url が不正です: %w", err)
+		}
+	}
+	if cfg.MaxStackDepth < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --max-stack-depth は 0 以上を指定してください。")
+	}
+	if cfg.MinStackAge < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --min-stack-age は 0 以上を指定してください。(got %s)", cfg.MinStackAge)
+	}
+	if cfg.QPS < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --qps は 0 以上を指定してください。(got %g)", cfg.QPS)
+	}
+	if cfg.MaxRetries < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --max-retries は 0 以上を指定してください。")
+	}
+	if _, _, ok := parseTag(cfg.ClusterTag); cfg.ClusterTag != "" && !ok {
+		return exitErrorf(ExitInvalidFlags, "Error: --cluster-tag は key=value の形式で指定してください。(got %q)", cfg.ClusterTag)
+	}
+	if cfg.StepDownSize < 1 {
+		return exitErrorf(ExitInvalidFlags, "Error: --step-down-size は 1 以上を指定してください。")
+	}
+	if cfg.StepDownDelay < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --step-down-delay は 0 以上を指定してください。(got %s)", cfg.StepDownDelay)
+	}
+	if cfg.DestroyRetries < 1 {
+		return exitErrorf(ExitInvalidFlags, "Error: --destroy-retries は 1 以上を指定してください。")
+	}
+	if cfg.FailedEvents < 0 {
+		return exitErrorf(ExitInvalidFlags, "Error: --failed-events は 0 以上を指定してください。")
+	}
+	if cfg.Profile != "" {
+		if err := validateProfileName(cfg.Profile); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --profile が不正です: %w", err)
+		}
+	}
+	if cfg.ExpectedAccountID != "" && !accountIDPattern.MatchString(cfg.ExpectedAccountID) {
+		return exitErrorf(ExitInvalidFlags, "Error: --expected-account-id は12桁の数字で指定してください。(got %q)", cfg.ExpectedAccountID)
+	}
+	if cfg.Notify != "" {
+		if _, err := parseNotifyTarget(cfg.Notify); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --notify が不正です: %w", err)
+		}
+	}
+	if cfg.TasksStartedAfter != "" {
+		if _, err := parseStartedAfter(cfg.TasksStartedAfter, time.Now()); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --tasks-started-after が不正です: %w", err)
+		}
+	}
+	if cfg.AssumeRoleArn != "" {
+		if err := validateRoleArn(cfg.AssumeRoleArn); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: --assume-role-arn が不正です: %w", err)
+		}
+	}
+	if len(cfg.CrossAccountBuckets) > 0 || len(cfg.CrossAccountRepos) > 0 {
+		if _, err := parseCrossAccountResources(cfg.CrossAccountBuckets, cfg.CrossAccountRepos); err != nil {
+			return exitErrorf(ExitInvalidFlags, "Error: %w", err)
+		}
+	}
+	return nil
+}
+
+// 全体の処理 (失敗時は終了コード付きのエラーを返す)
+func run(ctx context.Context, cfg Config) (output []byte, err error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	// cdk の --app や作業ディレクトリが実行場所に左右されないよう絶対パスにする
+	if abs, err := filepath.Abs(cfg.CdkAppRoot); err == nil {
+		cfg.CdkAppRoot = abs
+	}
+	// --retain-cluster: クラスターを残すため cdk destroy は実行しない
+	if cfg.RetainCluster {
+		cfg.CleanupOnly = true
+	}
+	// --skip-ecs: ECS の探索・クリーンアップをせず cdk destroy だけを実行する
+	if cfg.SkipECS {
+		cfg.DestroyOnly = true
+	}
+	// --plan-in では対象のスタック・アカウント・リージョンを計画ファイルから取る
+	var plan *savedPlan
+	if cfg.PlanIn != "" {
+		plan, err = readPlanFile(cfg.PlanIn)
+		if err != nil {
+			return nil, exitErrorf(ExitInvalidFlags, "Error: --plan-in が不正です: %w", err)
+		}
+		cfg.Stacks = plan.stackNames()
+		if cfg.Region == "" {
+			cfg.Region = plan.Region
+		}
+	}
+	// スタックごとのリージョン (name@region / スタック ARN の region / --region) でまとめる
+	groups, awsRegion, err := groupStacksByRegion(cfg.Stacks, cfg.Region)
+	if err != nil {
+		return nil, exitErrorf(ExitInvalidFlags, "Error: --stack / --region が不正です: %w", err)
+	}
+	// cdk destroy はスタックのあるリージョンごとに実行する (スタックが無ければ既定のリージョンで1回)
+	var cdkGroups []int
+	for i, g := range groups {
+		if len(g.Stacks) > 0 {
+			cdkGroups = append(cdkGroups, i)
+		}
+	}
+	if len(cdkGroups) == 0 {
+		cdkGroups = []int{0}
+	}
+	defaultGroup := slices.IndexFunc(groups, func(g regionGroup) bool { return g.Region == awsRegion })
+	multiRegion := len(cdkGroups) > 1
+	if multiRegion && (cfg.PlanOut != "" || cfg.PlanIn != "") {
+		return nil, exitErrorf(ExitInvalidFlags, "Error: --plan-out / --plan-in は複数のリージョンのスタックには使えません。")
+	}
+	crossAccount, _ := parseCrossAccountResources(cfg.CrossAccountBuckets, cfg.CrossAccountRepos)
+	serviceFilter, err := newServiceFilter(cfg.ServiceInclude, cfg.ServiceExclude)
+	if err != nil {
+		return nil, exitErrorf(ExitInvalidFlags, "Error: 正規表現が不正です: %w", err)
+	}
+	var tasksStartedAfter time.Time
+	if cfg.TasksStartedAfter != "" {
+		tasksStartedAfter, _ = parseStartedAfter(cfg.TasksStartedAfter, time.Now())
+	}
+	opts := cleanupOptions{
+		DryRun:                cfg.DryRun,
+		Concurrency:           cfg.Concurrency,
+		ClusterConcurrency:    cfg.ClusterConcurrency,
+		ServiceWaitTimeout:    cfg.ServiceWaitTimeout,
+		ProgressInterval:      cfg.ProgressInterval,
+		TaskWaitTimeout:       cfg.TaskWaitTimeout,
+		ForceStop:             cfg.ForceStop,
+		StopReason:            cfg.StopReason,
+		DeregisterTaskDefs:    cfg.DeregisterTaskDefs,
+		ScaleDownASG:          cfg.ScaleDownASG,
+		DrainTargets:          cfg.DrainTargets,
+		ContinueOnError:       cfg.ContinueOnError,
+		RetainCluster:         cfg.RetainCluster,
+		DeleteCluster:         cfg.DeleteCluster,
+		RemoveAutoscaling:     cfg.RemoveAutoscaling,
+		DisableScheduledTasks: cfg.DisableScheduledTasks,
+		CleanupENIs:           cfg.CleanupENIs,
+		StepDown:              cfg.StepDown,
+		StepDownSize:          cfg.StepDownSize,
+		StepDownDelay:         cfg.StepDownDelay,
+		InstanceWaitTimeout:   cfg.InstanceWaitTimeout,
+		WaitForStableStack:    cfg.WaitForStableStack,
+		StackWaitTimeout:      cfg.StackWaitTimeout,
+		EmptyS3Buckets:        cfg.EmptyS3Buckets,
+		EmptyEcrRepos:         cfg.EmptyEcrRepos,
+		DeleteLogGroups:       cfg.DeleteLogGroups,
+		DisableTableProtect:   cfg.DisableTableProtection,
+		CleanupEFS:            cfg.CleanupEFS,
+		PurgeQueues:           cfg.PurgeQueues,
+		DeleteSubscriptions:   cfg.DeleteTopicSubscriptions,
+		MaxStackDepth:         cfg.MaxStackDepth,
+		SkipECS:               cfg.DestroyOnly,
+		TasksOnly:             cfg.TasksOnly,
+		SkipDestroy:           cfg.CleanupOnly,
+		UseCloudFormation:     cfg.UseCloudFormation,
+		DisableTermProtect:    cfg.DisableTerminationProtection,
+		RequireStack:          cfg.RequireStack,
+		Clusters:              clusterNames(cfg.Clusters),
+		Services:              cfg.Services,
+		ServiceFilter:         serviceFilter,
+		TasksStartedAfter:     tasksStartedAfter,
+		ClusterTag:            cfg.ClusterTag,
+		ClusterLogicalIDs:     cfg.ClusterLogicalIDs,
+		Confirm:               !cfg.Yes && !cfg.Force,
+		Prompt:                bufio.NewReader(os.Stdin),
+		Force:                 cfg.Force,
+		MinStackAge:           cfg.MinStackAge,
+		Retry:                 newRetryPolicy(cfg.MaxRetries),
+		Plan:                  plan,
+		Timings:               &phaseTimings{},
+	}
+	if cfg.ParallelStacks > 1 {
+		opts.ClusterLocks = newKeyedLocks()
+	}
+	if plan != nil {
+		plan.Options.apply(&opts)
+		cfg.CleanupOnly, cfg.DestroyOnly = opts.SkipDestroy, opts.SkipECS
+	}
+
+	// OTEL_EXPORTER_OTLP_ENDPOINT があれば、実行全体をルートとするトレースを送る
+	shutdownTracing, traceErr := setupTracing(ctx)
+	if traceErr != nil {
+		logger.Warnf("Failed to set up tracing, continuing without it: %v", traceErr)
+	}
+	defer shutdownTracing()
+	ctx, span := startSpan(ctx, "teardown")
+	defer func() { endSpan(span, err) }()
+
+	// 全体の期限。AWS 呼び出し・待機・cdk はこの ctx で中断される
+	if cfg.MaxTotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cfg.MaxTotalTimeout, errTotalTimeout)
+		defer cancel()
+	}
+
+	// ctx がキャンセルされたら AWS 呼び出し・待機・cdk を中断する
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errTotalTimeout) {
+				logger.Warnf("--max-total-timeout (%s) exceeded, stopping...", cfg.MaxTotalTimeout)
+				return
+			}
+			logger.Warnf("Cancelled, stopping... (interrupt again to force quit)")
+		case <-done:
+		}
+	}()
+
+	// ここから先は途中で失敗しても、それまでの処理結果をまとめて出力する
+	// (--output のファイルは認証情報の確認に失敗した場合も書く)
+	summary := runSummary{
+		Region:     awsRegion,
+		DryRun:     cfg.DryRun,
+		CdkDestroy: statusNotRun,
+		StartedAt:  time.Now(),
+	}
+	connected := false
+	var clients awsClients
+	defer func() {
+		summary.FinishedAt = time.Now()
+		summary.Phases = opts.Timings.list()
+		if connected {
+			summary.log(cfg.LogFormat)
+		}
+		if cfg.Notify != "" {
+			target, _ := parseNotifyTarget(cfg.Notify)
+			sendNotification(ctx, clients.SNS, target, summary, err)
+		}
+		if cfg.Output == "" {
+			return
+		}
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		if werr := summary.writeFile(cfg.Output); werr != nil {
+			logger.Errorf("Failed to write --output file %s: %v", cfg.Output, werr)
+		}
+	}()
+
+	var inv inventory // --inspect
+	// --qps の上限は全リージョンの呼び出しで共有する
+	limiter := newRateLimiter(cfg.QPS)
+
+	// スタックごとにクリーンアップする
+	// 失敗したら残りのスタックは処理しない (--continue-on-error なら続ける)
+	var stackErrs []error
+	groupClients := make([]awsClients, len(groups))
+	groupRegions := make([]string, len(groups)) // 接続したリージョン (profile の region や既定値で決まった場合も含む)
+groups:
+	for i, g := range groups {
+		// 既定のリージョンにスタックが無く --cluster も無ければ接続しない
+		if len(g.Stacks) == 0 && (len(cfg.Clusters) == 0 || cfg.DestroyOnly) && len(groups) > 1 {
+			continue
+		}
+		if multiRegion {
+			logger.Infof("Region %s: %d stack(s)", cmp.Or(g.Region, "(default)"), len(g.Stacks))
+		}
+
+		// AWS Config をロード (profile / region / endpoint / assume role を反映) して認証情報を確認
+		regionClients, target, err := connectAWS(ctx, awsConfigOptions{
+			Profile:     cfg.Profile,
+			Region:      g.Region,
+			EndpointURL: cfg.EndpointURL,
+			Role: assumeRoleOptions{
+				RoleArn:     cfg.AssumeRoleArn,
+				ExternalID:  cfg.ExternalID,
+				SessionName: cfg.RoleSessionName,
+			},
+		}, cfg.SSOLogin, opts.Retry)
+		if err != nil {
+			return nil, exitErrorf(ExitCleanupFailed, "%w", err)
+		}
+		if limiter != nil {
+			regionClients.ECS = rateLimitedECS{ecsAPI: regionClients.ECS, limiter: limiter}
+		}
+		// 別環境のアカウントを誤って操作しないよう、何かする前に止める
+		if cfg.ExpectedAccountID != "" && target.Account != cfg.ExpectedAccountID {
+			return nil, exitErrorf(ExitCleanupFailed, "AWS account mismatch: expected %s (--expected-account-id) but the credentials are for %s (profile: %s). Aborting before any change", cfg.ExpectedAccountID, target.Account, effectiveProfile(cfg.Profile))
+		}
+		groupClients[i], groupRegions[i] = regionClients, target.Region
+		if clients.CFN == nil {
+			clients = regionClients
+		}
+
+		// --inspect では見つかったクラスター・サービス・タスクを出力するだけで、何も変更しない
+		if cfg.Inspect {
+			if inv.Account == "" {
+				inv.Account, inv.Region = target.Account, target.Region
+			}
+			if i == defaultGroup && len(cfg.Clusters) > 0 {
+				clusters, err := inspectClusters(ctx, regionClients.ECS, opts.Clusters, opts.Retry)
+				if err != nil {
+					return nil, exitErrorf(ExitCleanupFailed, "%w", err)
+				}
+				inv.Stacks = append(inv.Stacks, stackInventory{Stack: clusterOnlySummaryName, Status: "-", Clusters: clusters})
+			}
+			for _, name := range g.Stacks {
+				st, err := inspectStack(stackContext(ctx, name), regionClients, name, opts)
+				if err != nil {
+					return nil, exitErrorf(ExitCleanupFailed, "stack(%s): %w", name, err)
+				}
+				if multiRegion {
+					st.Region = target.Region
+				}
+				inv.Stacks = append(inv.Stacks, st)
+			}
+			continue
+		}
+
+		// --check-permissions では権限の確認だけを行い、何も変更しない
+		if cfg.CheckPermissions {
+			if err := checkPermissions(ctx, regionClients, target, g.Stacks, opts); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// 計画を作ったときと別の環境では実行しない
+		if plan != nil && (target.Account != plan.Account || target.Region != plan.Region) {
+			return nil, exitErrorf(ExitCleanupFailed, "The plan %s is for account %s / %s but the credentials are for %s / %s. Aborting before any change", cfg.PlanIn, plan.Account, plan.Region, target.Account, target.Region)
+		}
+		if cfg.PlanOut != "" && opts.PlanOut == nil {
+			opts.PlanOut = &savedPlan{
+				Version:   planVersion,
+				CreatedAt: time.Now().UTC(),
+				Account:   target.Account,
+				Region:    target.Region,
+				Options:   newPlanOptions(opts),
+			}
+		}
+
+		if !connected {
+			summary.Account, summary.Region = target.Account, target.Region
+			span.SetAttributes(targetAttrs(target)...)
+			connected = true
+		}
+
+		// --cluster で指定されたクラスターはスタックとは別に、既定のリージョンで一度だけ処理する
+		if i == defaultGroup && len(cfg.Clusters) > 0 && !cfg.DestroyOnly {
+			result, err := cleanupClusters(ctx, regionClients, opts.Clusters, target, opts)
+			summary.Stacks = append(summary.Stacks, result)
+			if errors.Is(err, errAbortedByUser) {
+				return nil, err
+			}
+			if err != nil {
+				err = exitErrorf(ExitCleanupFailed, "%w", err)
+				if isInterrupted(err) {
+					return nil, err
+				}
+				stackErrs = append(stackErrs, err)
+				if !opts.ContinueOnError {
+					skipRemainingStacks(&summary, groups[i:], 0)
+					break groups
+				}
+			}
+		}
+
+		// --parallel-stacks: このリージョンのスタックを同時に処理し、全部終わってから失敗をまとめる
+		if cfg.ParallelStacks > 1 && len(g.Stacks) > 1 {
+			results, errs := cleanupStacksConcurrently(ctx, regionClients, g.Stacks, target, opts, cfg.ParallelStacks)
+			for _, result := range results {
+				if multiRegion {
+					result.Region = target.Region
+				}
+				summary.Stacks = append(summary.Stacks, result)
+			}
+			if len(errs) > 0 {
+				if err := errors.Join(errs...); isInterrupted(err) {
+					return nil, err
+				}
+				stackErrs = append(stackErrs, errs...)
+				if !opts.ContinueOnError {
+					skipRemainingStacks(&summary, groups[i+1:], 0)
+					break groups
+				}
+			}
+			continue
+		}
+
+		for j, name := range g.Stacks {
+			result, err := cleanupStack(stackContext(ctx, name), regionClients, name, target, opts)
+			if multiRegion {
+				result.Region = target.Region
+			}
+			summary.Stacks = append(summary.Stacks, result)
+			if errors.Is(err, errAbortedByUser) {
+				return nil, err
+			}
+			if err != nil {
+				err = exitErrorf(ExitCleanupFailed, "stack(%s): %w", name, err)
+				// 中断された場合は残りのスタックも処理しない
+				if isInterrupted(err) {
+					return nil, err
+				}
+				stackErrs = append(stackErrs, err)
+				if !opts.ContinueOnError {
+					skipRemainingStacks(&summary, groups[i:], j+1)
+					break groups
+				}
+			}
+		}
+	}
+	if cfg.CheckPermissions {
+		return nil, nil
+	}
+	if cfg.Inspect {
+		if err := inv.write(os.Stdout, cfg.LogFormat); err != nil {
+			return nil, exitErrorf(ExitFailure, "Failed to write the inventory: %w", err)
+		}
+		return nil, nil
+	}
+
+	// --plan-out では計画を書き出して終わる
+	if opts.PlanOut != nil {
+		if len(stackErrs) > 0 {
+			return nil, errors.Join(stackErrs...)
+		}
+		if err := opts.PlanOut.writeFile(cfg.PlanOut); err != nil {
+			return nil, exitErrorf(ExitCleanupFailed, "Failed to write --plan-out file %s: %w", cfg.PlanOut, err)
+		}
+		summary.CdkDestroy = statusSkipped
+		logger.Infof("Plan written to %s. Review it, then apply it with --plan-in %s", cfg.PlanOut, cfg.PlanOut)
+		return nil, nil
+	}
+
+	// スタックが参照する別アカウントのバケット・リポジトリは、それぞれの認証情報で空にする
+	// cdk destroy を実行しない場合 (--cleanup-only など) も実行する
+	if len(crossAccount) > 0 {
+		if len(stackErrs) > 0 && !opts.ContinueOnError {
+			summary.Stacks = append(summary.Stacks, stackSummary{Stack: crossAccountSummaryName, Status: statusNotRun})
+		} else {
+			result, err := cleanupCrossAccountResources(ctx, crossAccount, awsConfigOptions{
+				Region:      awsRegion,
+				EndpointURL: cfg.EndpointURL,
+				Profile:     cfg.Profile,
+				Role:        assumeRoleOptions{SessionName: cfg.RoleSessionName},
+			}, cfg.SSOLogin, opts)
+			summary.Stacks = append(summary.Stacks, result)
+			if errors.Is(err, errAbortedByUser) {
+				return nil, err
+			}
+			if err != nil {
+				err = exitErrorf(ExitCleanupFailed, "cross-account cleanup: %w", err)
+				if isInterrupted(err) {
+					return nil, err
+				}
+				stackErrs = append(stackErrs, err)
+			}
+		}
+	}
+
+	// 4. cdk destroy 実行
+	switch {
+	case opts.RetainCluster:
+		logger.Warnf("--retain-cluster: skipping cdk destroy. The stack and its cluster remain, but the deleted services are still in the stack: CloudFormation sees them as drifted, the next deploy recreates them, and a later cdk destroy still deletes the cluster.")
+		summary.CdkDestroy = statusSkipped
+	case cfg.CleanupOnly:
+		logger.Infof("--cleanup-only: skipping cdk destroy.")
+		summary.CdkDestroy = statusSkipped
+	case len(stackErrs) > 0 && !opts.ContinueOnError:
+		// クリーンアップに失敗したスタックが残っていると cdk destroy も失敗するので実行しない
+		logger.Warnf("Skipping cdk destroy because cleanup failed for %d stack(s) (use --continue-on-error to run it anyway).", len(stackErrs))
+	default:
+		if len(stackErrs) > 0 {
+			logger.Warnf("Cleanup failed for %d stack(s), running cdk destroy anyway (--continue-on-error).", len(stackErrs))
+		}
+		// 複数のリージョンにまたがる場合は、リージョンごとにそのスタックだけを --stack の順に destroy する
+		for _, i := range cdkGroups {
+			g := groups[i]
+			destroyErr := "Failed to run cdk destroy"
+			if cfg.UseCloudFormation {
+				// --use-cloudformation: cdk の代わりに DeleteStack で --stack のスタックを削除する
+				destroyErr = "Failed to delete stacks with CloudFormation"
+				stopDelete := opts.Timings.start(phaseStackDelete)
+				deleteCtx, deleteSpan := startSpan(ctx, phaseStackDelete, regionAttr(g.Region))
+				err = deleteStacksViaCloudFormation(deleteCtx, groupClients[i].CFN, g.Stacks, cfg.CdkTimeout, cfg.DestroyRetries, cfg.ParallelStacks, opts)
+				endSpan(deleteSpan, err)
+				stopDelete()
+			} else {
+				var groupOutput []byte
+				stopCdk := opts.Timings.start(phaseCdkDestroy)
+				cdkCtx, cdkSpan := startSpan(ctx, phaseCdkDestroy, regionAttr(g.Region))
+				groupOutput, err = runCdkDestroyWithRetries(cdkCtx, cdkGroupOptions(cfg, g, groupRegions[i]), cfg.DestroyRetries)
+				endSpan(cdkSpan, err)
+				stopCdk()
+				output = append(output, groupOutput...)
+				summary.CdkExitCode = cdkExitCode(err, cfg.DryRun)
+			}
+			if err != nil {
+				summary.CdkDestroy = statusFailed
+				summary.Failures = failureMessages(err)
+				// どのリソースが削除を止めたかはスタックのイベントにしか出ないことが多い
+				if cfg.FailedEvents > 0 && !isInterrupted(err) {
+					summary.Failures = append(summary.Failures, reportDeleteFailures(ctx, groupClients[i].CFN, g.Stacks, cfg.FailedEvents, opts.Retry)...)
+				}
+				// クリーンアップの失敗もまとめて返す (終了コードは cdk destroy の失敗を優先する)
+				return output, errors.Join(append([]error{exitErrorf(ExitDestroyFailed, "%s: %w", destroyErr, err)}, stackErrs...)...)
+			}
+		}
+		// dry-run ではコマンドを表示しただけなので "not run" のまま
+		if !cfg.DryRun {
+			summary.CdkDestroy = statusSucceeded
+		}
+	}
+	if len(stackErrs) > 0 {
+		return output, errors.Join(stackErrs...)
+	}
+
+	if !cfg.DryRun {
+		logger.Infof("All done.")
+	}
+	return output, nil
+}
+
+// ログに stack を付ける ctx
+func stackContext(ctx context.Context, stackName string) context.Context {
+	return withLogger(ctx, logger.With("stack", stackDisplayName(stackName)))
+}
+
+// スタックを最大 limit 個同時にクリーンアップする (結果は --stack の順、エラーはスタックごと)
+// 同じクラスターを使うスタックは opts.ClusterLocks で1つずつ処理する
+func cleanupStacksConcurrently(ctx context.Context, clients awsClients, stackNames []string, target awsTarget, opts cleanupOptions, limit int) ([]stackSummary, []error) {
+	results := make([]stackSummary, len(stackNames))
+	errs := make([]error, len(stackNames))
+	indexes := make([]int, len(stackNames))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	logger.Infof("Cleaning up %d stacks, %d at a time (--parallel-stacks)", len(stackNames), limit)
+	_ = runConcurrently(indexes, limit, func(i int) error {
+		name := stackNames[i]
+		var err error
+		results[i], err = cleanupStack(stackContext(ctx, name), clients, name, target, opts)
+		if err != nil {
+			errs[i] = exitErrorf(ExitCleanupFailed, "stack(%s): %w", name, err)
+		}
+		return errs[i]
+	})
+	return results, slices.DeleteFunc(errs, func(err error) bool { return err == nil })
+}
+
+// fail-fast で処理しなかったスタックを "not run" としてサマリーに載せる
+// リージョンのグループ g の cdk destroy のオプション
+// --stack のスタックだけを destroy する (--stack が無ければ --all)。region は接続したリージョン
+func cdkGroupOptions(cfg Config, g regionGroup, region string) cdkOptions {
+	var stacks []string
+	for _, name := range g.Stacks {
+		stacks = append(stacks, stackDisplayName(name))
+	}
+	return cdkOptions{
+		Bin:     cfg.CdkBin,
+		Profile: cfg.Profile,
+		Region:  cmp.Or(g.Region, region),
+		AppRoot: cfg.CdkAppRoot,
+		Dir:     cfg.CdkCwd,
+		AppPath: cfg.CdkAppPath,
+		AppCmd:  cfg.CdkAppCommand,
+		Timeout: cfg.CdkTimeout,
+		Stacks:  stacks,
+		Args:    cfg.CdkArgs,
+		DryRun:  cfg.DryRun,
+	}
+}
+
+// groups[0] の先頭 skip 個は処理済み
+func skipRemainingStacks(summary *runSummary, groups []regionGroup, skip int) {
+	var names []string
+	for i, g := range groups {
+		stacks := g.Stacks
+		if i == 0 {
+			stacks = stacks[skip:]
+		}
+		for _, name := range stacks {
+			summary.Stacks = append(summary.Stacks, stackSummary{Stack: name, Status: statusNotRun})
+			names = append(names, stackDisplayName(name))
+		}
+	}
+	if len(names) > 0 {
+		logger.Warnf("Skipping remaining stack(s) because cleanup failed (use --continue-on-error to keep going): %s", strings.Join(names, ", "))
+	}
+}
+
+// 確認プロンプトで中断されたことを表すエラー
+var errAbortedByUser = errors.New("aborted by user")
+
+// 1スタック分の探索とクリーンアップ (ECS / S3 / ECR)。処理結果は失敗時も返す
+func cleanupStack(ctx context.Context, clients awsClients, stackName string, target awsTarget, opts cleanupOptions) (result stackSummary, err error) {
+	result = stackSummary{Stack: stackName, Status: statusNotRun}
+	defer func() {
+		result.Failures = failureMessages(err)
+		switch {
+		case errors.Is(err, errAbortedByUser), result.Status == statusNotFound, result.Status == statusPlanned:
+		case err != nil:
+			result.Status = statusFailed
+		default:
+			result.Status = statusSucceeded
+		}
+	}()
+
+	ctx, span := startSpan(ctx, "stack cleanup", append(targetAttrs(target), stackAttr(stackName))...)
+	defer func() { endSpan(span, err) }()
+
+	opts.StopReason = stopTaskReason(opts.StopReason, stackName)
+	stopTiming := opts.Timings.start(phaseDiscovery)
+	_, discoverySpan := startSpan(ctx, phaseDiscovery, stackAttr(stackName))
+	stopDiscovery := sync.OnceFunc(func() {
+		stopTiming()
+		discoverySpan.End()
+	})
+	defer stopDiscovery()
+
+	// 既に削除済みのスタックは何もしない (--require-stack ならエラー)
+	stack, err := describeStack(ctx, clients.CFN, stackName, opts.Retry)
+	if err != nil {
+		return result, fmt.Errorf("Failed to describe stack: %w", err)
+	}
+	if stack == nil {
+		if opts.RequireStack {
+			return result, fmt.Errorf("stack %s does not exist", stackName)
+		}
+		loggerFrom(ctx).Infof("Stack does not exist (already deleted?), nothing to do.")
+		result.Status = statusNotFound
+		return result, nil
+	}
+
+	// --wait-for-stable-stack: 進行中のデプロイなどと競合しないよう、終わるまで待つ
+	if opts.WaitForStableStack {
+		stack, err = waitForStackStable(ctx, clients.CFN, stack, opts.StackWaitTimeout, opts.Retry)
+		if err != nil {
+			return result, err
+		}
+		if stack == nil {
+			loggerFrom(ctx).Infof("Stack was deleted while waiting, nothing to do.")
+			result.Status = statusNotFound
+			return result, nil
+		}
+	}
+
+	loggerFrom(ctx).Debugf("Stack last updated at %s", stackLastUpdated(stack).Format(time.RFC3339))
+	if err := checkStackAge(ctx, stack, opts); err != nil {
+		return result, err
+	}
+
+	// cdk destroy する場合は、クリーンアップの前に削除保護を確認する
+	if !opts.SkipDestroy {
+		if err := ensureTerminationProtectionDisabled(ctx, clients.CFN, stack, opts); err != nil {
+			return result, err
+		}
+	}
+
+	// ネストされたスタックも含めて探索対象にする
+	var stacks []string
+	if !opts.SkipECS || opts.EmptyS3Buckets || opts.EmptyEcrRepos || opts.DeleteLogGroups || opts.DisableTableProtect || opts.CleanupEFS || opts.PurgeQueues || opts.DeleteSubscriptions {
+		stacks, err = listStackTree(ctx, clients.CFN, stackName, opts.MaxStackDepth, opts.Retry)
+		if err != nil {
+			return result, fmt.Errorf("Failed to list nested stacks: %w", err)
+		}
+	}
+
+	var clusterNames []string
+	switch {
+	case opts.SkipECS:
+		loggerFrom(ctx).Infof("--destroy-only: skipping ECS cleanup.")
+	case len(opts.Clusters) > 0:
+		loggerFrom(ctx).Debugf("--cluster is set: skipping ECS cluster discovery.")
+	default:
+		clusterNames, err = discoverClusters(ctx, clients, stackName, stacks, opts)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// --retain-cluster: 残すクラスターにある他のスタックのサービスは触らず、このスタックのサービスだけを処理する
+	if opts.RetainCluster && !opts.SkipECS {
+		services, err := collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getEcsServiceArnsFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get ECS services: %w", err)
+		}
+		opts.Services = selectStackServices(services, opts.Services)
+		if len(opts.Services) == 0 {
+			loggerFrom(ctx).Infof("No ECS::Service in stack: %s", stackName)
+		}
+	}
+
+	// S3 バケットの取得
+	var bucketNames []string
+	if opts.EmptyS3Buckets {
+		bucketNames, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getS3BucketNamesFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get S3 bucket names: %w", err)
+		}
+		if len(bucketNames) == 0 {
+			loggerFrom(ctx).Infof("No S3::Bucket in stack: %s", stackName)
+		}
+	}
+
+	// ECR リポジトリの取得
+	var repos []ecrRepository
+	if opts.EmptyEcrRepos {
+		repos, err = collectFromStacks(stacks, func(stack string) ([]ecrRepository, error) {
+			return getEcrRepositoriesFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get ECR repositories: %w", err)
+		}
+		if len(repos) == 0 {
+			loggerFrom(ctx).Infof("No ECR::Repository in stack: %s", stackName)
+		}
+	}
+
+	// ロググループの取得
+	var logGroups []string
+	if opts.DeleteLogGroups {
+		logGroups, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getLogGroupNamesFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get log groups: %w", err)
+		}
+		if len(logGroups) == 0 {
+			loggerFrom(ctx).Infof("No Logs::LogGroup in stack: %s", stackName)
+		}
+	}
+
+	// DynamoDB テーブルの取得
+	var tables []string
+	if opts.DisableTableProtect {
+		tables, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getDynamoDBTableNamesFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get DynamoDB tables: %w", err)
+		}
+		if len(tables) == 0 {
+			loggerFrom(ctx).Infof("No DynamoDB::Table in stack: %s", stackName)
+		}
+	}
+
+	// EFS マウントターゲットの取得
+	var mountTargets []string
+	if opts.CleanupEFS {
+		mountTargets, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getEfsMountTargetsFromStack(ctx, clients.CFN, clients.EFS, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get EFS mount targets: %w", err)
+		}
+		if len(mountTargets) == 0 {
+			loggerFrom(ctx).Infof("No EFS::MountTarget in stack: %s", stackName)
+		}
+	}
+
+	// SQS キューの取得
+	var queues []string
+	if opts.PurgeQueues {
+		queues, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getSqsQueueUrlsFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get SQS queues: %w", err)
+		}
+		if len(queues) == 0 {
+			loggerFrom(ctx).Infof("No SQS::Queue in stack: %s", stackName)
+		}
+	}
+
+	// SNS トピックの取得
+	var topics []string
+	if opts.DeleteSubscriptions {
+		topics, err = collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getSnsTopicArnsFromStack(ctx, clients.CFN, stack, opts.Retry)
+		})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get SNS topics: %w", err)
+		}
+		if len(topics) == 0 {
+			loggerFrom(ctx).Infof("No SNS::Topic in stack: %s", stackName)
+		}
+	}
+
+	stopDiscovery()
+
+	// --plan-out: 探索結果を記録するだけで何も変更しない
+	if opts.PlanOut != nil {
+		sp, err := buildStackPlan(ctx, clients.ECS, stackName, clusterNames, bucketNames, repos, logGroups, tables, mountTargets, opts)
+		if err != nil {
+			return result, err
+		}
+		sp.Queues, sp.Topics = queues, topics
+		opts.PlanOut.Stacks = append(opts.PlanOut.Stacks, sp)
+		result.Status = statusPlanned
+		return result, nil
+	}
+	// --plan-in: 計画にあり、今も存在するものだけを処理する
+	if opts.Plan != nil {
+		sp := opts.Plan.stack(stackName)
+		clusterNames, bucketNames, repos, logGroups, tables, mountTargets = restrictToPlan(ctx, sp, clusterNames, bucketNames, repos, logGroups, tables, mountTargets)
+		queues = keepPlanned(ctx, "SQS queue", queues, sp.Queues, identity)
+		topics = keepPlanned(ctx, "SNS topic", topics, sp.Topics, identity)
+	}
+
+	// 削除前の確認 (dry-run では何も変更しないので不要)
+	if opts.Confirm && !opts.DryRun {
+		clusters, err := describeClusterPlans(ctx, clients.ECS, stackName, clusterNames, opts)
+		if err != nil {
+			return result, fmt.Errorf("Failed to inspect ECS clusters: %w", err)
+		}
+		plan := destroyPlan{
+			StackName:    stackName,
+			UpdatedAt:    stackLastUpdated(stack),
+			Account:      target.Account,
+			Region:       target.Region,
+			Clusters:     clusters,
+			Buckets:      bucketNames,
+			Repos:        repos,
+			LogGroups:    logGroups,
+			Tables:       tables,
+			MountTargets: mountTargets,
+			Queues:       queues,
+			Topics:       topics,
+		}
+		if !confirmDestroy(opts.Prompt, os.Stdout, plan) {
+			return result, errAbortedByUser
+		}
+	}
+
+	// ここから先は --continue-on-error なら失敗しても次のクリーンアップに進む
+	errs := phaseErrors{continueOnError: opts.ContinueOnError}
+	drainErr := drainClusters(ctx, clients, clusterNames, opts, &result)
+	if errs.add(drainErr) {
+		return result, errs.err()
+	}
+
+	// EFS マウントターゲットを削除 (タスクが残っている間は使われているかもしれないので消さない)
+	if len(mountTargets) > 0 {
+		if drainErr != nil || result.hasStuckTasks() {
+			if errs.add(errors.New("Skipped deleting EFS mount targets because ECS tasks may still be using them")) {
+				return result, errs.err()
+			}
+		} else {
+			result.MountTargets, err = deleteEfsMountTargets(ctx, clients.EFS, mountTargets, opts)
+			if err != nil && errs.add(fmt.Errorf("Failed to delete EFS mount targets: %w", err)) {
+				return result, errs.err()
+			}
+		}
+	}
+
+	// 新しいメッセージが届かないよう、キューより先にトピックのサブスクリプションを削除する
+	if len(topics) > 0 {
+		result.Topics, result.Subscriptions, err = deleteTopicSubscriptions(ctx, clients.SNS, topics, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to delete SNS subscriptions: %w", err)) {
+			return result, errs.err()
+		}
+	}
+
+	// SQS キューのメッセージを削除
+	if len(queues) > 0 {
+		result.Queues, err = purgeSqsQueues(ctx, clients.SQS, queues, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to purge SQS queues: %w", err)) {
+			return result, errs.err()
+		}
+	}
+
+	// S3 バケットを空にする
+	if len(bucketNames) > 0 {
+		result.Buckets = len(bucketNames)
+		result.Objects, err = emptyS3Buckets(ctx, clients.S3, bucketNames, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to empty S3 buckets: %w", err)) {
+			return result, errs.err()
+		}
+	}
+
+	// ECR リポジトリのイメージを削除
+	if len(repos) > 0 {
+		result.Repositories = len(repos)
+		result.Images, err = emptyEcrRepositories(ctx, clients.ECR, repos, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to empty ECR repositories: %w", err)) {
+			return result, errs.err()
+		}
+	}
+
+	// ロググループを削除
+	if len(logGroups) > 0 {
+		result.LogGroups, err = deleteLogGroups(ctx, clients.Logs, logGroups, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to delete log groups: %w", err)) {
+			return result, errs.err()
+		}
+	}
+
+	// DynamoDB テーブルの削除保護を無効にする (テーブルは cdk destroy が削除する)
+	if len(tables) > 0 {
+		result.Tables, err = disableTableDeletionProtection(ctx, clients.DDB, tables, opts)
+		if err != nil && errs.add(fmt.Errorf("Failed to disable DynamoDB deletion protection: %w", err)) {
+			return result, errs.err()
+		}
+	}
+	return result, errs.err()
+}
+
+// スタック (とネストされたスタック) の ECS クラスター名を取得
+// AWS::ECS::Cluster が無ければ --cluster-logical-id の論理 ID、それでも無ければ --cluster-tag のタグで探す
+func discoverClusters(ctx context.Context, clients awsClients, stackName string, stacks []string, opts cleanupOptions) ([]string, error) {
+	names, err := collectFromStacks(stacks, func(stack string) ([]string, error) {
+		return getEcsClusterNamesFromStack(ctx, clients.CFN, stack, opts.Retry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get ECS cluster names: %w", err)
+	}
+	if len(names) == 0 && len(opts.ClusterLogicalIDs) > 0 {
+		ids, err := collectFromStacks(stacks, func(stack string) ([]string, error) {
+			return getEcsClusterNamesByLogicalIDs(ctx, clients.CFN, stack, opts.ClusterLogicalIDs, opts.Retry)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get ECS clusters by logical ID: %w", err)
+		}
+		names = clusterNames(ids)
+	}
+	if len(names) == 0 && opts.ClusterTag != "" {
+		// 既存クラスターを import しているスタックはタグで探す
+		key, value, _ := parseTag(opts.ClusterTag)
+		names, err = findClustersByTag(ctx, clients.ECS, key, value, opts.Retry)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to find ECS clusters by tag: %w", err)
+		}
+		for _, name := range names {
+			loggerFrom(ctx).With("cluster", name).Infof("Found ECS cluster by tag %s", opts.ClusterTag)
+		}
+	}
+	if len(names) == 0 {
+		loggerFrom(ctx).Infof("No ECS::Cluster in stack: %s", stackName)
+	}
+	return names, nil
+}
+
+// --cluster で指定されたクラスターのクリーンアップ (スタックの探索はしない)
+func cleanupClusters(ctx context.Context, clients awsClients, clusterNames []string, target awsTarget, opts cleanupOptions) (result stackSummary, err error) {
+	result = stackSummary{Stack: clusterOnlySummaryName, Status: statusNotRun}
+	defer func() {
+		result.Failures = failureMessages(err)
+		switch {
+		case errors.Is(err, errAbortedByUser):
+		case err != nil:
+			result.Status = statusFailed
+		default:
+			result.Status = statusSucceeded
+		}
+	}()
+
+	opts.StopReason = stopTaskReason(opts.StopReason, "")
+
+	if opts.Confirm && !opts.DryRun {
+		clusters, err := describeClusterPlans(ctx, clients.ECS, result.Stack, clusterNames, opts)
+		if err != nil {
+			return result, fmt.Errorf("Failed to inspect ECS clusters: %w", err)
+		}
+		plan := destroyPlan{Account: target.Account, Region: target.Region, Clusters: clusters}
+		if !confirmDestroy(opts.Prompt, os.Stdout, plan) {
+			return result, errAbortedByUser
+		}
+	}
+	if err := drainClusters(ctx, clients, clusterNames, opts, &result); err != nil || !opts.DeleteCluster {
+		return result, err
+	}
+
+	// --delete-cluster: 空にしたクラスターは cdk が削除しないので、ここで削除する
+	errs := phaseErrors{continueOnError: opts.ContinueOnError}
+	for i := range result.ClusterResults {
+		c := &result.ClusterResults[i]
+		if c.Skipped {
+			continue
+		}
+		deleted, redrained, err := deleteDrainedCluster(ctx, clients, c.Name, opts)
+		c.Deleted = deleted
+		c.add(redrained.drainStats)
+		c.ServiceResults = append(c.ServiceResults, redrained.ServiceResults...)
+		c.StoppedTasks = append(c.StoppedTasks, redrained.StoppedTasks...)
+		if redrained.StuckTasks != nil {
+			c.StuckTasks = redrained.StuckTasks
+		}
+		result.add(redrained.drainStats)
+		if deleted {
+			result.DeletedClusters++
+		}
+		if err != nil && errs.add(fmt.Errorf("Failed to delete cluster(%s): %w", c.Name, err)) {
+			break
+		}
+	}
+	return result, errs.err()
+}
+
+// --cluster のみの処理結果をサマリーに載せるときの名前
+const clusterOnlySummaryName = "(--cluster)"
+
+// クラスターを順に空にし、件数を result に加える
+func drainClusters(ctx context.Context, clients awsClients, clusterNames []string, opts cleanupOptions, result *stackSummary) error {
+	drain := func(i int, clusterName string) (clusterResult, error) {
+		if len(clusterNames) > 1 {
+			loggerFrom(ctx).With("cluster", clusterName).Infof("Draining cluster (%d/%d)...", i+1, len(clusterNames))
+		}
+		// 他のスタックが同じクラスターを処理中なら終わるまで待つ (--parallel-stacks)
+		unlock, err := opts.ClusterLocks.lock(ctx, clusterName)
+		if err != nil {
+			return clusterResult{Name: clusterName}, wrapCancelled(ctx, err)
+		}
+		defer unlock()
+		cluster, err := drainCluster(ctx, clients, clusterName, opts.forCluster(result.Stack, clusterName))
+		if err != nil {
+			return cluster, fmt.Errorf("Failed to drain cluster(%s): %w", clusterName, err)
+		}
+		return cluster, nil
+	}
+
+	// 1クラスターずつなら最初の失敗で止める (--continue-on-error なら残りのクラスターも処理する)
+	if opts.ClusterConcurrency <= 1 || len(clusterNames) <= 1 {
+		errs := phaseErrors{continueOnError: opts.ContinueOnError}
+		for i, clusterName := range clusterNames {
+			cluster, err := drain(i, clusterName)
+			result.Clusters++
+			result.add(cluster.drainStats)
+			result.ClusterResults = append(result.ClusterResults, cluster)
+			if errs.add(err) {
+				break
+			}
+		}
+		return errs.err()
+	}
+
+	// 並列に処理する場合は全クラスターを処理してから失敗をまとめて返す (結果は指定順に並べる)
+	clusters := make([]clusterResult, len(clusterNames))
+	indexes := make([]int, len(clusterNames))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	err := runConcurrently(indexes, opts.ClusterConcurrency, func(i int) error {
+		var err error
+		clusters[i], err = drain(i, clusterNames[i])
+		return err
+	})
+	for _, cluster := range clusters {
+		result.Clusters++
+		result.add(cluster.drainStats)
+		result.ClusterResults = append(result.ClusterResults, cluster)
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package destroyer
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// タスクごとの結果 (TaskResult.Status)
+const (
+	statusStopped    = "stopped"
+	statusNotStopped = "did not stop" // StopTask 後も止まらなかった
+)
+
+// DrainOptions は Drain の動作設定 (CLI のフラグやパッケージの状態とは独立)
+// Concurrency・ServiceWaitTimeout・TaskWaitTimeout はゼロ値なら DefaultDrainOptions と同じ値を使う
+type DrainOptions struct {
+	DryRun                    bool          // 何も変更せず、対象だけを結果に入れる
+	Services                  []string      // 処理するサービスの名前か ARN (空ならクラスターの全サービス)
+	TasksOnly                 bool          // サービスは触らず、残ったタスクだけを止める
+	TasksStartedAfter         time.Time     // これより前に起動したタスクは直接止めない (ゼロ値なら全て)
+	DeregisterTaskDefinitions bool          // サービスが使っていたタスク定義を登録解除する
+	Concurrency               int           // 同時に処理するサービス・タスクの数
+	ServiceWaitTimeout        time.Duration // サービスごとに安定 (タスク 0) を待つ時間の上限
+	TaskWaitTimeout           time.Duration // タスクの停止を待つ時間の上限
+	MaxRetries                int           // スロットリングなどの一時的なエラーで API を再試行する回数
+	StopReason                string        // StopTask に渡す理由 (空なら既定の文言)
+	Logger                    *slog.Logger  // 進捗のログの出力先 (nil なら出力しない)
+}
+
+// DefaultDrainOptions は CLI のフラグ既定値と同じ設定を返す
+func DefaultDrainOptions() DrainOptions {
+	cfg := DefaultConfig()
+	return DrainOptions{
+		Concurrency:        cfg.Concurrency,
+		ServiceWaitTimeout: cfg.ServiceWaitTimeout,
+		TaskWaitTimeout:    cfg.TaskWaitTimeout,
+		MaxRetries:         cfg.MaxRetries,
+	}
+}
+
+// DrainResult は Drain の結果
+type DrainResult struct {
+	Cluster         string
+	Skipped         bool // クラスターが無い・削除中のため何もしなかった
+	Services        []ServiceResult
+	Tasks           []TaskResult // 止めた (DryRun では止める) タスク
+	TaskDefinitions int          // 登録解除した (DryRun では登録解除する) タスク定義の数
+	Duration        time.Duration
+}
+
+// ServiceResult はサービスごとの結果
+type ServiceResult struct {
+	Name string
+	// "succeeded" / "deleted without confirmed stability" / "failed" (DryRun では "not run")
+	Status   string
+	Duration time.Duration
+	Err      error // 失敗したときのエラー
+}
+
+// TaskResult はタスクごとの結果
+type TaskResult struct {
+	ARN    string
+	Status string // "stopped" / "did not stop" (DryRun では "not run")
+}
+
+// Drain はクラスターのサービスを 0 にして削除し、残ったタスクを止めて、その結果を返す
+// clusterName はクラスターの ARN でもよい。ログは opts.Logger にだけ出す
+// 失敗しても、それまでに処理したサービス・タスクの結果は返す
+// CLI (--cluster を含む) は Drain を経由せず、DrainOptions に無い処理 (ASG の縮小・ルールの無効化など) も含めて
+// 同じ drainCluster を直接呼び、結果は stackSummary として出力する
+func Drain(ctx context.Context, api ECSAPI, clusterName string, opts DrainOptions) (DrainResult, error) {
+	started := time.Now()
+	log := appLogger{l: opts.Logger}
+	if opts.Logger == nil {
+		log = appLogger{l: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	}
+	ctx = withLogger(ctx, log)
+	result, err := drainCluster(ctx, awsClients{ECS: api}, clusterNameFromID(clusterName), opts.cleanupOptions())
+	return newDrainResult(result, opts.DryRun, time.Since(started)), err
+}
+
+// Drain に必要な項目だけを設定した cleanupOptions
+func (o DrainOptions) cleanupOptions() cleanupOptions {
+	defaults := DefaultDrainOptions()
+	return cleanupOptions{
+		DryRun:             o.DryRun,
+		Concurrency:        max(cmp.Or(o.Concurrency, defaults.Concurrency), 1),
+		ServiceWaitTimeout: cmp.Or(o.ServiceWaitTimeout, defaults.ServiceWaitTimeout),
+		TaskWaitTimeout:    cmp.Or(o.TaskWaitTimeout, defaults.TaskWaitTimeout),
+		DeregisterTaskDefs: o.DeregisterTaskDefinitions,
+		TasksOnly:          o.TasksOnly,
+		TasksStartedAfter:  o.TasksStartedAfter,
+		Services:           o.Services,
+		StopReason:         stopTaskReason(o.StopReason, ""),
+		Retry:              newRetryPolicy(o.MaxRetries),
+	}
+}
+
+// drainCluster の結果を Drain の結果に組み立て直す
+func newDrainResult(r clusterResult, dryRun bool, elapsed time.Duration) DrainResult {
+	result := DrainResult{
+		Cluster:         r.Name,
+		Skipped:         r.Skipped,
+		TaskDefinitions: r.TaskDefinitions,
+		Duration:        elapsed,
+	}
+	for _, svc := range r.ServiceResults {
+		result.Services = append(result.Services, ServiceResult{Name: svc.Name, Status: svc.Status, Duration: svc.Duration, Err: svc.err})
+	}
+	for _, taskArn := range r.StoppedTasks {
+		status := statusStopped
+		switch {
+		case dryRun:
+			status = statusNotRun
+		case slices.Contains(r.StuckTasks, taskArn):
+			status = statusNotStopped
+		}
+		result.Tasks = append(result.Tasks, TaskResult{ARN: taskArn, Status: status})
+	}
+	return result
+}
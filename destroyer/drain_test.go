@@ -0,0 +1,137 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+// テスト用の DrainOptions (待ち時間を短くする)
+func testDrainOptions() DrainOptions {
+	return DrainOptions{Concurrency: 4, ServiceWaitTimeout: 5 * time.Second, TaskWaitTimeout: 5 * time.Second, MaxRetries: 1}
+}
+
+func TestDrain(t *testing.T) {
+	api := fakes.NewECS()
+	clusterArn := api.AddCluster("app")
+	api.AddService("app", "web", 2)
+	api.AddService("app", "worker", 1)
+	standalone := api.AddTask("app", ecstypes.Task{})
+
+	opts := testDrainOptions()
+	opts.DeregisterTaskDefinitions = true
+	result, err := Drain(context.Background(), api, clusterArn, opts)
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if result.Cluster != "app" || result.Skipped {
+		t.Errorf("Cluster = %q, Skipped = %v; want app, false", result.Cluster, result.Skipped)
+	}
+	if len(result.Services) != 2 {
+		t.Fatalf("Services = %+v, want web and worker", result.Services)
+	}
+	for i, name := range []string{"web", "worker"} {
+		if svc := result.Services[i]; svc.Name != name || svc.Status != statusSucceeded || svc.Err != nil {
+			t.Errorf("Services[%d] = %+v, want %s succeeded", i, svc, name)
+		}
+	}
+	if len(result.Tasks) != 1 || result.Tasks[0] != (TaskResult{ARN: standalone, Status: statusStopped}) {
+		t.Errorf("Tasks = %+v, want the standalone task stopped", result.Tasks)
+	}
+	if result.TaskDefinitions != 2 {
+		t.Errorf("TaskDefinitions = %d, want 2", result.TaskDefinitions)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Duration = %s, want > 0", result.Duration)
+	}
+	// StopReason を指定しなければ既定の文言になる
+	if task, _ := api.Task(standalone); aws.ToString(task.StoppedReason) != "Cleanup before destroy" {
+		t.Errorf("StoppedReason = %q, want the default reason", aws.ToString(task.StoppedReason))
+	}
+}
+
+func TestDrainDryRun(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	api.AddService("app", "web", 1)
+	standalone := api.AddTask("app", ecstypes.Task{})
+
+	opts := testDrainOptions()
+	opts.DryRun = true
+	result, err := Drain(context.Background(), api, "app", opts)
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if len(result.Services) != 1 || result.Services[0].Status != statusNotRun {
+		t.Errorf("Services = %+v, want web not run", result.Services)
+	}
+	// サービスのタスクも残っているので、全て止める対象になる
+	if len(result.Tasks) != 2 {
+		t.Errorf("Tasks = %+v, want 2", result.Tasks)
+	}
+	for _, task := range result.Tasks {
+		if task.Status != statusNotRun {
+			t.Errorf("task %s: status %q, want %q", task.ARN, task.Status, statusNotRun)
+		}
+	}
+	for _, op := range []string{"UpdateService", "DeleteService", "StopTask"} {
+		if n := api.CallCount(op); n != 0 {
+			t.Errorf("%s called %d times in a dry run", op, n)
+		}
+	}
+	if task, _ := api.Task(standalone); aws.ToString(task.LastStatus) != "RUNNING" {
+		t.Errorf("standalone task: status %s, want RUNNING", aws.ToString(task.LastStatus))
+	}
+}
+
+func TestDrainFailedService(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	api.AddService("app", "web", 1)
+	api.AddService("app", "worker", 1)
+	api.Err = func(op string, input any) error {
+		if in, ok := input.(*ecs.UpdateServiceInput); ok && arnToName(aws.ToString(in.Service)) == "worker" {
+			return &ecstypes.AccessDeniedException{Message: aws.String("denied")}
+		}
+		return nil
+	}
+
+	result, err := Drain(context.Background(), api, "app", testDrainOptions())
+	if err == nil || !strings.Contains(err.Error(), "service(worker)") {
+		t.Errorf("Drain error = %v, want the worker failure", err)
+	}
+	if len(result.Services) != 2 {
+		t.Fatalf("Services = %+v, want both services", result.Services)
+	}
+	var denied *ecstypes.AccessDeniedException
+	if svc := result.Services[1]; svc.Name != "worker" || svc.Status != statusFailed || !errors.As(svc.Err, &denied) {
+		t.Errorf("Services[1] = %+v, want worker failed with AccessDeniedException", svc)
+	}
+	if svc := result.Services[0]; svc.Status != statusSucceeded {
+		t.Errorf("Services[0] = %+v, want web succeeded", svc)
+	}
+}
+
+func TestDrainMissingCluster(t *testing.T) {
+	result, err := Drain(context.Background(), fakes.NewECS(), "gone", testDrainOptions())
+	if err != nil || !result.Skipped {
+		t.Errorf("Drain = %+v, %v; want skipped", result, err)
+	}
+}
+
+func TestNewDrainResultStuckTasks(t *testing.T) {
+	r := clusterResult{Name: "app", StoppedTasks: []string{"t1", "t2"}, StuckTasks: []string{"t2"}}
+	got := newDrainResult(r, false, time.Second)
+	want := []TaskResult{{ARN: "t1", Status: statusStopped}, {ARN: "t2", Status: statusNotStopped}}
+	if len(got.Tasks) != 2 || got.Tasks[0] != want[0] || got.Tasks[1] != want[1] {
+		t.Errorf("Tasks = %+v, want %+v", got.Tasks, want)
+	}
+}
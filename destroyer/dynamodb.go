@@ -0,0 +1,62 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// スタック内の DynamoDB テーブル名を取得
+func getDynamoDBTableNamesFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::DynamoDB::Table", retry)
+}
+
+// テーブルの削除保護を無効にする (dryRun 時は対象の表示のみ)。変更したテーブル数を返す
+// テーブル自体は削除しない (cdk destroy に任せる)。既に存在しないテーブルは無視する
+func disableTableDeletionProtection(ctx context.Context, ddbClient dynamodbAPI, tableNames []string, opts cleanupOptions) (int, error) {
+	var changed int
+	var errs []error
+	for _, name := range tableNames {
+		tableLog := loggerFrom(ctx).With("table", name)
+		out, err := withRetry(ctx, opts.Retry, "DescribeTable", func() (*dynamodb.DescribeTableOutput, error) {
+			return ddbClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)})
+		})
+		if err != nil {
+			var notFound *ddbtypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				tableLog.Infof("Table does not exist, skipping")
+				continue
+			}
+			tableLog.Errorf("Failed to describe table: %v", err)
+			errs = append(errs, fmt.Errorf("table(%s): DescribeTable error: %w", name, err))
+			continue
+		}
+		if !aws.ToBool(out.Table.DeletionProtectionEnabled) {
+			tableLog.Debugf("Deletion protection is already disabled")
+			continue
+		}
+		if opts.DryRun {
+			tableLog.Infof("[DryRun] Would disable deletion protection")
+			changed++
+			continue
+		}
+		_, err = withRetry(ctx, opts.Retry, "UpdateTable", func() (*dynamodb.UpdateTableOutput, error) {
+			return ddbClient.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+				TableName:                 aws.String(name),
+				DeletionProtectionEnabled: aws.Bool(false),
+			})
+		})
+		if err != nil {
+			tableLog.Errorf("Failed to disable deletion protection: %v", err)
+			errs = append(errs, fmt.Errorf("table(%s): %w", name, err))
+			continue
+		}
+		tableLog.Infof("Disabled deletion protection")
+		changed++
+	}
+	return changed, errors.Join(errs...)
+}
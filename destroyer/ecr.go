@@ -0,0 +1,176 @@
+package destroyer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// BatchDeleteImage 1回で削除できる最大件数
+const ecrDeleteBatchSize = 100
+
+// スタック内の ECR リポジトリ
+type ecrRepository struct {
+	Name string
+	// EmptyOnDelete が有効なら CloudFormation が削除時に空にするので何もしない
+	EmptyOnDelete bool
+}
+
+// スタック内の ECR リポジトリを取得
+func getEcrRepositoriesFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]ecrRepository, error) {
+	resources, err := listStackResources(ctx, cfnClient, stackName, "AWS::ECR::Repository", retry)
+	if err != nil || len(resources) == 0 {
+		return nil, err
+	}
+
+	emptyOnDelete, err := ecrEmptyOnDeleteFromTemplate(ctx, cfnClient, stackName, retry)
+	if err != nil {
+		loggerFrom(ctx).Warnf("Could not read EmptyOnDelete from the stack template, treating all repositories as non-empty-on-delete: %v", err)
+	}
+
+	var repos []ecrRepository
+	for _, r := range resources {
+		repos = append(repos, ecrRepository{
+			Name:          *r.PhysicalResourceId,
+			EmptyOnDelete: emptyOnDelete[aws.ToString(r.LogicalResourceId)],
+		})
+	}
+	return repos, nil
+}
+
+// テンプレートから ECR リポジトリごとの EmptyOnDelete 設定を読む (論理 ID → 有効か)
+// CDK が出力する JSON テンプレートのみ対応
+func ecrEmptyOnDeleteFromTemplate(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) (map[string]bool, error) {
+	out, err := withRetry(ctx, retry, "GetTemplate", func() (*cfn.GetTemplateOutput, error) {
+		return cfnClient.GetTemplate(ctx, &cfn.GetTemplateInput{
+			StackName:     &stackName,
+			TemplateStage: cfntypes.TemplateStageOriginal,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetTemplate error: %w", err)
+	}
+
+	var tmpl struct {
+		Resources map[string]struct {
+			Type       string
+			Properties struct {
+				EmptyOnDelete any
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(out.TemplateBody)), &tmpl); err != nil {
+		return nil, fmt.Errorf("template is not JSON: %w", err)
+	}
+
+	result := map[string]bool{}
+	for logicalID, r := range tmpl.Resources {
+		if r.Type != "AWS::ECR::Repository" {
+			continue
+		}
+		switch v := r.Properties.EmptyOnDelete.(type) {
+		case bool:
+			result[logicalID] = v
+		case string:
+			result[logicalID] = strings.EqualFold(v, "true")
+		}
+	}
+	return result, nil
+}
+
+// 各リポジトリのイメージを全削除 (dryRun 時は削除対象数の表示のみ)。削除したイメージ数の合計を返す
+func emptyEcrRepositories(ctx context.Context, ecrClient ecrAPI, repos []ecrRepository, opts cleanupOptions) (int, error) {
+	var total int
+	var errs []error
+	for _, repo := range repos {
+		repoLog := loggerFrom(ctx).With("repository", repo.Name)
+		if repo.EmptyOnDelete {
+			repoLog.Infof("EmptyOnDelete is enabled, skipping")
+			continue
+		}
+		n, err := emptyEcrRepository(ctx, ecrClient, repo.Name, opts)
+		total += n
+		if err != nil {
+			repoLog.Errorf("Failed to delete images: %v", err)
+			errs = append(errs, fmt.Errorf("repository(%s): %w", repo.Name, err))
+			continue
+		}
+		if opts.DryRun {
+			repoLog.Infof("[DryRun] Would delete %d image(s)", n)
+		} else {
+			repoLog.Infof("Deleted %d image(s)", n)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// リポジトリ内の全イメージを digest 単位で削除
+func emptyEcrRepository(ctx context.Context, ecrClient ecrAPI, repo string, opts cleanupOptions) (int, error) {
+	// 削除中にページングが崩れないよう、先に全 digest を集める
+	var imageIDs []ecrtypes.ImageIdentifier
+	seen := map[string]bool{}
+	paginator := ecr.NewListImagesPaginator(ecrClient, &ecr.ListImagesInput{
+		RepositoryName: &repo,
+		MaxResults:     aws.Int32(1000),
+	})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, opts.Retry, "ListImages", func() (*ecr.ListImagesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			var notFound *ecrtypes.RepositoryNotFoundException
+			if errors.As(err, &notFound) {
+				loggerFrom(ctx).With("repository", repo).Infof("Repository does not exist, skipping")
+				return 0, nil
+			}
+			return 0, fmt.Errorf("ListImages error: %w", err)
+		}
+		for _, id := range page.ImageIds {
+			digest := aws.ToString(id.ImageDigest)
+			if digest == "" || seen[digest] {
+				continue
+			}
+			seen[digest] = true
+			imageIDs = append(imageIDs, ecrtypes.ImageIdentifier{ImageDigest: id.ImageDigest})
+		}
+	}
+	if opts.DryRun {
+		return len(imageIDs), nil
+	}
+
+	var deleted int
+	for start := 0; start < len(imageIDs); start += ecrDeleteBatchSize {
+		batch := imageIDs[start:min(start+ecrDeleteBatchSize, len(imageIDs))]
+		out, err := withRetry(ctx, opts.Retry, "BatchDeleteImage", func() (*ecr.BatchDeleteImageOutput, error) {
+			return ecrClient.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+				RepositoryName: &repo,
+				ImageIds:       batch,
+			})
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("BatchDeleteImage error: %w", err)
+		}
+		deleted += len(out.ImageIds)
+		for _, f := range out.Failures {
+			// 既に消えているイメージは問題ない
+			if f.FailureCode == ecrtypes.ImageFailureCodeImageNotFound {
+				continue
+			}
+			var digest string
+			if f.ImageId != nil {
+				digest = aws.ToString(f.ImageId.ImageDigest)
+			}
+			return deleted, fmt.Errorf("BatchDeleteImage failed for %s: %s %s",
+				digest, f.FailureCode, aws.ToString(f.FailureReason))
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,1092 @@
+package destroyer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// クラスターごとの処理件数
+type drainStats struct {
+	Services          int `json:"services"`
+	Tasks             int `json:"tasks"`
+	TaskDefinitions   int `json:"taskDefinitions"`
+	AutoScalingGroups int `json:"autoScalingGroups"`
+	ScheduledRules    int `json:"scheduledRules"`   // 無効化した EventBridge ルール
+	UnstableServices  int `json:"unstableServices"` // 安定を確認できないまま削除したサービス
+	NetworkInterfaces int `json:"networkInterfaces"`
+}
+
+// クラスターごとの処理結果 (--output のファイル用に対象の名前も残す)
+type clusterResult struct {
+	Name    string `json:"name"`
+	Skipped bool   `json:"skipped,omitempty"` // 削除済み・削除中のため処理しなかった
+	Deleted bool   `json:"deleted,omitempty"` // --delete-cluster で削除した (dry-run では削除する)
+	drainStats
+	ServiceResults []serviceResult `json:"serviceResults,omitempty"`
+	StoppedTasks   []string        `json:"stoppedTasks,omitempty"` // 停止した (dry-run では停止する) タスク ARN
+	StuckTasks     []string        `json:"stuckTasks,omitempty"`   // StopTask 後も止まらなかったタスク ARN
+	DisabledRules  []string        `json:"disabledRules,omitempty"`
+}
+
+// サービスごとの処理結果
+type serviceResult struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"` // dry-run では "not run"
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"-"` // Drain の結果用
+	err      error
+}
+
+func (s *drainStats) add(o drainStats) {
+	s.Services += o.Services
+	s.Tasks += o.Tasks
+	s.TaskDefinitions += o.TaskDefinitions
+	s.AutoScalingGroups += o.AutoScalingGroups
+	s.ScheduledRules += o.ScheduledRules
+	s.UnstableServices += o.UnstableServices
+	s.NetworkInterfaces += o.NetworkInterfaces
+}
+
+// クラスターのサービスを削除し、残りのタスクを停止 (処理した件数を返す)
+func drainCluster(ctx context.Context, clients awsClients, clusterName string, opts cleanupOptions) (result clusterResult, err error) {
+	ctx, span := startSpan(ctx, "cluster drain", clusterAttr(clusterName))
+	defer func() { endSpan(span, err) }()
+	ecsClient := clients.ECS
+	result = clusterResult{Name: clusterName}
+
+	// 削除済み・削除中のクラスターでは ListServices などが紛らわしい結果を返すので先に状態を見る
+	active, err := checkClusterStatus(ctx, ecsClient, clusterName, opts)
+	if err != nil {
+		return result, fmt.Errorf("failed to check the cluster status: %w", err)
+	}
+	if !active {
+		result.Skipped = true
+		return result, nil
+	}
+
+	// --tasks-only: サービスは (以前の実行などで) 削除済みとして、残ったタスクを止めるだけ
+	if opts.TasksOnly {
+		result.Tasks, result.StoppedTasks, result.StuckTasks, err = stopRemainingTasks(ctx, ecsClient, clusterName, opts)
+		if err != nil {
+			return result, fmt.Errorf("failed to stop tasks: %w", err)
+		}
+		return result, nil
+	}
+
+	// 新しいタスクが起動しないよう、先にスケジュールされたタスクのルールを無効化する
+	// 途中で失敗したらスタックは削除されないので元に戻す
+	if opts.DisableScheduledTasks {
+		result.DisabledRules, err = disableScheduledTaskRules(ctx, clients.Events, clusterName, opts)
+		result.ScheduledRules = len(result.DisabledRules)
+		defer func() {
+			if err == nil || opts.DryRun || len(result.DisabledRules) == 0 {
+				return
+			}
+			if enableErr := enableScheduledTaskRules(ctx, clients.Events, clusterName, result.DisabledRules, opts.Retry); enableErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to re-enable EventBridge rules: %w", enableErr))
+				return
+			}
+			result.ScheduledRules, result.DisabledRules = 0, nil
+		}()
+		if err != nil {
+			return result, fmt.Errorf("failed to disable EventBridge rules: %w", err)
+		}
+	}
+
+	// 削除前にサービスが使っているタスク定義を控えておく
+	var families []string
+	if opts.DeregisterTaskDefs {
+		families, err = listServiceTaskDefinitionFamilies(ctx, ecsClient, clusterName, opts)
+		if err != nil {
+			return result, fmt.Errorf("failed to list task definitions: %w", err)
+		}
+	}
+
+	// ここから先は --continue-on-error なら失敗しても次の段階に進む
+	errs := phaseErrors{continueOnError: opts.ContinueOnError}
+
+	// ECSサービスを停止・削除
+	result.ServiceResults, err = deleteEcsServices(ctx, ecsClient, clients.ELB, clients.AAS, clusterName, opts)
+	result.Services = len(result.ServiceResults)
+	for _, svc := range result.ServiceResults {
+		if svc.Status == statusUnconfirmed {
+			result.UnstableServices++
+		}
+	}
+	if err != nil && errs.add(fmt.Errorf("failed to delete ECS services: %w", err)) {
+		return result, errs.err()
+	}
+	// タスクを停止
+	result.Tasks, result.StoppedTasks, result.StuckTasks, err = stopRemainingTasks(ctx, ecsClient, clusterName, opts)
+	if err != nil && errs.add(fmt.Errorf("failed to stop tasks: %w", err)) {
+		return result, errs.err()
+	}
+	// EC2 キャパシティを 0 にする (残ったインスタンスがキャパシティプロバイダーの削除を妨げる)
+	if opts.ScaleDownASG {
+		result.AutoScalingGroups, err = scaleDownClusterASGs(ctx, ecsClient, clients.ASG, clusterName, opts)
+		if err != nil && errs.add(fmt.Errorf("failed to scale down Auto Scaling Groups: %w", err)) {
+			return result, errs.err()
+		}
+	}
+	// 停止したタスクの残った ENI を削除
+	if opts.CleanupENIs {
+		result.NetworkInterfaces, err = deleteOrphanedENIs(ctx, clients.EC2, clusterName, opts)
+		if err != nil && errs.add(fmt.Errorf("failed to delete network interfaces: %w", err)) {
+			return result, errs.err()
+		}
+	}
+	// タスク定義を登録解除
+	result.TaskDefinitions, err = deregisterTaskDefinitions(ctx, ecsClient, clusterName, families, opts)
+	if err != nil && errs.add(fmt.Errorf("failed to deregister task definitions: %w", err)) {
+		return result, errs.err()
+	}
+	return result, errs.err()
+}
+
+// ECSサービスを停止（DesiredCount=0）→ 削除 (dryRun 時は対象の表示のみ)
+// サービスごとの処理は opts.Concurrency 並列で実行し、失敗はまとめて返す
+func deleteEcsServices(ctx context.Context, ecsClient ecsAPI, elbClient elbv2API, aasClient appAutoscalingAPI, clusterName string, opts cleanupOptions) ([]serviceResult, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	serviceArns, err := targetServiceArns(ctx, ecsClient, clusterName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(serviceArns) == 0 {
+		clusterLog.Infof("No ECS services found in cluster: %s", clusterName)
+		return nil, nil
+	}
+	for _, svcArn := range serviceArns {
+		clusterLog.Debugf("Found service %s", svcArn)
+	}
+
+	// 別のクラスターのサービスの ARN (--service で指定されたもの) は、同じ名前のサービスと取り違えないよう触らない
+	var inCluster []string
+	for _, svcArn := range serviceArns {
+		if c := serviceArnCluster(svcArn); c != "" && c != arnToName(clusterName) {
+			clusterLog.With("service", arnToName(svcArn)).Warnf("Skipping service %s: it belongs to cluster %s", svcArn, c)
+			continue
+		}
+		inCluster = append(inCluster, svcArn)
+	}
+	serviceArns = inCluster
+	if len(serviceArns) == 0 {
+		clusterLog.Infof("No ECS services to delete in cluster: %s", clusterName)
+		return nil, nil
+	}
+
+	// --service-include / --service-exclude に合わないサービスは触らない
+	if opts.ServiceFilter.enabled() {
+		var selected []string
+		for _, svcArn := range serviceArns {
+			if opts.ServiceFilter.match(arnToName(svcArn)) {
+				selected = append(selected, svcArn)
+				continue
+			}
+			clusterLog.With("service", arnToName(svcArn)).Infof("Skipping service (filtered out by --service-include/--service-exclude)")
+		}
+		serviceArns = selected
+		if len(serviceArns) == 0 {
+			clusterLog.Infof("No ECS services to delete in cluster: %s", clusterName)
+			return nil, nil
+		}
+	}
+
+	if opts.DryRun {
+		action := "set desired count to 0 and delete"
+		if opts.DrainTargets {
+			action = "set desired count to 0, drain load balancer targets and delete"
+		}
+		if opts.StepDown {
+			action = fmt.Sprintf("step down desired count by %d every %s, then %s", opts.StepDownSize, opts.StepDownDelay, action)
+		}
+		if opts.RemoveAutoscaling {
+			action = "deregister the scalable target, " + action
+		}
+		results := make([]serviceResult, len(serviceArns))
+		for i, svcArn := range serviceArns {
+			clusterLog.With("service", arnToName(svcArn)).Infof("[DryRun] Would %s", action)
+			results[i] = serviceResult{Name: arnToName(svcArn), Status: statusNotRun}
+		}
+		return results, nil
+	}
+
+	// 結果は serviceArns の順に並べる (各要素は1つの goroutine だけが書く)
+	results := make([]serviceResult, len(serviceArns))
+	index := map[string]int{}
+	for i, svcArn := range serviceArns {
+		index[svcArn] = i
+	}
+	err = runConcurrently(serviceArns, opts.Concurrency, func(svcArn string) error {
+		svcName := arnToName(svcArn)
+		svcLog := clusterLog.With("service", svcName)
+		result := &results[index[svcArn]]
+		*result = serviceResult{Name: svcName, Status: statusSucceeded}
+		started := time.Now()
+		stable, err := deleteEcsService(ctx, svcLog, ecsClient, elbClient, aasClient, clusterName, svcArn, opts)
+		result.Duration = time.Since(started)
+		if err != nil {
+			svcLog.Errorf("%v", err)
+			result.Status, result.Error, result.err = statusFailed, err.Error(), err
+			return fmt.Errorf("service(%s): %w", svcName, err)
+		}
+		if !stable {
+			result.Status = statusUnconfirmed
+		}
+		return nil
+	})
+	return results, err
+}
+
+// クラスター内の全サービス ARN を取得 (全ページ分)
+// 処理対象のサービス (--service や --plan-in の計画で指定されていればそれだけで、クラスターの一覧は取らない)
+func targetServiceArns(ctx context.Context, ecsClient ecsAPI, clusterName string, opts cleanupOptions) ([]string, error) {
+	if opts.servicesSelected() {
+		return opts.Services, nil
+	}
+	return listServiceArns(ctx, ecsClient, clusterName, opts.Retry)
+}
+
+func listServiceArns(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) ([]string, error) {
+	var serviceArns []string
+	paginator := ecs.NewListServicesPaginator(ecsClient, &ecs.ListServicesInput{
+		Cluster:    &clusterName,
+		MaxResults: aws.Int32(100),
+	})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListServices", func() (*ecs.ListServicesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListServices error: %w", err)
+		}
+		serviceArns = append(serviceArns, page.ServiceArns...)
+	}
+	return serviceArns, nil
+}
+
+// 1サービス分の (スケーラブルターゲットの登録解除) → 停止（DesiredCount=0、--step-down なら段階的に）→ 安定待ち → (ターゲットの登録解除) → 削除
+// ECS の API には名前ではなく ARN (--service で名前を指定した場合は名前) をそのまま渡す
+// 安定を確認できたか (確認できなくても削除は試みる) を返す
+func deleteEcsService(ctx context.Context, svcLog appLogger, ecsClient ecsAPI, elbClient elbv2API, aasClient appAutoscalingAPI, clusterName, svcArn string, opts cleanupOptions) (stable bool, err error) {
+	ctx, span := startSpan(ctx, "service drain", clusterAttr(clusterName), serviceAttr(svcArn))
+	defer func() { endSpan(span, err) }()
+	// ターゲットグループはサービスを削除すると分からなくなるので先に控える
+	var targetGroups []string
+	if opts.DrainTargets {
+		targetGroups, err = serviceTargetGroups(ctx, ecsClient, clusterName, svcArn, opts.Retry)
+		if err != nil {
+			return false, fmt.Errorf("failed to get target groups: %w", err)
+		}
+	}
+
+	stopScaleDown := sync.OnceFunc(opts.Timings.start(phaseScaleDown))
+	defer stopScaleDown()
+
+	// スケーリングポリシーに DesiredCount を戻されないよう、先にスケーラブルターゲットを外す
+	if opts.RemoveAutoscaling {
+		if err := removeServiceAutoscaling(ctx, svcLog, aasClient, clusterName, arnToName(svcArn), opts); err != nil {
+			return false, fmt.Errorf("failed to remove auto scaling: %w", err)
+		}
+	}
+
+	// 一気に 0 にするとアラームが鳴るので、指定があれば段階的に減らす (失敗したら 0 にする)
+	if opts.StepDown {
+		if err := stepDownService(ctx, svcLog, ecsClient, clusterName, svcArn, opts); err != nil {
+			if isInterrupted(err) {
+				return false, err
+			}
+			if isServiceGoneError(err) {
+				svcLog.Debugf("Service already deleted, skipping: %v", err)
+				return true, nil
+			}
+			svcLog.Warnf("Step-down failed, scaling straight to 0: %v", err)
+		}
+	}
+
+	svcLog.Infof("Setting desired count to 0...")
+	_, err = withRetry(ctx, opts.Retry, "UpdateService", func() (*ecs.UpdateServiceOutput, error) {
+		return ecsClient.UpdateService(ctx, &ecs.UpdateServiceInput{
+			Cluster:      &clusterName,
+			Service:      &svcArn,
+			DesiredCount: aws.Int32(0),
+		})
+	})
+	if isServiceGoneError(err) {
+		svcLog.Debugf("Service already deleted, skipping: %v", err)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update desiredCount=0: %w", err)
+	}
+	stopScaleDown()
+
+	// 安定しなくても (--service-wait-timeout を過ぎても) Force で削除を試み、サマリーで分かるようにする
+	stable = true
+	stopWait := opts.Timings.start(phaseStabilityWait)
+	err = waitForServiceStable(ctx, ecsClient, clusterName, svcArn, opts.ServiceWaitTimeout, opts.ProgressInterval)
+	stopWait()
+	if err != nil {
+		if isInterrupted(err) {
+			return false, fmt.Errorf("scaled to 0 but not deleted: %w", err)
+		}
+		svcLog.Warnf("waitForServiceStable failed, deleting without confirmed stability: %v", err)
+		stable = false
+	}
+
+	// ターゲットが残っていても削除は試みる
+	if len(targetGroups) > 0 {
+		if err := drainTargetGroups(ctx, svcLog, elbClient, targetGroups, opts); err != nil {
+			if isInterrupted(err) {
+				return false, fmt.Errorf("scaled to 0 but not deleted: %w", err)
+			}
+			svcLog.Warnf("drainTargetGroups failed: %v", err)
+		}
+	}
+
+	defer opts.Timings.start(phaseServiceDelete)()
+	svcLog.Infof("Deleting...")
+	err = deleteService(ctx, svcLog, ecsClient, clusterName, svcArn, opts.Retry)
+	if isServiceGoneError(err) {
+		svcLog.Debugf("Service already deleted: %v", err)
+		return stable, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete: %w", err)
+	}
+
+	// Force で削除してもしばらく DRAINING で残り、cdk destroy と競合するので消えるまで待つ
+	if err := waitForServiceInactive(ctx, ecsClient, clusterName, svcArn, opts.ServiceWaitTimeout, opts.Retry); err != nil {
+		return false, fmt.Errorf("failed to wait for the service to become INACTIVE: %w", err)
+	}
+	svcLog.Debugf("Service is INACTIVE")
+	return stable, nil
+}
+
+// スケール中などで DeleteService が一時的に失敗したときに再試行する回数と間隔 (間隔はテストでは短くする)
+const deleteServiceRetries = 5
+
+var deleteServiceRetryInterval = 10 * time.Second
+
+// サービスを Force で削除する。スケールダウンが落ち着くまでの一時的なエラーの間は待って再試行する
+func deleteService(ctx context.Context, svcLog appLogger, ecsClient ecsAPI, clusterName, svcArn string, retry retryPolicy) error {
+	for attempt := 0; ; attempt++ {
+		_, err := withRetry(ctx, retry, "DeleteService", func() (*ecs.DeleteServiceOutput, error) {
+			return ecsClient.DeleteService(ctx, &ecs.DeleteServiceInput{
+				Cluster: &clusterName,
+				Service: &svcArn,
+				Force:   aws.Bool(true),
+			})
+		})
+		if !isTransientDeleteServiceError(err) || attempt >= deleteServiceRetries {
+			return err
+		}
+		svcLog.Warnf("DeleteService failed temporarily, retrying in %s (%d/%d): %v", deleteServiceRetryInterval, attempt+1, deleteServiceRetries, err)
+		select {
+		case <-ctx.Done():
+			return wrapCancelled(ctx, ctx.Err())
+		case <-time.After(deleteServiceRetryInterval):
+		}
+	}
+}
+
+// 待てば DeleteService が通るエラーか (スケール中・更新中・依存関係のエラー、ECS 側の障害)
+// 権限エラーや不正なパラメータなどはそのまま返す
+func isTransientDeleteServiceError(err error) bool {
+	var inUse *ecstypes.ResourceInUseException
+	var updating *ecstypes.UpdateInProgressException
+	var server *ecstypes.ServerException
+	if errors.As(err, &inUse) || errors.As(err, &updating) || errors.As(err, &server) {
+		return true
+	}
+	// "The service cannot be stopped while it is scaling." などは ClientException / InvalidParameterException で返る
+	var client *ecstypes.ClientException
+	var param *ecstypes.InvalidParameterException
+	var msg string
+	switch {
+	case errors.As(err, &client):
+		msg = client.ErrorMessage()
+	case errors.As(err, &param):
+		msg = param.ErrorMessage()
+	default:
+		return false
+	}
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "while it is scaling") || strings.Contains(msg, "dependency") || strings.Contains(msg, "dependent")
+}
+
+// ListServices の後に他のプロセス (CDK など) がサービスを削除した場合のエラーか
+// 削除中 (DRAINING) のサービスは ServiceNotActiveException になる
+func isServiceGoneError(err error) bool {
+	var notFound *ecstypes.ServiceNotFoundException
+	var notActive *ecstypes.ServiceNotActiveException
+	return errors.As(err, &notFound) || errors.As(err, &notActive)
+}
+
+// StopTask の Reason の最大長
+const maxStopReasonLen = 255
+
+// StopTask に渡す Reason を決める。未指定ならスタック名入りの既定値を使い、上限を超える分は切り詰める
+func stopTaskReason(reason, stackName string) string {
+	switch {
+	case reason == "" && stackName == "":
+		reason = "Cleanup before destroy"
+	case reason == "":
+		reason = fmt.Sprintf("Cleanup before destroy (stack: %s)", stackDisplayName(stackName))
+	}
+	if r := []rune(reason); len(r) > maxStopReasonLen {
+		reason = string(r[:maxStopReasonLen])
+	}
+	return reason
+}
+
+// クラスターに残っている RUNNING / PENDING のタスクを停止し、STOPPED になるまで待つ (dryRun 時は対象の表示のみ)
+// 見つかったタスク数・停止したタスク ARN・停止しなかったタスク ARN を返す
+// 待っても止まらないタスクは警告し、--force-stop なら StopTask をやり直してもう一度待つ
+func stopRemainingTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, opts cleanupOptions) (found int, stopped, stuck []string, err error) {
+	defer opts.Timings.start(phaseTaskStop)()
+	ctx, span := startSpan(ctx, phaseTaskStop, clusterAttr(clusterName))
+	defer func() { endSpan(span, err) }()
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	// --service (または計画) で指定されていれば、そのサービスのタスク (と計画にあるタスク) だけを止める
+	var taskArns []string
+	if opts.servicesSelected() {
+		for _, svc := range opts.Services {
+			arns, err := listActiveTaskArns(ctx, ecsClient, clusterName, arnToName(svc), opts.Retry)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			taskArns = append(taskArns, arns...)
+		}
+		if len(opts.Tasks) > 0 {
+			alive, err := listNotStoppedTasks(ctx, ecsClient, clusterName, opts.Tasks, opts.Retry)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			// 計画後に止まったタスクは alive に含まれない
+			for _, taskArn := range alive {
+				if !slices.Contains(taskArns, taskArn) {
+					taskArns = append(taskArns, taskArn)
+				}
+			}
+		}
+	} else {
+		taskArns, err = listActiveTaskArns(ctx, ecsClient, clusterName, "", opts.Retry)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if len(taskArns) == 0 {
+		clusterLog.Infof("No running or pending tasks in cluster: %s", clusterName)
+		return 0, nil, nil, nil
+	}
+	for _, taskArn := range taskArns {
+		clusterLog.Debugf("Found task %s", taskArn)
+	}
+
+	// 対象外のサービスのタスクや、--tasks-started-after より前から動いているタスクは止めない
+	if opts.ServiceFilter.enabled() || !opts.TasksStartedAfter.IsZero() {
+		taskArns, err = filterTasks(ctx, ecsClient, clusterName, taskArns, opts.ServiceFilter, opts.TasksStartedAfter, opts.Retry)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if len(taskArns) == 0 {
+			return 0, nil, nil, nil
+		}
+	}
+
+	if opts.DryRun {
+		for _, taskArn := range taskArns {
+			clusterLog.With("task", arnToName(taskArn)).Infof("[DryRun] Would stop task %s", taskArn)
+		}
+		return len(taskArns), taskArns, nil, nil
+	}
+
+	stopped, stopErr := stopTasks(ctx, ecsClient, clusterName, taskArns, opts)
+
+	// 停止できたタスクだけを待つ
+	var waitErr error
+	if len(stopped) > 0 {
+		clusterLog.Infof("Waiting for %d task(s) to stop...", len(stopped))
+		if err := waitForTasksStopped(ctx, ecsClient, clusterName, stopped, opts.TaskWaitTimeout, opts.ProgressInterval); err != nil {
+			if isInterrupted(err) {
+				return len(taskArns), stopped, nil, errors.Join(stopErr, err)
+			}
+			stuck, waitErr = retryStuckTasks(ctx, ecsClient, clusterName, stopped, opts)
+			if waitErr == nil && len(stuck) > 0 {
+				waitErr = fmt.Errorf("%d task(s) did not stop (cdk destroy would fail on them): %s", len(stuck), strings.Join(taskNames(stuck), ", "))
+			}
+		}
+	}
+	return len(taskArns), stopped, stuck, errors.Join(stopErr, waitErr)
+}
+
+// StopTask を opts.Concurrency 並列で実行し、停止を要求できたタスク ARN と失敗をまとめて返す
+func stopTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, taskArns []string, opts cleanupOptions) ([]string, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+	err := runConcurrently(taskArns, opts.Concurrency, func(taskArn string) error {
+		taskLog := clusterLog.With("task", arnToName(taskArn))
+		taskLog.Infof("Stopping...")
+		_, err := withRetry(ctx, opts.Retry, "StopTask", func() (*ecs.StopTaskOutput, error) {
+			return ecsClient.StopTask(ctx, &ecs.StopTaskInput{
+				Cluster: &clusterName,
+				Task:    &taskArn,
+				Reason:  aws.String(opts.StopReason),
+			})
+		})
+		if err != nil {
+			taskLog.Errorf("Failed to stop task: %v", err)
+			return fmt.Errorf("task(%s): %w", arnToName(taskArn), err)
+		}
+		mu.Lock()
+		stopped = append(stopped, taskArn)
+		mu.Unlock()
+		return nil
+	})
+	return stopped, err
+}
+
+// 待っても止まらなかったタスクを確認し、--force-stop なら停止をやり直してもう一度待つ
+// 最後まで止まらなかったタスク ARN を返す
+func retryStuckTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, taskArns []string, opts cleanupOptions) ([]string, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+	alive, err := listNotStoppedTasks(ctx, ecsClient, clusterName, taskArns, opts.Retry)
+	if err != nil {
+		return nil, err
+	}
+	if len(alive) == 0 {
+		return nil, nil
+	}
+	for _, taskArn := range alive {
+		clusterLog.With("task", arnToName(taskArn)).Warnf("Task is still running %s after StopTask", opts.TaskWaitTimeout)
+	}
+	if !opts.ForceStop {
+		clusterLog.Warnf("%d task(s) did not stop; use --force-stop to stop them again and wait longer", len(alive))
+		return alive, nil
+	}
+
+	clusterLog.Infof("--force-stop: stopping %d task(s) again...", len(alive))
+	stopped, stopErr := stopTasks(ctx, ecsClient, clusterName, alive, opts)
+	if len(stopped) > 0 {
+		if err := waitForTasksStopped(ctx, ecsClient, clusterName, stopped, opts.TaskWaitTimeout, opts.ProgressInterval); err != nil && isInterrupted(err) {
+			return alive, err
+		}
+	}
+	alive, err = listNotStoppedTasks(ctx, ecsClient, clusterName, alive, opts.Retry)
+	return alive, errors.Join(stopErr, err)
+}
+
+// タスク ARN をログ用の短い ID にする
+func taskNames(taskArns []string) []string {
+	names := make([]string, len(taskArns))
+	for i, taskArn := range taskArns {
+		names[i] = arnToName(taskArn)
+	}
+	return names
+}
+
+// DescribeTasks 1回で指定できる最大タスク数
+const describeTasksBatchSize = 100
+
+// 指定したタスクが全て STOPPED になるまで待機 (全体で最大 maxWait)
+func waitForTasksStopped(ctx context.Context, ecsClient ecsAPI, clusterName string, taskArns []string, maxWait, progressInterval time.Duration) error {
+	stop := startProgress(ctx, loggerFrom(ctx).With("cluster", clusterName), progressInterval, func(context.Context) string {
+		return fmt.Sprintf("waiting for %d task(s) to stop", len(taskArns))
+	})
+	defer stop()
+
+	waiter := ecs.NewTasksStoppedWaiter(ecsClient)
+	deadline := time.Now().Add(maxWait)
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		batch := taskArns[start:min(start+describeTasksBatchSize, len(taskArns))]
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("exceeded max wait time %s", maxWait)
+		}
+		input := &ecs.DescribeTasksInput{
+			Cluster: &clusterName,
+			Tasks:   batch,
+		}
+		if err := waiter.Wait(ctx, input, remaining); err != nil {
+			return wrapCancelled(ctx, err)
+		}
+	}
+	return nil
+}
+
+// クラスター内の実行中・起動中 (PENDING) のタスク ARN を取得 (全ページ分)
+// PENDING のタスクも ENI を持つのでサブネットの削除を妨げる
+// serviceName を指定するとそのサービスのタスクだけを返す (削除済みのサービスなら空)
+func listActiveTaskArns(ctx context.Context, ecsClient ecsAPI, clusterName, serviceName string, retry retryPolicy) ([]string, error) {
+	seen := map[string]bool{}
+	var taskArns []string
+	for _, status := range []ecstypes.DesiredStatus{ecstypes.DesiredStatusRunning, ecstypes.DesiredStatusPending} {
+		input := &ecs.ListTasksInput{
+			Cluster:       &clusterName,
+			DesiredStatus: status,
+		}
+		if serviceName != "" {
+			input.ServiceName = &serviceName
+		}
+		paginator := ecs.NewListTasksPaginator(ecsClient, input)
+		for paginator.HasMorePages() {
+			page, err := withRetry(ctx, retry, "ListTasks", func() (*ecs.ListTasksOutput, error) {
+				return paginator.NextPage(ctx)
+			})
+			if serviceName != "" && isServiceGoneError(err) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("ListTasks error: %w", err)
+			}
+			// 一覧の取得中に PENDING → RUNNING になったタスクを二重に数えない
+			for _, taskArn := range page.TaskArns {
+				if !seen[taskArn] {
+					seen[taskArn] = true
+					taskArns = append(taskArns, taskArn)
+				}
+			}
+		}
+	}
+	return taskArns, nil
+}
+
+// taskArns のうち、まだ STOPPED になっていないタスク ARN を返す
+func listNotStoppedTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, taskArns []string, retry retryPolicy) ([]string, error) {
+	var alive []string
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		batch := taskArns[start:min(start+describeTasksBatchSize, len(taskArns))]
+		out, err := withRetry(ctx, retry, "DescribeTasks", func() (*ecs.DescribeTasksOutput, error) {
+			return ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: &clusterName,
+				Tasks:   batch,
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeTasks error: %w", err)
+		}
+		for _, task := range out.Tasks {
+			if aws.ToString(task.LastStatus) != string(ecstypes.DesiredStatusStopped) {
+				alive = append(alive, aws.ToString(task.TaskArn))
+			}
+		}
+	}
+	return alive, nil
+}
+
+// クラスター内の実行中・起動中のタスクのうち、サービスに属さないもの (RunTask・スケジュールされたタスクなど) の ARN
+func listStandaloneTaskArns(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) ([]string, error) {
+	taskArns, err := listActiveTaskArns(ctx, ecsClient, clusterName, "", retry)
+	if err != nil {
+		return nil, err
+	}
+	standalone := []string{}
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		batch := taskArns[start:min(start+describeTasksBatchSize, len(taskArns))]
+		out, err := withRetry(ctx, retry, "DescribeTasks", func() (*ecs.DescribeTasksOutput, error) {
+			return ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: &clusterName,
+				Tasks:   batch,
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeTasks error: %w", err)
+		}
+		for _, task := range out.Tasks {
+			if !strings.HasPrefix(aws.ToString(task.Group), "service:") {
+				standalone = append(standalone, aws.ToString(task.TaskArn))
+			}
+		}
+	}
+	return standalone, nil
+}
+
+// ARN からリソース名を取り出す (ARN でなければそのまま名前として扱う)
+//   - ECS のサービス・タスク: 旧形式 service/<name>、新形式 service/<cluster>/<name> のどちらも末尾
+//   - ELB のターゲットグループ: targetgroup/<name>/<id> の <name>
+//   - それ以外: リソース部分の最後の "/" 以降
+func arnToName(s string) string {
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return lastPathSegment(s)
+	}
+	resourceType, path, ok := strings.Cut(parsed.Resource, "/")
+	if !ok {
+		return parsed.Resource
+	}
+	if parsed.Service == "elasticloadbalancing" && resourceType == "targetgroup" {
+		name, _, _ := strings.Cut(path, "/")
+		return name
+	}
+	return lastPathSegment(path)
+}
+
+// スタック内の ECS サービス ARN を取得 (AWS::ECS::Service の物理 ID はサービス ARN)
+func getEcsServiceArnsFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::ECS::Service", retry)
+}
+
+// スタックのサービスのうち --service で指定されたもの (指定が無ければ全て)
+func selectStackServices(stackServices, selected []string) []string {
+	if len(selected) == 0 {
+		return stackServices
+	}
+	var services []string
+	for _, svc := range stackServices {
+		if slices.ContainsFunc(selected, func(s string) bool { return arnToName(s) == arnToName(svc) }) {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// clusterName のサービスだけを返す (旧形式の ARN はクラスターが分からないので含める)
+func servicesInCluster(serviceArns []string, clusterName string) []string {
+	var services []string
+	for _, svc := range serviceArns {
+		if c := serviceArnCluster(svc); c == "" || c == arnToName(clusterName) {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// 新形式のサービス ARN (service/<cluster>/<name>) のクラスター名 (旧形式の ARN や名前なら空)
+func serviceArnCluster(s string) string {
+	parsed, err := arn.Parse(s)
+	if err != nil || parsed.Service != "ecs" {
+		return ""
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	if len(parts) != 3 || parts[0] != "service" {
+		return ""
+	}
+	return parts[1]
+}
+
+func lastPathSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// DesiredCount を opts.StepDownSize ずつ減らし、各段階で安定を待ってから opts.StepDownDelay 待つ
+// 最後の 0 への変更は呼び出し側で行う
+func stepDownService(ctx context.Context, svcLog appLogger, ecsClient ecsAPI, clusterName, svcArn string, opts cleanupOptions) error {
+	services, err := describeServices(ctx, ecsClient, clusterName, []string{svcArn}, opts.Retry)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	for count := services[0].DesiredCount - int32(opts.StepDownSize); count > 0; count -= int32(opts.StepDownSize) {
+		svcLog.Infof("Stepping down desired count to %d...", count)
+		_, err := withRetry(ctx, opts.Retry, "UpdateService", func() (*ecs.UpdateServiceOutput, error) {
+			return ecsClient.UpdateService(ctx, &ecs.UpdateServiceInput{
+				Cluster:      &clusterName,
+				Service:      &svcArn,
+				DesiredCount: aws.Int32(count),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update desiredCount=%d: %w", count, err)
+		}
+		if err := waitForServiceStable(ctx, ecsClient, clusterName, svcArn, opts.ServiceWaitTimeout, opts.ProgressInterval); err != nil {
+			return fmt.Errorf("desiredCount=%d: %w", count, err)
+		}
+		select {
+		case <-ctx.Done():
+			return wrapCancelled(ctx, ctx.Err())
+		case <-time.After(opts.StepDownDelay):
+		}
+	}
+	return nil
+}
+
+// サービスが STABLE になるまで待機 (最大 maxWait)。serviceName は ARN でもよい
+// サーキットブレーカーのロールバックを見つけたら、待たずに errDeploymentRolledBack を返す
+func waitForServiceStable(ctx context.Context, ecsClient ecsAPI, clusterName, serviceName string, maxWait, progressInterval time.Duration) error {
+	svcLog := loggerFrom(ctx).With("cluster", clusterName, "service", arnToName(serviceName))
+	stop := startProgress(ctx, svcLog, progressInterval, func(ctx context.Context) string {
+		return serviceProgress(ctx, ecsClient, clusterName, serviceName)
+	})
+	defer stop()
+
+	svcWaiter := ecs.NewServicesStableWaiter(ecsClient, func(o *ecs.ServicesStableWaiterOptions) {
+		retryable := o.Retryable
+		o.Retryable = func(ctx context.Context, in *ecs.DescribeServicesInput, out *ecs.DescribeServicesOutput, err error) (bool, error) {
+			if err == nil {
+				if rolledBack := rolledBackDeployment(out); rolledBack != nil {
+					return false, fmt.Errorf("%w: deployment %s%s", errDeploymentRolledBack,
+						lastPathSegment(aws.ToString(rolledBack.Id)), formatReason(rolledBack.RolloutStateReason))
+				}
+			}
+			return retryable(ctx, in, out, err)
+		}
+	})
+	input := &ecs.DescribeServicesInput{
+		Cluster:  &clusterName,
+		Services: []string{serviceName},
+	}
+	err := wrapCancelled(ctx, svcWaiter.Wait(ctx, input, maxWait))
+	if errors.Is(err, errDeploymentRolledBack) {
+		// 削除するサービスなので、ロールバックが終わるのを待たずに先へ進む
+		svcLog.Warnf("Deployment circuit breaker rollback detected, not waiting for the service to become stable: %v", err)
+	}
+	if err != nil && !isInterrupted(err) {
+		if reason := reportServiceInstability(ctx, svcLog, ecsClient, clusterName, serviceName); reason != "" {
+			err = fmt.Errorf("%w (%s)", err, reason)
+		}
+	}
+	return err
+}
+
+// デプロイサーキットブレーカーがデプロイを失敗としてロールバックした
+var errDeploymentRolledBack = errors.New("deployment circuit breaker rolled back")
+
+// rollout state が FAILED のデプロイ (サーキットブレーカーがロールバックした) があれば返す
+func rolledBackDeployment(out *ecs.DescribeServicesOutput) *ecstypes.Deployment {
+	for _, svc := range out.Services {
+		for i, d := range svc.Deployments {
+			if d.RolloutState == ecstypes.DeploymentRolloutStateFailed {
+				return &svc.Deployments[i]
+			}
+		}
+	}
+	return nil
+}
+
+// 安定しなかったときに表示するサービスイベントの件数
+const serviceEventsLimit = 5
+
+// 安定しなかったサービスのデプロイの状態と直近のイベントを表示する
+// PRIMARY のデプロイの rollout state (と理由) を返す (取得に失敗したら空)
+func reportServiceInstability(ctx context.Context, svcLog appLogger, ecsClient ecsAPI, clusterName, serviceName string) string {
+	out, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  &clusterName,
+		Services: []string{serviceName},
+	})
+	if err != nil || len(out.Services) == 0 {
+		svcLog.Debugf("Failed to describe the unstable service: %v", err)
+		return ""
+	}
+	svc := out.Services[0]
+	var reason string
+	for _, d := range svc.Deployments {
+		svcLog.Warnf("Deployment %s (%s): rollout %s, running %d, pending %d, desired %d, failed tasks %d, %s%s",
+			lastPathSegment(aws.ToString(d.Id)), aws.ToString(d.Status), cmp.Or(string(d.RolloutState), "-"),
+			d.RunningCount, d.PendingCount, d.DesiredCount, d.FailedTasks, arnToName(aws.ToString(d.TaskDefinition)),
+			formatReason(d.RolloutStateReason))
+		if aws.ToString(d.Status) == "PRIMARY" && d.RolloutState != "" {
+			reason = "deployment " + string(d.RolloutState) + formatReason(d.RolloutStateReason)
+		}
+	}
+	// イベントは新しい順に返る
+	events := svc.Events[:min(len(svc.Events), serviceEventsLimit)]
+	if len(events) > 0 {
+		svcLog.Warnf("Recent service events (latest first):")
+	}
+	for _, e := range events {
+		svcLog.Warnf("  %s %s", aws.ToTime(e.CreatedAt).Format(time.RFC3339), aws.ToString(e.Message))
+	}
+	return reason
+}
+
+func formatReason(reason *string) string {
+	if aws.ToString(reason) == "" {
+		return ""
+	}
+	return ": " + aws.ToString(reason)
+}
+
+// 削除したサービスの状態を確認する間隔 (テストでは短くする)
+var serviceInactivePollInterval = 5 * time.Second
+
+// 削除したサービスが INACTIVE になる (または見つからなくなる) まで待つ (maxWait を過ぎたらエラー)。serviceName は ARN でもよい
+func waitForServiceInactive(ctx context.Context, ecsClient ecsAPI, clusterName, serviceName string, maxWait time.Duration, retry retryPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	ticker := time.NewTicker(serviceInactivePollInterval)
+	defer ticker.Stop()
+	for {
+		out, err := withRetry(ctx, retry, "DescribeServices", func() (*ecs.DescribeServicesOutput, error) {
+			return ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Cluster:  &clusterName,
+				Services: []string{serviceName},
+			})
+		})
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return fmt.Errorf("DescribeServices error: %w", err)
+		case err == nil && (len(out.Services) == 0 || aws.ToString(out.Services[0].Status) == "INACTIVE"):
+			// 見つからないサービスは Failures (reason: MISSING) に入る
+			return nil
+		case err == nil:
+			loggerFrom(ctx).With("cluster", clusterName, "service", arnToName(serviceName)).Debugf("Service status: %s", aws.ToString(out.Services[0].Status))
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return fmt.Errorf("still DRAINING after %s", maxWait)
+			}
+			return wrapCancelled(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// "key=value" 形式のタグ指定を分解する (key が空なら ok=false)
+func parseTag(s string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	return key, strings.TrimSpace(value), ok && key != ""
+}
+
+// 指定したタグ (key=value) を持つ ECS クラスター名を取得 (スタック外で作られたクラスター用)
+func findClustersByTag(ctx context.Context, ecsClient ecsAPI, key, value string, retry retryPolicy) ([]string, error) {
+	var names []string
+	paginator := ecs.NewListClustersPaginator(ecsClient, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListClusters", func() (*ecs.ListClustersOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListClusters error: %w", err)
+		}
+		if len(page.ClusterArns) == 0 {
+			continue
+		}
+		// ListClusters の1ページ (最大100件) は DescribeClusters の上限内
+		out, err := withRetry(ctx, retry, "DescribeClusters", func() (*ecs.DescribeClustersOutput, error) {
+			return ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+				Clusters: page.ClusterArns,
+				Include:  []ecstypes.ClusterField{ecstypes.ClusterFieldTags},
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeClusters error: %w", err)
+		}
+		for _, c := range out.Clusters {
+			for _, t := range c.Tags {
+				if aws.ToString(t.Key) == key && aws.ToString(t.Value) == value {
+					names = append(names, aws.ToString(c.ClusterName))
+					break
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// 待機中の進捗表示用に、サービスの running / desired の数を取得 (失敗したら空文字)
+func serviceProgress(ctx context.Context, ecsClient ecsAPI, clusterName, serviceName string) string {
+	out, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  &clusterName,
+		Services: []string{serviceName},
+	})
+	if err != nil || len(out.Services) == 0 {
+		return ""
+	}
+	svc := out.Services[0]
+	return fmt.Sprintf("running %d, desired %d", svc.RunningCount, svc.DesiredCount)
+}
+
+// 削除中のクラスターの状態を確認する間隔
+const clusterPollInterval = 15 * time.Second
+
+// クラスターの状態 (ACTIVE / PROVISIONING / DEPROVISIONING / FAILED / INACTIVE)。存在しなければ空
+func describeClusterStatus(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) (string, error) {
+	out, err := withRetry(ctx, retry, "DescribeClusters", func() (*ecs.DescribeClustersOutput, error) {
+		return ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+			Clusters: []string{clusterName},
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("DescribeClusters error: %w", err)
+	}
+	// 存在しないクラスターは Failures (reason: MISSING) に入る
+	if len(out.Clusters) == 0 {
+		return "", nil
+	}
+	return aws.ToString(out.Clusters[0].Status), nil
+}
+
+// DEPROVISIONING のクラスターが INACTIVE になるまで待つ (maxWait を過ぎたらエラー)
+func waitForClusterInactive(ctx context.Context, ecsClient ecsAPI, clusterName string, maxWait time.Duration, retry retryPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	ticker := time.NewTicker(clusterPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return fmt.Errorf("exceeded max wait time %s", maxWait)
+			}
+			return wrapCancelled(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+		status, err := describeClusterStatus(ctx, ecsClient, clusterName, retry)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return err
+		case err == nil && (status == "" || status == "INACTIVE"):
+			return nil
+		case err == nil:
+			loggerFrom(ctx).With("cluster", clusterName).Debugf("Cluster status: %s", status)
+		}
+	}
+}
+
+// 以前の実行などで削除が始まっているクラスターは片付けられないので、状態を見て処理するか決める
+// 処理しない場合は false (理由はログに出す)
+func checkClusterStatus(ctx context.Context, ecsClient ecsAPI, clusterName string, opts cleanupOptions) (bool, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+	status, err := describeClusterStatus(ctx, ecsClient, clusterName, opts.Retry)
+	if err != nil {
+		return false, err
+	}
+	clusterLog.Infof("Cluster status: %s", cmp.Or(status, "not found"))
+	switch status {
+	case "":
+		clusterLog.Infof("Cluster %s does not exist (already deleted?), skipping", clusterName)
+		return false, nil
+	case "INACTIVE":
+		clusterLog.Infof("Cluster %s is already deleted (INACTIVE), skipping", clusterName)
+		return false, nil
+	case "DEPROVISIONING":
+		// 削除中のクラスターにはサービスもタスクも残っていないはずなので、削除の完了を待つだけ
+		if opts.DryRun {
+			clusterLog.Infof("[DryRun] Cluster %s is being deleted (DEPROVISIONING), would wait for it and skip", clusterName)
+			return false, nil
+		}
+		clusterLog.Infof("Cluster %s is being deleted (DEPROVISIONING), waiting for it to finish...", clusterName)
+		if err := waitForClusterInactive(ctx, ecsClient, clusterName, opts.ServiceWaitTimeout, opts.Retry); err != nil {
+			if isInterrupted(err) {
+				return false, err
+			}
+			clusterLog.Warnf("Cluster %s is still DEPROVISIONING (%v); skipping it, cdk destroy may fail until it finishes", clusterName, err)
+			return false, nil
+		}
+		clusterLog.Infof("Cluster %s has been deleted, skipping", clusterName)
+		return false, nil
+	}
+	return true, nil
+}
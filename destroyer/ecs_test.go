@@ -0,0 +1,404 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+// テスト用の cleanupOptions (待ち時間と再試行の間隔を短くする)
+func testCleanupOptions() cleanupOptions {
+	return cleanupOptions{
+		Concurrency:        4,
+		ServiceWaitTimeout: 5 * time.Second,
+		TaskWaitTimeout:    5 * time.Second,
+		Retry:              retryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}
+}
+
+func TestDeleteEcsServicesPaginates(t *testing.T) {
+	api := fakes.NewECS()
+	api.PageSize = 1
+	api.AddCluster("app")
+	api.AddService("app", "web", 2)
+	api.AddService("app", "worker", 1)
+
+	results, err := deleteEcsServices(context.Background(), api, nil, nil, "app", testCleanupOptions())
+	if err != nil {
+		t.Fatalf("deleteEcsServices: %v", err)
+	}
+	if got := api.CallCount("ListServices"); got != 2 {
+		t.Errorf("ListServices called %d times, want 2 (one per page)", got)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+		if r.Status != statusSucceeded {
+			t.Errorf("service %s: status %q, want %q", r.Name, r.Status, statusSucceeded)
+		}
+	}
+	if want := []string{"web", "worker"}; !slices.Equal(names, want) {
+		t.Errorf("results = %v, want %v", names, want)
+	}
+	var deleted []string
+	for _, c := range api.Calls("DeleteService") {
+		deleted = append(deleted, arnToName(aws.ToString(c.Input.(*ecs.DeleteServiceInput).Service)))
+	}
+	slices.Sort(deleted)
+	if want := []string{"web", "worker"}; !slices.Equal(deleted, want) {
+		t.Errorf("deleted services = %v, want %v", deleted, want)
+	}
+	for _, name := range []string{"web", "worker"} {
+		if svc, _ := api.Service("app", name); aws.ToString(svc.Status) != "INACTIVE" {
+			t.Errorf("service %s: status %s, want INACTIVE", name, aws.ToString(svc.Status))
+		}
+	}
+}
+
+func TestFindClustersByTagSecondPage(t *testing.T) {
+	api := fakes.NewECS()
+	api.PageSize = 1
+	api.AddCluster("a-other", ecstypes.Tag{Key: aws.String("app"), Value: aws.String("other")})
+	api.AddCluster("b-target", ecstypes.Tag{Key: aws.String("app"), Value: aws.String("demo")})
+
+	names, err := findClustersByTag(context.Background(), api, "app", "demo", testCleanupOptions().Retry)
+	if err != nil {
+		t.Fatalf("findClustersByTag: %v", err)
+	}
+	if want := []string{"b-target"}; !slices.Equal(names, want) {
+		t.Errorf("clusters = %v, want %v", names, want)
+	}
+	if got := api.CallCount("ListClusters"); got != 2 {
+		t.Errorf("ListClusters called %d times, want 2 (one per page)", got)
+	}
+}
+
+func TestDeleteEcsServicesConcurrencyLimit(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		api := fakes.NewECS()
+		api.Delay = 20 * time.Millisecond
+		api.AddCluster("app")
+		for i := range 8 {
+			api.AddService("app", fmt.Sprintf("svc-%d", i), 1)
+		}
+
+		opts := testCleanupOptions()
+		opts.Concurrency = limit
+		results, err := deleteEcsServices(context.Background(), api, nil, nil, "app", opts)
+		if err != nil {
+			t.Fatalf("limit %d: deleteEcsServices: %v", limit, err)
+		}
+		if len(results) != 8 || api.CallCount("DeleteService") != 8 {
+			t.Errorf("limit %d: deleted %d of 8 service(s)", limit, api.CallCount("DeleteService"))
+		}
+		if got := api.MaxInFlight("UpdateService"); got > limit {
+			t.Errorf("limit %d: peak in-flight UpdateService calls = %d, want <= %d", limit, got, limit)
+		}
+	}
+}
+
+func TestStopRemainingTasksWaitsForStoppedTasksOnly(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	ok1 := api.AddTask("app", ecstypes.Task{})
+	failing := api.AddTask("app", ecstypes.Task{})
+	ok2 := api.AddTask("app", ecstypes.Task{})
+	api.Err = func(op string, input any) error {
+		if in, ok := input.(*ecs.StopTaskInput); ok && aws.ToString(in.Task) == failing {
+			return &ecstypes.AccessDeniedException{Message: aws.String("denied")}
+		}
+		return nil
+	}
+
+	found, stopped, stuck, err := stopRemainingTasks(context.Background(), api, "app", testCleanupOptions())
+	if err == nil {
+		t.Error("stopRemainingTasks succeeded, want the StopTask error")
+	}
+	if found != 3 || len(stuck) != 0 {
+		t.Errorf("found %d, stuck %v; want 3 and none", found, stuck)
+	}
+	slices.Sort(stopped)
+	want := []string{ok1, ok2}
+	slices.Sort(want)
+	if !slices.Equal(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+
+	var waited []string
+	for _, c := range api.Calls("DescribeTasks") {
+		waited = append(waited, c.Input.(*ecs.DescribeTasksInput).Tasks...)
+	}
+	slices.Sort(waited)
+	if !slices.Equal(waited, want) {
+		t.Errorf("waited for %v, want %v (only the tasks StopTask accepted)", waited, want)
+	}
+}
+
+func TestStopTasksJoinsErrors(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	first := api.AddTask("app", ecstypes.Task{})
+	second := api.AddTask("app", ecstypes.Task{})
+	ok := api.AddTask("app", ecstypes.Task{})
+	api.Err = func(op string, input any) error {
+		if in, isStop := input.(*ecs.StopTaskInput); isStop && aws.ToString(in.Task) != ok {
+			return &ecstypes.AccessDeniedException{Message: aws.String("denied " + arnToName(aws.ToString(in.Task)))}
+		}
+		return nil
+	}
+
+	stopped, err := stopTasks(context.Background(), api, "app", []string{first, second, ok}, testCleanupOptions())
+	if !slices.Equal(stopped, []string{ok}) {
+		t.Errorf("stopped = %v, want [%s]", stopped, ok)
+	}
+	if err == nil {
+		t.Fatal("stopTasks succeeded, want both StopTask errors")
+	}
+	for _, taskArn := range []string{first, second} {
+		if !strings.Contains(err.Error(), "task("+arnToName(taskArn)+")") {
+			t.Errorf("error %q does not mention task %s", err, arnToName(taskArn))
+		}
+	}
+	var denied *ecstypes.AccessDeniedException
+	if !errors.As(err, &denied) {
+		t.Errorf("error %v does not wrap AccessDeniedException", err)
+	}
+}
+
+func TestStopRemainingTasksStopsPendingTasks(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	running := api.AddTask("app", ecstypes.Task{})
+	provisioning := api.AddTask("app", ecstypes.Task{LastStatus: aws.String("PENDING")})
+	pending := api.AddTask("app", ecstypes.Task{LastStatus: aws.String("PENDING"), DesiredStatus: aws.String("PENDING")})
+
+	found, stopped, _, err := stopRemainingTasks(context.Background(), api, "app", testCleanupOptions())
+	if err != nil {
+		t.Fatalf("stopRemainingTasks: %v", err)
+	}
+	if found != 3 || len(stopped) != 3 {
+		t.Errorf("found %d, stopped %d; want 3 and 3", found, len(stopped))
+	}
+	if got := api.CallCount("StopTask"); got != 3 {
+		t.Errorf("StopTask called %d times, want 3 (once per task)", got)
+	}
+	for _, taskArn := range []string{running, provisioning, pending} {
+		if task, _ := api.Task(taskArn); aws.ToString(task.LastStatus) != "STOPPED" {
+			t.Errorf("task %s: status %s, want STOPPED", arnToName(taskArn), aws.ToString(task.LastStatus))
+		}
+	}
+}
+
+func TestStopRemainingTasksStopsPlannedTasks(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	api.AddService("app", "web", 1)
+	planned := api.AddTask("app", ecstypes.Task{})
+	unplanned := api.AddTask("app", ecstypes.Task{})
+
+	// --plan-in: 計画にあるサービスとタスクだけを止め、計画後に起動したタスクは残す
+	opts := testCleanupOptions()
+	opts.Plan = &savedPlan{}
+	opts.Services, opts.Tasks = []string{"web"}, []string{planned}
+	found, stopped, _, err := stopRemainingTasks(context.Background(), api, "app", opts)
+	if err != nil {
+		t.Fatalf("stopRemainingTasks: %v", err)
+	}
+	if found != 2 || len(stopped) != 2 {
+		t.Errorf("found %d, stopped %d; want 2 and 2 (the service task and the planned task)", found, len(stopped))
+	}
+	if task, _ := api.Task(planned); aws.ToString(task.LastStatus) != "STOPPED" {
+		t.Errorf("planned task: status %s, want STOPPED", aws.ToString(task.LastStatus))
+	}
+	if task, _ := api.Task(unplanned); aws.ToString(task.LastStatus) != "RUNNING" {
+		t.Errorf("unplanned task: status %s, want RUNNING", aws.ToString(task.LastStatus))
+	}
+}
+
+func TestDeleteEcsServiceAlreadyDeleted(t *testing.T) {
+	notFound := &ecstypes.ServiceNotFoundException{Message: aws.String("Service not found.")}
+	tests := []struct {
+		name   string
+		failOp string // この操作を ServiceNotFoundException で失敗させる (空なら削除済みのサービス)
+	}{
+		{"deleted before UpdateService", ""},
+		{"deleted before DeleteService", "DeleteService"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := fakes.NewECS()
+			api.AddCluster("app")
+			svcArn := "arn:aws:ecs:us-east-1:123456789012:service/app/gone"
+			if tt.failOp != "" {
+				svcArn = api.AddService("app", "web", 1)
+				api.Err = func(op string, input any) error {
+					if op == tt.failOp {
+						return notFound
+					}
+					return nil
+				}
+			}
+
+			stable, err := deleteEcsService(context.Background(), logger, api, nil, nil, "app", svcArn, testCleanupOptions())
+			if err != nil || !stable {
+				t.Errorf("deleteEcsService = %v, %v; want true, nil", stable, err)
+			}
+			if tt.failOp == "" && api.CallCount("DeleteService") != 0 {
+				t.Errorf("DeleteService called for a deleted service")
+			}
+		})
+	}
+}
+
+func TestArnToName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"arn:aws:ecs:us-east-1:123456789012:service/web", "web"},
+		{"arn:aws:ecs:us-east-1:123456789012:service/app/web", "web"},
+		{"arn:aws:ecs:us-east-1:123456789012:task/0123456789abcdef", "0123456789abcdef"},
+		{"arn:aws:ecs:us-east-1:123456789012:task/app/0123456789abcdef", "0123456789abcdef"},
+		{"arn:aws:ecs:us-east-1:123456789012:cluster/app", "app"},
+		{"arn:aws:ecs:us-east-1:123456789012:task-definition/web:3", "web:3"},
+		{"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-tg/0123456789abcdef", "web-tg"},
+		{"arn:aws:sns:us-east-1:123456789012:alerts", "alerts"},
+		{"arn:aws-cn:ecs:cn-north-1:123456789012:service/app/web", "web"},
+		{"web", "web"},
+		{"app/web", "web"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := arnToName(tt.in); got != tt.want {
+			t.Errorf("arnToName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWaitForServiceInactiveWhileDraining(t *testing.T) {
+	defer func(d time.Duration) { serviceInactivePollInterval = d }(serviceInactivePollInterval)
+	serviceInactivePollInterval = time.Millisecond
+
+	api := fakes.NewECS()
+	api.DrainingDescribes = 2
+	api.AddCluster("app")
+	svcArn := api.AddService("app", "web", 0)
+	ctx := context.Background()
+	if _, err := api.DeleteService(ctx, &ecs.DeleteServiceInput{Cluster: aws.String("app"), Service: &svcArn}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := waitForServiceInactive(ctx, api, "app", svcArn, 5*time.Second, testCleanupOptions().Retry); err != nil {
+		t.Fatalf("waitForServiceInactive: %v", err)
+	}
+	// DRAINING を2回見てから INACTIVE を見る
+	if got := api.CallCount("DescribeServices"); got != 3 {
+		t.Errorf("DescribeServices called %d times, want 3", got)
+	}
+}
+
+func TestWaitForServiceInactiveTimeout(t *testing.T) {
+	defer func(d time.Duration) { serviceInactivePollInterval = d }(serviceInactivePollInterval)
+	serviceInactivePollInterval = time.Millisecond
+
+	api := fakes.NewECS()
+	api.DrainingDescribes = 1 << 30
+	api.AddCluster("app")
+	svcArn := api.AddService("app", "web", 0)
+	ctx := context.Background()
+	if _, err := api.DeleteService(ctx, &ecs.DeleteServiceInput{Cluster: aws.String("app"), Service: &svcArn}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := waitForServiceInactive(ctx, api, "app", svcArn, 20*time.Millisecond, testCleanupOptions().Retry)
+	if err == nil || !strings.Contains(err.Error(), "still DRAINING") {
+		t.Errorf("waitForServiceInactive = %v, want a still DRAINING error", err)
+	}
+}
+
+func TestDeleteEcsServicesSameNameInAnotherCluster(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("a")
+	api.AddCluster("b")
+	webA := api.AddService("a", "web", 1)
+	webB := api.AddService("b", "web", 1)
+
+	opts := testCleanupOptions()
+	opts.Services = []string{webA, webB}
+	results, err := deleteEcsServices(context.Background(), api, nil, nil, "a", opts)
+	if err != nil {
+		t.Fatalf("deleteEcsServices: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "web" || results[0].Status != statusSucceeded {
+		t.Errorf("results = %+v, want only web in cluster a", results)
+	}
+	for _, c := range api.Calls("") {
+		switch in := c.Input.(type) {
+		case *ecs.UpdateServiceInput:
+			if aws.ToString(in.Service) != webA {
+				t.Errorf("UpdateService called for %s", aws.ToString(in.Service))
+			}
+		case *ecs.DeleteServiceInput:
+			if aws.ToString(in.Service) != webA {
+				t.Errorf("DeleteService called for %s", aws.ToString(in.Service))
+			}
+		}
+	}
+	if svc, _ := api.Service("a", "web"); aws.ToString(svc.Status) != "INACTIVE" {
+		t.Errorf("a/web: status %s, want INACTIVE", aws.ToString(svc.Status))
+	}
+	if svc, _ := api.Service("b", "web"); aws.ToString(svc.Status) != "ACTIVE" || svc.DesiredCount != 1 {
+		t.Errorf("b/web: status %s, desired %d; want untouched", aws.ToString(svc.Status), svc.DesiredCount)
+	}
+}
+
+func TestDeleteServiceRetriesTransientErrors(t *testing.T) {
+	defer func(d time.Duration) { deleteServiceRetryInterval = d }(deleteServiceRetryInterval)
+	deleteServiceRetryInterval = time.Millisecond
+
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"scaling", &ecstypes.InvalidParameterException{Message: aws.String("The service cannot be stopped while it is scaling.")}, 2, 3, false},
+		{"update in progress", &ecstypes.UpdateInProgressException{Message: aws.String("update in progress")}, 1, 2, false},
+		{"too many failures", &ecstypes.ServerException{Message: aws.String("internal error")}, deleteServiceRetries + 1, deleteServiceRetries + 1, true},
+		{"not transient", &ecstypes.AccessDeniedException{Message: aws.String("denied")}, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := fakes.NewECS()
+			api.AddCluster("app")
+			svcArn := api.AddService("app", "web", 0)
+			var failures int
+			api.Err = func(op string, input any) error {
+				if op == "DeleteService" && failures < tt.failures {
+					failures++
+					return tt.err
+				}
+				return nil
+			}
+
+			ctx := context.Background()
+			err := deleteService(ctx, loggerFrom(ctx), api, "app", svcArn, testCleanupOptions().Retry)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deleteService = %v, want error %v", err, tt.wantErr)
+			}
+			if got := api.CallCount("DeleteService"); got != tt.wantCalls {
+				t.Errorf("DeleteService called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
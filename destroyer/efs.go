@@ -0,0 +1,147 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+)
+
+// マウントターゲットの削除を確認する間隔と、待つ時間の上限
+const (
+	efsPollInterval   = 5 * time.Second
+	efsDeleteWaitTime = 10 * time.Minute
+)
+
+// スタック内の EFS マウントターゲット ID を取得
+// スタックのファイルシステムにスタック外で作られたマウントターゲットがあれば、それも削除対象に含める
+func getEfsMountTargetsFromStack(ctx context.Context, cfnClient cfnAPI, efsClient efsAPI, stackName string, retry retryPolicy) ([]string, error) {
+	mountTargets, err := listStackResourceIDs(ctx, cfnClient, stackName, "AWS::EFS::MountTarget", retry)
+	if err != nil {
+		return nil, err
+	}
+	fileSystems, err := listStackResourceIDs(ctx, cfnClient, stackName, "AWS::EFS::FileSystem", retry)
+	if err != nil {
+		return nil, err
+	}
+	for _, fsID := range fileSystems {
+		ids, err := listMountTargets(ctx, efsClient, fsID, retry)
+		if err != nil {
+			return nil, fmt.Errorf("file system(%s): %w", fsID, err)
+		}
+		for _, id := range ids {
+			if !slices.Contains(mountTargets, id) {
+				mountTargets = append(mountTargets, id)
+			}
+		}
+	}
+	return mountTargets, nil
+}
+
+// ファイルシステムのマウントターゲット ID (全ページ分、ファイルシステムが無ければ空)
+func listMountTargets(ctx context.Context, efsClient efsAPI, fileSystemID string, retry retryPolicy) ([]string, error) {
+	var ids []string
+	paginator := efs.NewDescribeMountTargetsPaginator(efsClient, &efs.DescribeMountTargetsInput{FileSystemId: &fileSystemID})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "DescribeMountTargets", func() (*efs.DescribeMountTargetsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			var notFound *efstypes.FileSystemNotFound
+			if errors.As(err, &notFound) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("DescribeMountTargets error: %w", err)
+		}
+		for _, mt := range page.MountTargets {
+			if mt.LifeCycleState != efstypes.LifeCycleStateDeleted {
+				ids = append(ids, *mt.MountTargetId)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// マウントターゲットを削除し、消えるまで待つ (dryRun 時は対象の表示のみ)。削除した数を返す
+// タスクが使っている間は削除できないので、クラスターを空にした後に呼ぶ
+func deleteEfsMountTargets(ctx context.Context, efsClient efsAPI, mountTargetIDs []string, opts cleanupOptions) (int, error) {
+	var deleted []string
+	var errs []error
+	for _, id := range mountTargetIDs {
+		mtLog := loggerFrom(ctx).With("mountTarget", id)
+		if opts.DryRun {
+			mtLog.Infof("[DryRun] Would delete EFS mount target")
+			deleted = append(deleted, id)
+			continue
+		}
+		_, err := withRetry(ctx, opts.Retry, "DeleteMountTarget", func() (*efs.DeleteMountTargetOutput, error) {
+			return efsClient.DeleteMountTarget(ctx, &efs.DeleteMountTargetInput{MountTargetId: &id})
+		})
+		if err != nil {
+			var notFound *efstypes.MountTargetNotFound
+			if errors.As(err, &notFound) {
+				mtLog.Infof("Mount target does not exist, skipping")
+				continue
+			}
+			if isInterrupted(err) {
+				return len(deleted), err
+			}
+			mtLog.Errorf("Failed to delete mount target: %v", err)
+			errs = append(errs, fmt.Errorf("mount target(%s): %w", id, err))
+			continue
+		}
+		mtLog.Infof("Deleting EFS mount target...")
+		deleted = append(deleted, id)
+	}
+	if opts.DryRun || len(deleted) == 0 {
+		return len(deleted), errors.Join(errs...)
+	}
+
+	// 削除は非同期なので、消えるまで待たないと cdk destroy でサブネットやセキュリティグループの削除に失敗する
+	for _, id := range deleted {
+		if err := waitForMountTargetDeleted(ctx, efsClient, id, opts.Retry); err != nil {
+			if isInterrupted(err) {
+				return len(deleted), err
+			}
+			errs = append(errs, fmt.Errorf("mount target(%s): %w", id, err))
+			continue
+		}
+		loggerFrom(ctx).With("mountTarget", id).Infof("Deleted EFS mount target")
+	}
+	return len(deleted), errors.Join(errs...)
+}
+
+// マウントターゲットが見つからなくなる (deleted になる) まで待つ (efsDeleteWaitTime を過ぎたらエラー)
+func waitForMountTargetDeleted(ctx context.Context, efsClient efsAPI, mountTargetID string, retry retryPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, efsDeleteWaitTime)
+	defer cancel()
+
+	ticker := time.NewTicker(efsPollInterval)
+	defer ticker.Stop()
+	for {
+		out, err := withRetry(ctx, retry, "DescribeMountTargets", func() (*efs.DescribeMountTargetsOutput, error) {
+			return efsClient.DescribeMountTargets(ctx, &efs.DescribeMountTargetsInput{MountTargetId: &mountTargetID})
+		})
+		var notFound *efstypes.MountTargetNotFound
+		switch {
+		case errors.As(err, &notFound):
+			return nil
+		case err != nil && ctx.Err() == nil:
+			return fmt.Errorf("DescribeMountTargets error: %w", err)
+		case err == nil && (len(out.MountTargets) == 0 || out.MountTargets[0].LifeCycleState == efstypes.LifeCycleStateDeleted):
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return fmt.Errorf("still not deleted after %s", efsDeleteWaitTime)
+			}
+			return wrapCancelled(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// ENI がまだ使用中 (デタッチ中) のときに削除を再試行する回数と間隔
+const (
+	eniInUseRetries  = 5
+	eniRetryInterval = 10 * time.Second
+)
+
+// ECS が付けるクラスター名のタグ (Fargate タスクの ENI に付く)
+const ecsClusterNameTag = "aws:ecs:clusterName"
+
+// クラスターのタスクが使っていた、未使用 (available) の ENI の ID を取得 (全ページ分)
+// クラスター名のタグが付いたものだけを対象にする
+func listOrphanedClusterENIs(ctx context.Context, ec2Client ec2API, clusterName string, retry retryPolicy) ([]string, error) {
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(ec2Client, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("status"), Values: []string{string(ec2types.NetworkInterfaceStatusAvailable)}},
+			{Name: aws.String("tag:" + ecsClusterNameTag), Values: []string{clusterName}},
+		},
+	})
+	var ids []string
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "DescribeNetworkInterfaces", func() (*ec2.DescribeNetworkInterfacesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeNetworkInterfaces error: %w", err)
+		}
+		for _, eni := range page.NetworkInterfaces {
+			ids = append(ids, aws.ToString(eni.NetworkInterfaceId))
+		}
+	}
+	return ids, nil
+}
+
+// 停止したタスクの ENI を削除する (dryRun 時は対象の表示のみ)。削除した ENI 数を返す
+// 残った ENI がセキュリティグループやサブネットの削除を妨げる
+func deleteOrphanedENIs(ctx context.Context, ec2Client ec2API, clusterName string, opts cleanupOptions) (int, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	ids, err := listOrphanedClusterENIs(ctx, ec2Client, clusterName, opts.Retry)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		clusterLog.Infof("No orphaned ENIs for cluster: %s", clusterName)
+		return 0, nil
+	}
+
+	var deleted int
+	var errs []error
+	for _, id := range ids {
+		eniLog := clusterLog.With("eni", id)
+		if opts.DryRun {
+			eniLog.Infof("[DryRun] Would delete network interface")
+			deleted++
+			continue
+		}
+		err := deleteNetworkInterface(ctx, ec2Client, id, opts.Retry)
+		switch {
+		case isEC2ErrorCode(err, "InvalidNetworkInterfaceID.NotFound"):
+			eniLog.Debugf("Network interface already deleted")
+		case err != nil:
+			eniLog.Errorf("Failed to delete network interface: %v", err)
+			errs = append(errs, fmt.Errorf("eni(%s): %w", id, err))
+		default:
+			eniLog.Infof("Deleted network interface")
+			deleted++
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// ENI を削除する。使用中 (InvalidNetworkInterface.InUse) の間は待って再試行する
+func deleteNetworkInterface(ctx context.Context, ec2Client ec2API, id string, retry retryPolicy) error {
+	for attempt := 0; ; attempt++ {
+		_, err := withRetry(ctx, retry, "DeleteNetworkInterface", func() (*ec2.DeleteNetworkInterfaceOutput, error) {
+			return ec2Client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{
+				NetworkInterfaceId: aws.String(id),
+			})
+		})
+		if !isEC2ErrorCode(err, "InvalidNetworkInterface.InUse") || attempt >= eniInUseRetries {
+			return err
+		}
+		loggerFrom(ctx).With("eni", id).Debugf("Network interface still in use, retrying in %s (%d/%d)", eniRetryInterval, attempt+1, eniInUseRetries)
+		select {
+		case <-ctx.Done():
+			return wrapCancelled(ctx, ctx.Err())
+		case <-time.After(eniRetryInterval):
+		}
+	}
+}
+
+// EC2 の API エラーコードの判定 (EC2 は型付きのエラーを返さない)
+func isEC2ErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
@@ -0,0 +1,41 @@
+package destroyer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// プロセスの終了コード (Run が返すエラーから ExitCode で求める)
+const (
+	ExitOK            = 0
+	ExitFailure       = 1 // 上記以外のエラー (ユーザーによる中断など)
+	ExitCleanupFailed = 2 // AWS 側のクリーンアップ (探索・削除) に失敗
+	ExitDestroyFailed = 3 // cdk destroy に失敗
+	ExitInvalidFlags  = 4 // フラグ (Config) の指定が不正
+)
+
+// 終了コード付きのエラー
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// 終了コード付きのエラーを作成
+func exitErrorf(code int, format string, args ...any) error {
+	return &exitError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// ExitCode はエラーに対応する終了コードを返す (nil なら ExitOK、終了コードの無いエラーは ExitFailure)
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return ExitFailure
+}
@@ -0,0 +1,24 @@
+package destroyer
+
+import "errors"
+
+// 処理段階の失敗を集める
+// --continue-on-error なら失敗しても次の段階に進み、そうでなければ最初の失敗で止める (中断は常に止める)
+type phaseErrors struct {
+	continueOnError bool
+	errs            []error
+}
+
+// 失敗を記録し、ここで処理を止めるべきかを返す (err が nil なら何もしない)
+func (p *phaseErrors) add(err error) (stop bool) {
+	if err == nil {
+		return false
+	}
+	p.errs = append(p.errs, err)
+	return !p.continueOnError || isInterrupted(err)
+}
+
+// 記録した失敗をまとめたエラー (無ければ nil)
+func (p *phaseErrors) err() error {
+	return errors.Join(p.errs...)
+}
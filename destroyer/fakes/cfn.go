@@ -0,0 +1,270 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// CFN はスタックとそのリソース・テンプレート・イベントをメモリ上に持つ CloudFormation
+// DeleteStack したスタックはすぐに DELETE_COMPLETE になる
+type CFN struct {
+	recorder
+	// PageSize は ListStackResources・DescribeStackEvents の1ページの最大件数 (0 なら API の既定)
+	PageSize int
+
+	mu     sync.Mutex
+	stacks []*cfnStack
+}
+
+type cfnStack struct {
+	stack     cfntypes.Stack
+	resources []cfntypes.StackResourceSummary
+	template  string
+	events    []cfntypes.StackEvent
+}
+
+// NewCFN は空の CFN を返す
+func NewCFN() *CFN {
+	return &CFN{}
+}
+
+// AddStack は CREATE_COMPLETE のスタックを作り、その ID (ARN) を返す
+func (f *CFN) AddStack(name string, tags ...cfntypes.Tag) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := arnOf("cloudformation", fmt.Sprintf("stack/%s/%08d-0000-0000-0000-000000000000", name, len(f.stacks)+1))
+	f.stacks = append(f.stacks, &cfnStack{stack: cfntypes.Stack{
+		StackName:    aws.String(name),
+		StackId:      aws.String(id),
+		StackStatus:  cfntypes.StackStatusCreateComplete,
+		CreationTime: aws.Time(time.Now()),
+		Tags:         tags,
+	}})
+	return id
+}
+
+// AddResource はスタックに CREATE_COMPLETE のリソースを加える
+func (f *CFN) AddResource(stack, logicalID, resourceType, physicalID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.mustStack(stack)
+	s.resources = append(s.resources, cfntypes.StackResourceSummary{
+		LogicalResourceId:  aws.String(logicalID),
+		PhysicalResourceId: aws.String(physicalID),
+		ResourceType:       aws.String(resourceType),
+		ResourceStatus:     cfntypes.ResourceStatusCreateComplete,
+	})
+}
+
+// SetTemplate は GetTemplate が返すテンプレートの本文を設定する
+func (f *CFN) SetTemplate(stack, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mustStack(stack).template = body
+}
+
+// SetStatus はスタックの状態 (UPDATE_IN_PROGRESS など) を変える
+func (f *CFN) SetStatus(stack string, status cfntypes.StackStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mustStack(stack).stack.StackStatus = status
+}
+
+// SetTerminationProtection はスタックの削除保護を設定する
+func (f *CFN) SetTerminationProtection(stack string, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mustStack(stack).stack.EnableTerminationProtection = aws.Bool(enabled)
+}
+
+// AddEvent はスタックのイベントを加える (DescribeStackEvents は新しいものから返す。EventId は上書きする)
+func (f *CFN) AddEvent(stack string, event cfntypes.StackEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.mustStack(stack)
+	// 新しいものほど小さい ID にして、ID の順に並べれば新しいものから返るようにする
+	event.EventId = aws.String(fmt.Sprintf("%08d", 99999999-len(s.events)))
+	event.StackName, event.StackId = s.stack.StackName, s.stack.StackId
+	s.events = append(s.events, event)
+}
+
+// Stack はスタックの現在の状態を返す
+func (f *CFN) Stack(stack string) (cfntypes.Stack, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s := f.find(stack); s != nil {
+		return s.stack, true
+	}
+	return cfntypes.Stack{}, false
+}
+
+// 名前か ID でスタックを探す (削除済みは名前では見つからない)
+func (f *CFN) find(id string) *cfnStack {
+	for i := len(f.stacks) - 1; i >= 0; i-- {
+		s := f.stacks[i]
+		if aws.ToString(s.stack.StackId) == id ||
+			aws.ToString(s.stack.StackName) == id && s.stack.StackStatus != cfntypes.StackStatusDeleteComplete {
+			return s
+		}
+	}
+	return nil
+}
+
+func (f *CFN) mustStack(id string) *cfnStack {
+	s := f.find(id)
+	if s == nil {
+		panic(fmt.Sprintf("fakes: stack %s does not exist", id))
+	}
+	return s
+}
+
+// API の StackName の値のスタック (無ければ ValidationError)
+func (f *CFN) stackFor(id *string) (*cfnStack, error) {
+	if s := f.find(aws.ToString(id)); s != nil {
+		return s, nil
+	}
+	return nil, &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: fmt.Sprintf("Stack with id %s does not exist", aws.ToString(id)),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func (f *CFN) DescribeStacks(ctx context.Context, params *cfn.DescribeStacksInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStacksOutput, error) {
+	if err := f.call(ctx, "DescribeStacks", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if params.StackName == nil {
+		out := &cfn.DescribeStacksOutput{}
+		for _, s := range f.stacks {
+			if s.stack.StackStatus != cfntypes.StackStatusDeleteComplete {
+				out.Stacks = append(out.Stacks, s.stack)
+			}
+		}
+		return out, nil
+	}
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	return &cfn.DescribeStacksOutput{Stacks: []cfntypes.Stack{s.stack}}, nil
+}
+
+func (f *CFN) UpdateTerminationProtection(ctx context.Context, params *cfn.UpdateTerminationProtectionInput, optFns ...func(*cfn.Options)) (*cfn.UpdateTerminationProtectionOutput, error) {
+	if err := f.call(ctx, "UpdateTerminationProtection", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	s.stack.EnableTerminationProtection = aws.Bool(aws.ToBool(params.EnableTerminationProtection))
+	return &cfn.UpdateTerminationProtectionOutput{StackId: s.stack.StackId}, nil
+}
+
+func (f *CFN) ListStackResources(ctx context.Context, params *cfn.ListStackResourcesInput, optFns ...func(*cfn.Options)) (*cfn.ListStackResourcesOutput, error) {
+	if err := f.call(ctx, "ListStackResources", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	resources, next := page(s.resources, func(r cfntypes.StackResourceSummary) string { return aws.ToString(r.LogicalResourceId) },
+		params.NextToken, pageSize(f.PageSize, nil, 100))
+	return &cfn.ListStackResourcesOutput{StackResourceSummaries: resources, NextToken: next}, nil
+}
+
+func (f *CFN) DescribeStackResource(ctx context.Context, params *cfn.DescribeStackResourceInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackResourceOutput, error) {
+	if err := f.call(ctx, "DescribeStackResource", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range s.resources {
+		if aws.ToString(r.LogicalResourceId) == aws.ToString(params.LogicalResourceId) {
+			return &cfn.DescribeStackResourceOutput{StackResourceDetail: &cfntypes.StackResourceDetail{
+				LogicalResourceId:  r.LogicalResourceId,
+				PhysicalResourceId: r.PhysicalResourceId,
+				ResourceType:       r.ResourceType,
+				ResourceStatus:     r.ResourceStatus,
+				StackName:          s.stack.StackName,
+				StackId:            s.stack.StackId,
+			}}, nil
+		}
+	}
+	return nil, &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: fmt.Sprintf("Resource %s does not exist for stack %s", aws.ToString(params.LogicalResourceId), aws.ToString(params.StackName)),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func (f *CFN) GetTemplate(ctx context.Context, params *cfn.GetTemplateInput, optFns ...func(*cfn.Options)) (*cfn.GetTemplateOutput, error) {
+	if err := f.call(ctx, "GetTemplate", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	return &cfn.GetTemplateOutput{TemplateBody: aws.String(s.template)}, nil
+}
+
+func (f *CFN) DescribeStackEvents(ctx context.Context, params *cfn.DescribeStackEventsInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackEventsOutput, error) {
+	if err := f.call(ctx, "DescribeStackEvents", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.stackFor(params.StackName)
+	if err != nil {
+		return nil, err
+	}
+	events, next := page(s.events, func(e cfntypes.StackEvent) string { return aws.ToString(e.EventId) },
+		params.NextToken, pageSize(f.PageSize, nil, 100))
+	return &cfn.DescribeStackEventsOutput{StackEvents: events, NextToken: next}, nil
+}
+
+// 削除保護が有効なら ValidationError で失敗する
+func (f *CFN) DeleteStack(ctx context.Context, params *cfn.DeleteStackInput, optFns ...func(*cfn.Options)) (*cfn.DeleteStackOutput, error) {
+	if err := f.call(ctx, "DeleteStack", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.find(aws.ToString(params.StackName))
+	if s == nil {
+		// 存在しないスタックの削除は成功する
+		return &cfn.DeleteStackOutput{}, nil
+	}
+	if aws.ToBool(s.stack.EnableTerminationProtection) {
+		return nil, &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: fmt.Sprintf("Stack [%s] cannot be deleted while TerminationProtection is enabled", aws.ToString(s.stack.StackName)),
+			Fault:   smithy.FaultClient,
+		}
+	}
+	s.stack.StackStatus = cfntypes.StackStatusDeleteComplete
+	s.stack.DeletionTime = aws.Time(time.Now())
+	return &cfn.DeleteStackOutput{}, nil
+}
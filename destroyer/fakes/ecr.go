@@ -0,0 +1,110 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// ECR はリポジトリとそのイメージをメモリ上に持つ ECR
+type ECR struct {
+	recorder
+	// PageSize は ListImages の1ページの最大件数 (0 なら MaxResults か API の既定)
+	PageSize int
+
+	mu     sync.Mutex
+	repos  map[string][]ecrtypes.ImageIdentifier
+	nextID int
+}
+
+// NewECR は空の ECR を返す
+func NewECR() *ECR {
+	return &ECR{repos: map[string][]ecrtypes.ImageIdentifier{}}
+}
+
+// AddRepository は空のリポジトリを作る
+func (f *ECR) AddRepository(repo string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.repos[repo]; !ok {
+		f.repos[repo] = nil
+	}
+}
+
+// PutImage はタグ (空ならタグ無し) の付いたイメージを作り、そのダイジェストを返す (リポジトリが無ければ作る)
+func (f *ECR) PutImage(repo, tag string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	digest := fmt.Sprintf("sha256:%064x", f.nextID)
+	id := ecrtypes.ImageIdentifier{ImageDigest: aws.String(digest)}
+	if tag != "" {
+		id.ImageTag = aws.String(tag)
+	}
+	f.repos[repo] = append(f.repos[repo], id)
+	return digest
+}
+
+// Images はリポジトリに残っているイメージの数
+func (f *ECR) Images(repo string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.repos[repo])
+}
+
+func repositoryNotFound(repo string) error {
+	return &ecrtypes.RepositoryNotFoundException{Message: aws.String(fmt.Sprintf("The repository with name '%s' does not exist in the registry with id '%s'", repo, Account))}
+}
+
+func (f *ECR) ListImages(ctx context.Context, params *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	if err := f.call(ctx, "ListImages", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	images, ok := f.repos[aws.ToString(params.RepositoryName)]
+	if !ok {
+		return nil, repositoryNotFound(aws.ToString(params.RepositoryName))
+	}
+	images, next := page(images, func(id ecrtypes.ImageIdentifier) string { return aws.ToString(id.ImageDigest) },
+		params.NextToken, pageSize(f.PageSize, params.MaxResults, 100))
+	return &ecr.ListImagesOutput{ImageIds: images, NextToken: next}, nil
+}
+
+// ダイジェストかタグの一致するイメージを消す。見つからないものは ImageNotFound の失敗になる
+func (f *ECR) BatchDeleteImage(ctx context.Context, params *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	if err := f.call(ctx, "BatchDeleteImage", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	repo := aws.ToString(params.RepositoryName)
+	images, ok := f.repos[repo]
+	if !ok {
+		return nil, repositoryNotFound(repo)
+	}
+	out := &ecr.BatchDeleteImageOutput{}
+	for _, id := range params.ImageIds {
+		i := slices.IndexFunc(images, func(img ecrtypes.ImageIdentifier) bool {
+			return id.ImageDigest != nil && aws.ToString(img.ImageDigest) == *id.ImageDigest ||
+				id.ImageDigest == nil && id.ImageTag != nil && aws.ToString(img.ImageTag) == *id.ImageTag
+		})
+		if i < 0 {
+			out.Failures = append(out.Failures, ecrtypes.ImageFailure{
+				ImageId:       &id,
+				FailureCode:   ecrtypes.ImageFailureCodeImageNotFound,
+				FailureReason: aws.String("Requested image not found"),
+			})
+			continue
+		}
+		out.ImageIds = append(out.ImageIds, images[i])
+		images = slices.Delete(images, i, i+1)
+	}
+	f.repos[repo] = images
+	return out, nil
+}
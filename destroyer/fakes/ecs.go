@@ -0,0 +1,584 @@
+package fakes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// ECS はクラスター・サービス・タスク・タスク定義をメモリ上に持つ ECS
+// サービスの DesiredCount を変えるとタスクがすぐに増減し、StopTask したタスクはすぐに STOPPED になる
+type ECS struct {
+	recorder
+	// PageSize は List 系の1ページの最大件数 (0 なら MaxResults か API の既定)
+	PageSize int
+	// DrainingDescribes は削除したサービスが INACTIVE になるまでに、DescribeServices が DRAINING を返す回数
+	DrainingDescribes int
+
+	mu       sync.Mutex
+	clusters []*ecsCluster
+	taskDefs []ecstypes.TaskDefinition
+	nextTask int
+}
+
+type ecsCluster struct {
+	cluster  ecstypes.Cluster
+	services []*ecsService
+	tasks    []*ecstypes.Task
+}
+
+type ecsService struct {
+	service  ecstypes.Service
+	draining int // INACTIVE になるまでに DRAINING を返す残りの回数
+}
+
+// NewECS は空の ECS を返す
+func NewECS() *ECS {
+	return &ECS{}
+}
+
+// AddCluster は ACTIVE のクラスターを作り、その ARN を返す
+func (f *ECS) AddCluster(name string, tags ...ecstypes.Tag) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clusterArn := arnOf("ecs", "cluster/"+name)
+	f.clusters = append(f.clusters, &ecsCluster{cluster: ecstypes.Cluster{
+		ClusterArn:  aws.String(clusterArn),
+		ClusterName: aws.String(name),
+		Status:      aws.String("ACTIVE"),
+		Tags:        tags,
+	}})
+	return clusterArn
+}
+
+// AddService はクラスターに desired 個のタスクが動いている ACTIVE のサービスを作り、その ARN (新形式) を返す
+// タスク定義 <name>:1 も登録する
+func (f *ECS) AddService(cluster, name string, desired int32) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.cluster(cluster)
+	if c == nil {
+		panic(fmt.Sprintf("fakes: cluster %s does not exist", cluster))
+	}
+	clusterName := aws.ToString(c.cluster.ClusterName)
+	taskDef := f.registerTaskDefinition(name)
+	svc := &ecsService{service: ecstypes.Service{
+		ServiceArn:     aws.String(arnOf("ecs", "service/"+clusterName+"/"+name)),
+		ServiceName:    aws.String(name),
+		ClusterArn:     c.cluster.ClusterArn,
+		Status:         aws.String("ACTIVE"),
+		TaskDefinition: aws.String(taskDef),
+	}}
+	c.services = append(c.services, svc)
+	f.scale(c, svc, desired)
+	return aws.ToString(svc.service.ServiceArn)
+}
+
+// AddTask はサービスに属さないタスク (RunTask で起動したものなど) を作り、その ARN を返す
+// task の未設定の項目は、LastStatus・DesiredStatus が RUNNING、Group が family:standalone になる
+func (f *ECS) AddTask(cluster string, task ecstypes.Task) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.cluster(cluster)
+	if c == nil {
+		panic(fmt.Sprintf("fakes: cluster %s does not exist", cluster))
+	}
+	if task.Group == nil {
+		task.Group = aws.String("family:standalone")
+	}
+	return aws.ToString(f.addTask(c, task).TaskArn)
+}
+
+// Service はサービスの現在の状態を返す (name は名前か ARN)
+func (f *ECS) Service(cluster, name string) (ecstypes.Service, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.cluster(cluster)
+	if c == nil {
+		return ecstypes.Service{}, false
+	}
+	svc, err := c.service(name)
+	if err != nil || svc == nil {
+		return ecstypes.Service{}, false
+	}
+	return svc.service, true
+}
+
+// Task はタスクの現在の状態を返す (クラスターは問わない)
+func (f *ECS) Task(taskArn string) (ecstypes.Task, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.clusters {
+		if t := c.task(taskArn); t != nil {
+			return *t, true
+		}
+	}
+	return ecstypes.Task{}, false
+}
+
+// Tasks はクラスターのタスク (STOPPED も含む) を作った順に返す
+func (f *ECS) Tasks(cluster string) []ecstypes.Task {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tasks []ecstypes.Task
+	if c := f.cluster(cluster); c != nil {
+		for _, t := range c.tasks {
+			tasks = append(tasks, *t)
+		}
+	}
+	return tasks
+}
+
+// 名前か ARN でクラスターを探す (無ければ nil)
+func (f *ECS) cluster(id string) *ecsCluster {
+	name := clusterName(id)
+	for _, c := range f.clusters {
+		if aws.ToString(c.cluster.ClusterName) == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// API の Cluster の値のクラスター (無ければ ClusterNotFoundException)
+func (f *ECS) clusterFor(id *string) (*ecsCluster, error) {
+	c := f.cluster(aws.ToString(id))
+	if c == nil || aws.ToString(c.cluster.Status) == "INACTIVE" {
+		return nil, &ecstypes.ClusterNotFoundException{Message: aws.String("Cluster not found.")}
+	}
+	return c, nil
+}
+
+func clusterName(id string) string {
+	if id == "" {
+		return "default"
+	}
+	if parsed, err := arn.Parse(id); err == nil {
+		return strings.TrimPrefix(parsed.Resource, "cluster/")
+	}
+	return id
+}
+
+// 名前か ARN でサービスを探す (無ければ nil)
+// 別のクラスターのサービス ARN なら InvalidParameterException
+func (c *ecsCluster) service(id string) (*ecsService, error) {
+	name := id
+	if parsed, err := arn.Parse(id); err == nil {
+		parts := strings.Split(parsed.Resource, "/")
+		if len(parts) == 3 && parts[1] != aws.ToString(c.cluster.ClusterName) {
+			return nil, &ecstypes.InvalidParameterException{Message: aws.String(fmt.Sprintf("Service %s does not belong to cluster %s.", id, aws.ToString(c.cluster.ClusterName)))}
+		}
+		name = parts[len(parts)-1]
+	}
+	for _, svc := range c.services {
+		if aws.ToString(svc.service.ServiceName) == name {
+			return svc, nil
+		}
+	}
+	return nil, nil
+}
+
+// ARN かタスク ID でタスクを探す (無ければ nil)
+func (c *ecsCluster) task(id string) *ecstypes.Task {
+	for _, t := range c.tasks {
+		if arn := aws.ToString(t.TaskArn); arn == id || arn[strings.LastIndex(arn, "/")+1:] == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (f *ECS) addTask(c *ecsCluster, task ecstypes.Task) *ecstypes.Task {
+	f.nextTask++
+	if task.TaskArn == nil {
+		task.TaskArn = aws.String(arnOf("ecs", fmt.Sprintf("task/%s/%032x", aws.ToString(c.cluster.ClusterName), f.nextTask)))
+	}
+	task.ClusterArn = c.cluster.ClusterArn
+	if task.LastStatus == nil {
+		task.LastStatus = aws.String("RUNNING")
+	}
+	if task.DesiredStatus == nil {
+		task.DesiredStatus = aws.String("RUNNING")
+	}
+	if task.StartedAt == nil {
+		task.StartedAt = aws.Time(time.Now())
+	}
+	c.tasks = append(c.tasks, &task)
+	return &task
+}
+
+func stopTask(t *ecstypes.Task, reason string) {
+	t.DesiredStatus = aws.String("STOPPED")
+	t.LastStatus = aws.String("STOPPED")
+	t.StoppedReason = aws.String(reason)
+	t.StoppedAt = aws.Time(time.Now())
+}
+
+// サービスのタスクを desired 個にそろえ、サービスとデプロイの数を更新する
+func (f *ECS) scale(c *ecsCluster, svc *ecsService, desired int32) {
+	group := "service:" + aws.ToString(svc.service.ServiceName)
+	var running []*ecstypes.Task
+	for _, t := range c.tasks {
+		if aws.ToString(t.Group) == group && aws.ToString(t.LastStatus) != "STOPPED" {
+			running = append(running, t)
+		}
+	}
+	for _, t := range running[min(int(desired), len(running)):] {
+		stopTask(t, "Scaling activity initiated by (deployment ecs-svc/1)")
+	}
+	for n := int32(len(running)); n < desired; n++ {
+		f.addTask(c, ecstypes.Task{
+			Group:             aws.String(group),
+			StartedBy:         aws.String("ecs-svc/1"),
+			TaskDefinitionArn: svc.service.TaskDefinition,
+		})
+	}
+	svc.service.DesiredCount = desired
+	svc.service.RunningCount = desired
+	svc.service.Deployments = []ecstypes.Deployment{{
+		Id:             aws.String("ecs-svc/1"),
+		Status:         aws.String("PRIMARY"),
+		DesiredCount:   desired,
+		RunningCount:   desired,
+		RolloutState:   ecstypes.DeploymentRolloutStateCompleted,
+		TaskDefinition: svc.service.TaskDefinition,
+	}}
+}
+
+func (f *ECS) registerTaskDefinition(family string) string {
+	revision := int32(1)
+	for _, td := range f.taskDefs {
+		if aws.ToString(td.Family) == family {
+			revision = max(revision, td.Revision+1)
+		}
+	}
+	tdArn := arnOf("ecs", fmt.Sprintf("task-definition/%s:%d", family, revision))
+	f.taskDefs = append(f.taskDefs, ecstypes.TaskDefinition{
+		TaskDefinitionArn: aws.String(tdArn),
+		Family:            aws.String(family),
+		Revision:          revision,
+		Status:            ecstypes.TaskDefinitionStatusActive,
+	})
+	return tdArn
+}
+
+func (f *ECS) ListClusters(ctx context.Context, params *ecs.ListClustersInput, optFns ...func(*ecs.Options)) (*ecs.ListClustersOutput, error) {
+	if err := f.call(ctx, "ListClusters", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var arns []string
+	for _, c := range f.clusters {
+		if aws.ToString(c.cluster.Status) != "INACTIVE" {
+			arns = append(arns, aws.ToString(c.cluster.ClusterArn))
+		}
+	}
+	arns, next := page(arns, identity, params.NextToken, pageSize(f.PageSize, params.MaxResults, 100))
+	return &ecs.ListClustersOutput{ClusterArns: arns, NextToken: next}, nil
+}
+
+func (f *ECS) DescribeClusters(ctx context.Context, params *ecs.DescribeClustersInput, optFns ...func(*ecs.Options)) (*ecs.DescribeClustersOutput, error) {
+	if err := f.call(ctx, "DescribeClusters", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &ecs.DescribeClustersOutput{}
+	ids := params.Clusters
+	if len(ids) == 0 {
+		ids = []string{"default"}
+	}
+	for _, id := range ids {
+		c := f.cluster(id)
+		if c == nil {
+			out.Failures = append(out.Failures, ecstypes.Failure{Arn: aws.String(arnOf("ecs", "cluster/"+clusterName(id))), Reason: aws.String("MISSING")})
+			continue
+		}
+		cluster := c.cluster
+		if !slices.Contains(params.Include, ecstypes.ClusterFieldTags) {
+			cluster.Tags = nil
+		}
+		out.Clusters = append(out.Clusters, cluster)
+	}
+	return out, nil
+}
+
+// キャパシティプロバイダーは持たない
+func (f *ECS) DescribeCapacityProviders(ctx context.Context, params *ecs.DescribeCapacityProvidersInput, optFns ...func(*ecs.Options)) (*ecs.DescribeCapacityProvidersOutput, error) {
+	if err := f.call(ctx, "DescribeCapacityProviders", params); err != nil {
+		return nil, err
+	}
+	out := &ecs.DescribeCapacityProvidersOutput{}
+	for _, name := range params.CapacityProviders {
+		out.Failures = append(out.Failures, ecstypes.Failure{Arn: aws.String(name), Reason: aws.String("MISSING")})
+	}
+	return out, nil
+}
+
+// コンテナインスタンスは持たない (Fargate のみ)
+func (f *ECS) ListContainerInstances(ctx context.Context, params *ecs.ListContainerInstancesInput, optFns ...func(*ecs.Options)) (*ecs.ListContainerInstancesOutput, error) {
+	if err := f.call(ctx, "ListContainerInstances", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.clusterFor(params.Cluster); err != nil {
+		return nil, err
+	}
+	return &ecs.ListContainerInstancesOutput{}, nil
+}
+
+func (f *ECS) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	if err := f.call(ctx, "ListServices", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	var arns []string
+	for _, svc := range c.services {
+		if aws.ToString(svc.service.Status) != "INACTIVE" {
+			arns = append(arns, aws.ToString(svc.service.ServiceArn))
+		}
+	}
+	arns, next := page(arns, identity, params.NextToken, pageSize(f.PageSize, params.MaxResults, 10))
+	return &ecs.ListServicesOutput{ServiceArns: arns, NextToken: next}, nil
+}
+
+// DRAINING のサービスは、DrainingDescribes 回返したあと INACTIVE になる
+func (f *ECS) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	if err := f.call(ctx, "DescribeServices", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	out := &ecs.DescribeServicesOutput{}
+	for _, id := range params.Services {
+		svc, err := c.service(id)
+		if err != nil {
+			return nil, err
+		}
+		if svc == nil {
+			out.Failures = append(out.Failures, ecstypes.Failure{Arn: aws.String(id), Reason: aws.String("MISSING")})
+			continue
+		}
+		out.Services = append(out.Services, svc.service)
+		if aws.ToString(svc.service.Status) == "DRAINING" {
+			if svc.draining--; svc.draining <= 0 {
+				svc.service.Status = aws.String("INACTIVE")
+			}
+		}
+	}
+	return out, nil
+}
+
+// API の Service の値の ACTIVE なサービス (無ければ ServiceNotFoundException、削除中なら ServiceNotActiveException)
+func (c *ecsCluster) activeService(id *string) (*ecsService, error) {
+	svc, err := c.service(aws.ToString(id))
+	switch {
+	case err != nil:
+		return nil, err
+	case svc == nil || aws.ToString(svc.service.Status) == "INACTIVE":
+		return nil, &ecstypes.ServiceNotFoundException{Message: aws.String("Service not found.")}
+	case aws.ToString(svc.service.Status) == "DRAINING":
+		return nil, &ecstypes.ServiceNotActiveException{Message: aws.String("Service was not ACTIVE.")}
+	}
+	return svc, nil
+}
+
+func (f *ECS) UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error) {
+	if err := f.call(ctx, "UpdateService", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := c.activeService(params.Service)
+	if err != nil {
+		return nil, err
+	}
+	if params.DesiredCount != nil {
+		f.scale(c, svc, *params.DesiredCount)
+	}
+	return &ecs.UpdateServiceOutput{Service: &svc.service}, nil
+}
+
+// Force なら残ったタスクも止める。DrainingDescribes が 0 ならすぐに INACTIVE になる
+func (f *ECS) DeleteService(ctx context.Context, params *ecs.DeleteServiceInput, optFns ...func(*ecs.Options)) (*ecs.DeleteServiceOutput, error) {
+	if err := f.call(ctx, "DeleteService", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := c.activeService(params.Service)
+	if err != nil {
+		return nil, err
+	}
+	if svc.service.DesiredCount > 0 && !aws.ToBool(params.Force) {
+		return nil, &ecstypes.InvalidParameterException{Message: aws.String("The service cannot be stopped while it is scaled above 0.")}
+	}
+	f.scale(c, svc, 0)
+	svc.service.Status = aws.String("INACTIVE")
+	if f.DrainingDescribes > 0 {
+		svc.service.Status = aws.String("DRAINING")
+		svc.draining = f.DrainingDescribes
+	}
+	return &ecs.DeleteServiceOutput{Service: &svc.service}, nil
+}
+
+func (f *ECS) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	if err := f.call(ctx, "ListTasks", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	if params.ServiceName != nil {
+		if svc, err := c.service(*params.ServiceName); err != nil || svc == nil {
+			return nil, &ecstypes.ServiceNotFoundException{Message: aws.String("Service not found.")}
+		}
+	}
+	desired := cmp.Or(string(params.DesiredStatus), "RUNNING")
+	var arns []string
+	for _, t := range c.tasks {
+		if aws.ToString(t.DesiredStatus) != desired {
+			continue
+		}
+		if params.ServiceName != nil && aws.ToString(t.Group) != "service:"+*params.ServiceName {
+			continue
+		}
+		arns = append(arns, aws.ToString(t.TaskArn))
+	}
+	arns, next := page(arns, identity, params.NextToken, pageSize(f.PageSize, params.MaxResults, 100))
+	return &ecs.ListTasksOutput{TaskArns: arns, NextToken: next}, nil
+}
+
+func (f *ECS) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	if err := f.call(ctx, "DescribeTasks", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	out := &ecs.DescribeTasksOutput{}
+	for _, id := range params.Tasks {
+		if t := c.task(id); t != nil {
+			out.Tasks = append(out.Tasks, *t)
+			continue
+		}
+		out.Failures = append(out.Failures, ecstypes.Failure{Arn: aws.String(id), Reason: aws.String("MISSING")})
+	}
+	return out, nil
+}
+
+func (f *ECS) StopTask(ctx context.Context, params *ecs.StopTaskInput, optFns ...func(*ecs.Options)) (*ecs.StopTaskOutput, error) {
+	if err := f.call(ctx, "StopTask", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	t := c.task(aws.ToString(params.Task))
+	if t == nil {
+		return nil, &ecstypes.InvalidParameterException{Message: aws.String("The referenced task was not found.")}
+	}
+	if aws.ToString(t.LastStatus) != "STOPPED" {
+		stopTask(t, aws.ToString(params.Reason))
+	}
+	task := *t
+	return &ecs.StopTaskOutput{Task: &task}, nil
+}
+
+// 実際の API と同じく、FamilyPrefix はファミリー名の完全一致
+func (f *ECS) ListTaskDefinitions(ctx context.Context, params *ecs.ListTaskDefinitionsInput, optFns ...func(*ecs.Options)) (*ecs.ListTaskDefinitionsOutput, error) {
+	if err := f.call(ctx, "ListTaskDefinitions", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := cmp.Or(string(params.Status), string(ecstypes.TaskDefinitionStatusActive))
+	var arns []string
+	for _, td := range f.taskDefs {
+		if string(td.Status) == status && (params.FamilyPrefix == nil || aws.ToString(td.Family) == *params.FamilyPrefix) {
+			arns = append(arns, aws.ToString(td.TaskDefinitionArn))
+		}
+	}
+	arns, next := page(arns, identity, params.NextToken, pageSize(f.PageSize, params.MaxResults, 100))
+	return &ecs.ListTaskDefinitionsOutput{TaskDefinitionArns: arns, NextToken: next}, nil
+}
+
+func (f *ECS) DeregisterTaskDefinition(ctx context.Context, params *ecs.DeregisterTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	if err := f.call(ctx, "DeregisterTaskDefinition", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.taskDefs {
+		td := &f.taskDefs[i]
+		if aws.ToString(td.TaskDefinitionArn) == aws.ToString(params.TaskDefinition) {
+			td.Status = ecstypes.TaskDefinitionStatusInactive
+			out := *td
+			return &ecs.DeregisterTaskDefinitionOutput{TaskDefinition: &out}, nil
+		}
+	}
+	return nil, &ecstypes.ClientException{Message: aws.String("Unable to describe task definition.")}
+}
+
+// サービスや止まっていないタスクが残っていれば失敗する
+func (f *ECS) DeleteCluster(ctx context.Context, params *ecs.DeleteClusterInput, optFns ...func(*ecs.Options)) (*ecs.DeleteClusterOutput, error) {
+	if err := f.call(ctx, "DeleteCluster", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.clusterFor(params.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range c.services {
+		if aws.ToString(svc.service.Status) != "INACTIVE" {
+			return nil, &ecstypes.ClusterContainsServicesException{Message: aws.String("The Cluster cannot be deleted while Services are active.")}
+		}
+	}
+	for _, t := range c.tasks {
+		if aws.ToString(t.LastStatus) != "STOPPED" {
+			return nil, &ecstypes.ClusterContainsTasksException{Message: aws.String("The Cluster cannot be deleted while Tasks are active.")}
+		}
+	}
+	c.cluster.Status = aws.String("INACTIVE")
+	cluster := c.cluster
+	return &ecs.DeleteClusterOutput{Cluster: &cluster}, nil
+}
@@ -0,0 +1,105 @@
+package fakes
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// ELBv2 はターゲットグループとその登録済みターゲットをメモリ上に持つ ELBv2
+// 登録解除したターゲットはすぐに draining を終える (Targets を指定した DescribeTargetHealth では unused になる)
+type ELBv2 struct {
+	recorder
+
+	mu     sync.Mutex
+	groups map[string][]elbv2types.TargetDescription
+}
+
+// NewELBv2 は空の ELBv2 を返す
+func NewELBv2() *ELBv2 {
+	return &ELBv2{groups: map[string][]elbv2types.TargetDescription{}}
+}
+
+// AddTargetGroup はターゲットグループを作り、その ARN を返す
+func (f *ELBv2) AddTargetGroup(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tgArn := arnOf("elasticloadbalancing", "targetgroup/"+name+"/0123456789abcdef")
+	if _, ok := f.groups[tgArn]; !ok {
+		f.groups[tgArn] = nil
+	}
+	return tgArn
+}
+
+// RegisterTarget はターゲットグループに healthy のターゲット (IP とポート) を登録する
+func (f *ELBv2) RegisterTarget(tgArn, id string, port int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.groups[tgArn] = append(f.groups[tgArn], elbv2types.TargetDescription{Id: aws.String(id), Port: aws.Int32(port)})
+}
+
+// Targets はターゲットグループに登録されているターゲット
+func (f *ELBv2) Targets(tgArn string) []elbv2types.TargetDescription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.groups[tgArn])
+}
+
+func sameTarget(a, b elbv2types.TargetDescription) bool {
+	return aws.ToString(a.Id) == aws.ToString(b.Id) && aws.ToInt32(a.Port) == aws.ToInt32(b.Port)
+}
+
+func (f *ELBv2) DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error) {
+	if err := f.call(ctx, "DescribeTargetHealth", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	targets, ok := f.groups[aws.ToString(params.TargetGroupArn)]
+	if !ok {
+		return nil, &elbv2types.TargetGroupNotFoundException{Message: aws.String("One or more target groups not found")}
+	}
+	out := &elbv2.DescribeTargetHealthOutput{}
+	health := func(t elbv2types.TargetDescription, state elbv2types.TargetHealthStateEnum) {
+		target := t
+		out.TargetHealthDescriptions = append(out.TargetHealthDescriptions, elbv2types.TargetHealthDescription{
+			Target:       &target,
+			TargetHealth: &elbv2types.TargetHealth{State: state},
+		})
+	}
+	if len(params.Targets) == 0 {
+		for _, t := range targets {
+			health(t, elbv2types.TargetHealthStateEnumHealthy)
+		}
+		return out, nil
+	}
+	for _, t := range params.Targets {
+		state := elbv2types.TargetHealthStateEnumUnused
+		if slices.ContainsFunc(targets, func(r elbv2types.TargetDescription) bool { return sameTarget(r, t) }) {
+			state = elbv2types.TargetHealthStateEnumHealthy
+		}
+		health(t, state)
+	}
+	return out, nil
+}
+
+func (f *ELBv2) DeregisterTargets(ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.DeregisterTargetsOutput, error) {
+	if err := f.call(ctx, "DeregisterTargets", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tgArn := aws.ToString(params.TargetGroupArn)
+	targets, ok := f.groups[tgArn]
+	if !ok {
+		return nil, &elbv2types.TargetGroupNotFoundException{Message: aws.String("One or more target groups not found")}
+	}
+	f.groups[tgArn] = slices.DeleteFunc(targets, func(r elbv2types.TargetDescription) bool {
+		return slices.ContainsFunc(params.Targets, func(t elbv2types.TargetDescription) bool { return sameTarget(r, t) })
+	})
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
@@ -0,0 +1,130 @@
+package fakes
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// Events は default バスのルールとそのターゲットをメモリ上に持つ EventBridge
+type Events struct {
+	recorder
+	// PageSize は ListRules・ListTargetsByRule の1ページの最大件数 (0 なら Limit か API の既定)
+	PageSize int
+
+	mu    sync.Mutex
+	rules map[string]*eventsRule
+}
+
+type eventsRule struct {
+	rule    ebtypes.Rule
+	targets []ebtypes.Target
+}
+
+// NewEvents は空の Events を返す
+func NewEvents() *Events {
+	return &Events{rules: map[string]*eventsRule{}}
+}
+
+// AddRule は有効なルールを作る
+func (f *Events) AddRule(name string, targets ...ebtypes.Target) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules[name] = &eventsRule{
+		rule: ebtypes.Rule{
+			Name:         aws.String(name),
+			Arn:          aws.String(arnOf("events", "rule/"+name)),
+			EventBusName: aws.String("default"),
+			State:        ebtypes.RuleStateEnabled,
+		},
+		targets: targets,
+	}
+}
+
+// EcsTarget はクラスターで taskDefinition のタスクを起動するターゲット
+func EcsTarget(id, clusterArn, taskDefinition string) ebtypes.Target {
+	return ebtypes.Target{
+		Id:            aws.String(id),
+		Arn:           aws.String(clusterArn),
+		EcsParameters: &ebtypes.EcsParameters{TaskDefinitionArn: aws.String(taskDefinition)},
+	}
+}
+
+// RuleState はルールの状態 (無ければ空)
+func (f *Events) RuleState(name string) ebtypes.RuleState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.rules[name]; ok {
+		return r.rule.State
+	}
+	return ""
+}
+
+func (f *Events) ruleFor(name *string) (*eventsRule, error) {
+	if r, ok := f.rules[aws.ToString(name)]; ok {
+		return r, nil
+	}
+	return nil, &ebtypes.ResourceNotFoundException{Message: aws.String("Rule " + aws.ToString(name) + " does not exist on EventBus default.")}
+}
+
+func (f *Events) ListRules(ctx context.Context, params *eventbridge.ListRulesInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListRulesOutput, error) {
+	if err := f.call(ctx, "ListRules", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rules []ebtypes.Rule
+	for _, r := range f.rules {
+		rules = append(rules, r.rule)
+	}
+	rules, next := page(rules, func(r ebtypes.Rule) string { return aws.ToString(r.Name) },
+		params.NextToken, pageSize(f.PageSize, params.Limit, 100))
+	return &eventbridge.ListRulesOutput{Rules: rules, NextToken: next}, nil
+}
+
+func (f *Events) ListTargetsByRule(ctx context.Context, params *eventbridge.ListTargetsByRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListTargetsByRuleOutput, error) {
+	if err := f.call(ctx, "ListTargetsByRule", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.ruleFor(params.Rule)
+	if err != nil {
+		return nil, err
+	}
+	targets, next := page(slices.Clone(r.targets), func(t ebtypes.Target) string { return aws.ToString(t.Id) },
+		params.NextToken, pageSize(f.PageSize, params.Limit, 100))
+	return &eventbridge.ListTargetsByRuleOutput{Targets: targets, NextToken: next}, nil
+}
+
+func (f *Events) DisableRule(ctx context.Context, params *eventbridge.DisableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DisableRuleOutput, error) {
+	if err := f.call(ctx, "DisableRule", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.ruleFor(params.Name)
+	if err != nil {
+		return nil, err
+	}
+	r.rule.State = ebtypes.RuleStateDisabled
+	return &eventbridge.DisableRuleOutput{}, nil
+}
+
+func (f *Events) EnableRule(ctx context.Context, params *eventbridge.EnableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.EnableRuleOutput, error) {
+	if err := f.call(ctx, "EnableRule", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.ruleFor(params.Name)
+	if err != nil {
+		return nil, err
+	}
+	r.rule.State = ebtypes.RuleStateEnabled
+	return &eventbridge.EnableRuleOutput{}, nil
+}
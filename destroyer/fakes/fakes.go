@@ -0,0 +1,131 @@
+// Package fakes は destroyer のテストで AWS のクライアントの代わりに使う、メモリ上の偽物
+// 各型は destroyer の API インターフェース (ecsAPI など) を満たし、状態を持って API の動作を真似る
+// 呼び出しは記録され、Err でエラーを、Delay で遅延を差し込める
+package fakes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 偽物のリソースの ARN に使うアカウントとリージョン
+const (
+	Account = "123456789012"
+	Region  = "us-east-1"
+)
+
+// Call は記録した1回分の API 呼び出し
+type Call struct {
+	Op    string // "ListServices" など
+	Input any    // *ecs.ListServicesInput など
+}
+
+// API 呼び出しの記録と、エラー・遅延の差し込み (各偽物に埋め込む)
+type recorder struct {
+	// Err は API を呼ぶたびに操作名と入力で呼ばれる。nil 以外を返すと、その呼び出しは状態を変えずにそのエラーで失敗する
+	Err func(op string, input any) error
+	// Delay は各呼び出しにかかる時間 (同時に実行される数を確かめるとき用)
+	Delay time.Duration
+
+	mu       sync.Mutex
+	calls    []Call
+	inFlight map[string]int
+	peak     map[string]int
+}
+
+// 呼び出しを記録し、Delay だけ待ってから Err の結果を返す
+func (r *recorder) call(ctx context.Context, op string, input any) error {
+	r.mu.Lock()
+	if r.inFlight == nil {
+		r.inFlight, r.peak = map[string]int{}, map[string]int{}
+	}
+	r.calls = append(r.calls, Call{Op: op, Input: input})
+	r.inFlight[op]++
+	r.peak[op] = max(r.peak[op], r.inFlight[op])
+	errFn, delay := r.Err, r.Delay
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight[op]--
+		r.mu.Unlock()
+	}()
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if errFn != nil {
+		return errFn(op, input)
+	}
+	return nil
+}
+
+// Calls は op の呼び出しの入力を呼ばれた順に返す (op が空なら全ての呼び出し)
+func (r *recorder) Calls(op string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []Call
+	for _, c := range r.calls {
+		if op == "" || c.Op == op {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// CallCount は op が呼ばれた回数
+func (r *recorder) CallCount(op string) int {
+	return len(r.Calls(op))
+}
+
+// MaxInFlight は op が同時に実行されていた数の最大
+func (r *recorder) MaxInFlight(op string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.peak[op]
+}
+
+// key の順に並べた items から、token (前のページの最後のキー) より後ろの最大 size 件を返す
+// 続きがあれば次のページの token も返す。キーで区切るので、ページの間に削除されても取りこぼさない
+func page[T any](items []T, key func(T) string, token *string, size int) ([]T, *string) {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b T) int { return cmp.Compare(key(a), key(b)) })
+	if token != nil {
+		i, _ := slices.BinarySearchFunc(items, *token, func(v T, t string) int { return cmp.Compare(key(v), t) })
+		for i < len(items) && key(items[i]) == *token {
+			i++
+		}
+		items = items[i:]
+	}
+	if size <= 0 || len(items) <= size {
+		return items, nil
+	}
+	next := key(items[size-1])
+	return items[:size], &next
+}
+
+// PageSize (偽物の設定) と MaxResults (API の入力) の小さい方。どちらも無ければ def
+func pageSize(fakeSize int, maxResults *int32, def int) int {
+	size := def
+	if maxResults != nil && *maxResults > 0 {
+		size = int(*maxResults)
+	}
+	if fakeSize > 0 {
+		size = min(size, fakeSize)
+	}
+	return size
+}
+
+// 偽物のリソースの ARN
+func arnOf(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, Region, Account, resource)
+}
+
+func identity(s string) string { return s }
@@ -0,0 +1,153 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 はバケットとそのオブジェクトのバージョン・削除マーカーをメモリ上に持つ S3
+type S3 struct {
+	recorder
+	// PageSize は ListObjectVersions の1ページの最大件数 (0 なら MaxKeys か API の既定)
+	PageSize int
+
+	mu      sync.Mutex
+	buckets map[string][]s3Entry
+	nextID  int
+}
+
+// バージョンか削除マーカー
+type s3Entry struct {
+	Key, VersionID string
+	DeleteMarker   bool
+}
+
+// バージョンの並び順とページの区切りのキー
+func (e s3Entry) sortKey() string {
+	return e.Key + "\x00" + e.VersionID
+}
+
+// NewS3 は空の S3 を返す
+func NewS3() *S3 {
+	return &S3{buckets: map[string][]s3Entry{}}
+}
+
+// AddBucket は空のバケットを作る
+func (f *S3) AddBucket(bucket string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.buckets[bucket]; !ok {
+		f.buckets[bucket] = nil
+	}
+}
+
+// PutObject はオブジェクトの新しいバージョンを作り、そのバージョン ID を返す (バケットが無ければ作る)
+func (f *S3) PutObject(bucket, key string) string {
+	return f.add(bucket, key, false)
+}
+
+// DeleteObject はオブジェクトに削除マーカーを置き、そのバージョン ID を返す (バージョニングされたバケットの削除)
+func (f *S3) DeleteObject(bucket, key string) string {
+	return f.add(bucket, key, true)
+}
+
+func (f *S3) add(bucket, key string, deleteMarker bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("v%06d", f.nextID)
+	f.buckets[bucket] = append(f.buckets[bucket], s3Entry{Key: key, VersionID: id, DeleteMarker: deleteMarker})
+	return id
+}
+
+// Versions はバケットに残っているバージョンと削除マーカーの数
+func (f *S3) Versions(bucket string) (versions, deleteMarkers int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.buckets[bucket] {
+		if e.DeleteMarker {
+			deleteMarkers++
+		} else {
+			versions++
+		}
+	}
+	return versions, deleteMarkers
+}
+
+func noSuchBucket() error {
+	return &s3types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")}
+}
+
+func (f *S3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if err := f.call(ctx, "ListObjectVersions", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, ok := f.buckets[aws.ToString(params.Bucket)]
+	if !ok {
+		return nil, noSuchBucket()
+	}
+	var token *string
+	if params.KeyMarker != nil {
+		token = aws.String(s3Entry{Key: *params.KeyMarker, VersionID: aws.ToString(params.VersionIdMarker)}.sortKey())
+	}
+	var matched []s3Entry
+	for _, e := range entries {
+		if strings.HasPrefix(e.Key, aws.ToString(params.Prefix)) {
+			matched = append(matched, e)
+		}
+	}
+	entries, next := page(matched, s3Entry.sortKey, token, pageSize(f.PageSize, params.MaxKeys, 1000))
+	out := &s3.ListObjectVersionsOutput{Name: params.Bucket, IsTruncated: aws.Bool(next != nil)}
+	for _, e := range entries {
+		if e.DeleteMarker {
+			out.DeleteMarkers = append(out.DeleteMarkers, s3types.DeleteMarkerEntry{Key: aws.String(e.Key), VersionId: aws.String(e.VersionID)})
+		} else {
+			out.Versions = append(out.Versions, s3types.ObjectVersion{Key: aws.String(e.Key), VersionId: aws.String(e.VersionID)})
+		}
+	}
+	if next != nil {
+		last := entries[len(entries)-1]
+		out.NextKeyMarker, out.NextVersionIdMarker = aws.String(last.Key), aws.String(last.VersionID)
+	}
+	return out, nil
+}
+
+// VersionId の無い指定はそのキーの全てのバージョンを消す (実際の S3 とは違い、削除マーカーは置かない)
+func (f *S3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if err := f.call(ctx, "DeleteObjects", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket := aws.ToString(params.Bucket)
+	entries, ok := f.buckets[bucket]
+	if !ok {
+		return nil, noSuchBucket()
+	}
+	out := &s3.DeleteObjectsOutput{}
+	if params.Delete == nil {
+		return out, nil
+	}
+	for _, obj := range params.Delete.Objects {
+		key, id := aws.ToString(obj.Key), aws.ToString(obj.VersionId)
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.Key == key && (id == "" || e.VersionID == id) {
+				continue
+			}
+			kept = append(kept, e)
+		}
+		entries = kept
+		out.Deleted = append(out.Deleted, s3types.DeletedObject{Key: obj.Key, VersionId: obj.VersionId})
+	}
+	f.buckets[bucket] = entries
+	return out, nil
+}
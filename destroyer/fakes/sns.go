@@ -0,0 +1,123 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// SNS はトピックとそのサブスクリプション、発行したメッセージをメモリ上に持つ SNS
+type SNS struct {
+	recorder
+	// PageSize は ListSubscriptionsByTopic の1ページの最大件数 (0 なら API の既定)
+	PageSize int
+
+	mu        sync.Mutex
+	topics    map[string][]snstypes.Subscription
+	published []sns.PublishInput
+	nextID    int
+}
+
+// NewSNS は空の SNS を返す
+func NewSNS() *SNS {
+	return &SNS{topics: map[string][]snstypes.Subscription{}}
+}
+
+// AddTopic はトピックを作り、その ARN を返す
+func (f *SNS) AddTopic(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	topicArn := arnOf("sns", name)
+	if _, ok := f.topics[topicArn]; !ok {
+		f.topics[topicArn] = nil
+	}
+	return topicArn
+}
+
+// Subscribe はトピックに確認済みのサブスクリプションを作り、その ARN を返す
+// pending なら確認待ち (ARN が "PendingConfirmation") のサブスクリプションになる
+func (f *SNS) Subscribe(topicArn, protocol, endpoint string, pending bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	subArn := fmt.Sprintf("%s:%08d-0000-0000-0000-000000000000", topicArn, f.nextID)
+	if pending {
+		subArn = "PendingConfirmation"
+	}
+	f.topics[topicArn] = append(f.topics[topicArn], snstypes.Subscription{
+		SubscriptionArn: aws.String(subArn),
+		TopicArn:        aws.String(topicArn),
+		Protocol:        aws.String(protocol),
+		Endpoint:        aws.String(endpoint),
+		Owner:           aws.String(Account),
+	})
+	return subArn
+}
+
+// Subscriptions はトピックに残っているサブスクリプション
+func (f *SNS) Subscriptions(topicArn string) []snstypes.Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.topics[topicArn])
+}
+
+// Published は Publish されたメッセージを発行した順に返す
+func (f *SNS) Published() []sns.PublishInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.published)
+}
+
+func notFound(what string) error {
+	return &snstypes.NotFoundException{Message: aws.String(what + " does not exist")}
+}
+
+// トピックが無くても失敗しない (実際の SNS と違い、宛先はどこでもよい)
+func (f *SNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	if err := f.call(ctx, "Publish", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published = append(f.published, *params)
+	return &sns.PublishOutput{MessageId: aws.String(fmt.Sprintf("%08d", len(f.published)))}, nil
+}
+
+func (f *SNS) ListSubscriptionsByTopic(ctx context.Context, params *sns.ListSubscriptionsByTopicInput, optFns ...func(*sns.Options)) (*sns.ListSubscriptionsByTopicOutput, error) {
+	if err := f.call(ctx, "ListSubscriptionsByTopic", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subs, ok := f.topics[aws.ToString(params.TopicArn)]
+	if !ok {
+		return nil, notFound("Topic")
+	}
+	subs, next := page(subs, func(s snstypes.Subscription) string {
+		// 確認待ちのサブスクリプションは ARN が同じなので、エンドポイントでも区別する
+		return aws.ToString(s.SubscriptionArn) + "\x00" + aws.ToString(s.Endpoint)
+	}, params.NextToken, pageSize(f.PageSize, nil, 100))
+	return &sns.ListSubscriptionsByTopicOutput{Subscriptions: subs, NextToken: next}, nil
+}
+
+func (f *SNS) Unsubscribe(ctx context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	if err := f.call(ctx, "Unsubscribe", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subArn := aws.ToString(params.SubscriptionArn)
+	topicArn := subArn[:max(strings.LastIndex(subArn, ":"), 0)]
+	i := slices.IndexFunc(f.topics[topicArn], func(s snstypes.Subscription) bool { return aws.ToString(s.SubscriptionArn) == subArn })
+	if i < 0 {
+		return nil, notFound("Subscription")
+	}
+	f.topics[topicArn] = slices.Delete(f.topics[topicArn], i, i+1)
+	return &sns.UnsubscribeOutput{}, nil
+}
@@ -0,0 +1,66 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQS はキューとそのメッセージの数をメモリ上に持つ SQS
+// 実際の SQS と同じく、PurgeQueue は同じキューに 60 秒に1回しか呼べない
+type SQS struct {
+	recorder
+
+	mu     sync.Mutex
+	queues map[string]*sqsQueue
+}
+
+type sqsQueue struct {
+	messages int
+	purgedAt time.Time
+}
+
+// NewSQS は空の SQS を返す
+func NewSQS() *SQS {
+	return &SQS{queues: map[string]*sqsQueue{}}
+}
+
+// AddQueue は messages 件のメッセージが入ったキューを作り、その URL を返す
+func (f *SQS) AddQueue(name string, messages int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	url := "https://sqs." + Region + ".amazonaws.com/" + Account + "/" + name
+	f.queues[url] = &sqsQueue{messages: messages}
+	return url
+}
+
+// Messages はキューに残っているメッセージの数
+func (f *SQS) Messages(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if q, ok := f.queues[url]; ok {
+		return q.messages
+	}
+	return 0
+}
+
+func (f *SQS) PurgeQueue(ctx context.Context, params *sqs.PurgeQueueInput, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	if err := f.call(ctx, "PurgeQueue", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.queues[aws.ToString(params.QueueUrl)]
+	if !ok {
+		return nil, &sqstypes.QueueDoesNotExist{Message: aws.String("The specified queue does not exist.")}
+	}
+	if !q.purgedAt.IsZero() && time.Since(q.purgedAt) < 60*time.Second {
+		return nil, &sqstypes.PurgeQueueInProgress{Message: aws.String("Only one PurgeQueue operation on " + aws.ToString(params.QueueUrl) + " is allowed every 60 seconds.")}
+	}
+	q.messages, q.purgedAt = 0, time.Now()
+	return &sqs.PurgeQueueOutput{}, nil
+}
@@ -0,0 +1,94 @@
+package destroyer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// 削除対象のサービスを名前で絞り込む (--service-include / --service-exclude)
+// 共有クラスターで関係のないサービスを消さないためのもの
+type serviceFilter struct {
+	Include *regexp.Regexp // nil なら全て対象
+	Exclude *regexp.Regexp // nil なら除外しない
+}
+
+func newServiceFilter(include, exclude string) (serviceFilter, error) {
+	var f serviceFilter
+	var err error
+	if include != "" {
+		if f.Include, err = regexp.Compile(include); err != nil {
+			return f, fmt.Errorf("--service-include: %w", err)
+		}
+	}
+	if exclude != "" {
+		if f.Exclude, err = regexp.Compile(exclude); err != nil {
+			return f, fmt.Errorf("--service-exclude: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// 絞り込みが指定されているか
+func (f serviceFilter) enabled() bool {
+	return f.Include != nil || f.Exclude != nil
+}
+
+// サービス名が削除対象か
+func (f serviceFilter) match(svcName string) bool {
+	if f.Include != nil && !f.Include.MatchString(svcName) {
+		return false
+	}
+	return f.Exclude == nil || !f.Exclude.MatchString(svcName)
+}
+
+// --tasks-started-after の値 (RFC3339 の時刻、または now からさかのぼる時間 "2h" など) を時刻にする
+func parseStartedAfter(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("expected an RFC3339 time or a positive duration, got %q", s)
+	}
+	return now.Add(-d), nil
+}
+
+// 直接止めるタスクを絞り込む
+//   - 対象外のサービス (--service-include / --service-exclude) に属するタスクを除く (サービスに属さないタスクは残す)
+//   - startedAfter より前に起動したタスクを除く (まだ起動していない PENDING のタスクは残す)
+//
+// タスクの group は "service:<サービス名>" の形式
+func filterTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, taskArns []string, f serviceFilter, startedAfter time.Time, retry retryPolicy) ([]string, error) {
+	var kept []string
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		batch := taskArns[start:min(start+describeTasksBatchSize, len(taskArns))]
+		out, err := withRetry(ctx, retry, "DescribeTasks", func() (*ecs.DescribeTasksOutput, error) {
+			return ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: &clusterName,
+				Tasks:   batch,
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeTasks error: %w", err)
+		}
+		for _, task := range out.Tasks {
+			taskArn := aws.ToString(task.TaskArn)
+			if svcName, ok := strings.CutPrefix(aws.ToString(task.Group), "service:"); ok && !f.match(svcName) {
+				loggerFrom(ctx).With("cluster", clusterName, "task", arnToName(taskArn)).Infof("Skipping task of service %s (filtered out by --service-include/--service-exclude)", svcName)
+				continue
+			}
+			if task.StartedAt != nil && !startedAfter.IsZero() && task.StartedAt.Before(startedAfter) {
+				loggerFrom(ctx).With("cluster", clusterName, "task", arnToName(taskArn)).Infof("Skipping task started at %s (before --tasks-started-after)", task.StartedAt.Format(time.RFC3339))
+				continue
+			}
+			kept = append(kept, taskArn)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,192 @@
+package destroyer
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// --inspect で出力する、スタックごとの ECS の現状 (何も変更しない)
+type inventory struct {
+	Account string           `json:"account"`
+	Region  string           `json:"region"`
+	Stacks  []stackInventory `json:"stacks"`
+}
+
+type stackInventory struct {
+	Stack    string             `json:"stack"`
+	Region   string             `json:"region,omitempty"` // 複数のリージョンのスタックを調べたときだけ
+	Status   string             `json:"status"`           // CloudFormation のスタックの状態 (無ければ "not found")
+	Clusters []clusterInventory `json:"clusters,omitempty"`
+}
+
+type clusterInventory struct {
+	Name     string             `json:"name"`
+	Status   string             `json:"status"`
+	Services []serviceInventory `json:"services,omitempty"`
+	Tasks    []taskInventory    `json:"tasks,omitempty"` // 停止していないタスク (サービス以外のタスクも含む)
+}
+
+type serviceInventory struct {
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	TaskDefinition string `json:"taskDefinition"`
+	Desired        int32  `json:"desired"`
+	Running        int32  `json:"running"`
+	Pending        int32  `json:"pending"`
+}
+
+type taskInventory struct {
+	ID             string     `json:"id"`
+	Group          string     `json:"group"` // "service:<name>" や "family:<name>"
+	LastStatus     string     `json:"lastStatus"`
+	TaskDefinition string     `json:"taskDefinition"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+}
+
+// スタックのクラスター・サービス・実行中のタスクを調べる
+func inspectStack(ctx context.Context, clients awsClients, stackName string, opts cleanupOptions) (stackInventory, error) {
+	inv := stackInventory{Stack: stackName}
+	stack, err := describeStack(ctx, clients.CFN, stackName, opts.Retry)
+	if err != nil {
+		return inv, fmt.Errorf("Failed to describe stack: %w", err)
+	}
+	if stack == nil {
+		inv.Status = statusNotFound
+		return inv, nil
+	}
+	inv.Status = string(stack.StackStatus)
+
+	// --cluster のクラスターはスタックとは別に出す
+	if len(opts.Clusters) > 0 {
+		return inv, nil
+	}
+	stacks, err := listStackTree(ctx, clients.CFN, stackName, opts.MaxStackDepth, opts.Retry)
+	if err != nil {
+		return inv, fmt.Errorf("Failed to list nested stacks: %w", err)
+	}
+	clusterNames, err := discoverClusters(ctx, clients, stackName, stacks, opts)
+	if err != nil {
+		return inv, err
+	}
+	inv.Clusters, err = inspectClusters(ctx, clients.ECS, clusterNames, opts.Retry)
+	return inv, err
+}
+
+func inspectClusters(ctx context.Context, ecsClient ecsAPI, clusterNames []string, retry retryPolicy) ([]clusterInventory, error) {
+	var clusters []clusterInventory
+	for _, clusterName := range clusterNames {
+		c, err := inspectCluster(ctx, ecsClient, clusterName, retry)
+		if err != nil {
+			return clusters, fmt.Errorf("cluster(%s): %w", clusterName, err)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+func inspectCluster(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) (clusterInventory, error) {
+	c := clusterInventory{Name: clusterName}
+	status, err := describeClusterStatus(ctx, ecsClient, clusterName, retry)
+	if err != nil {
+		return c, err
+	}
+	c.Status = cmp.Or(status, statusNotFound)
+	if status != "ACTIVE" {
+		return c, nil
+	}
+
+	serviceArns, err := listServiceArns(ctx, ecsClient, clusterName, retry)
+	if err != nil {
+		return c, err
+	}
+	services, err := describeServices(ctx, ecsClient, clusterName, serviceArns, retry)
+	if err != nil {
+		return c, err
+	}
+	for _, svc := range services {
+		c.Services = append(c.Services, serviceInventory{
+			Name:           aws.ToString(svc.ServiceName),
+			Status:         aws.ToString(svc.Status),
+			TaskDefinition: arnToName(aws.ToString(svc.TaskDefinition)),
+			Desired:        svc.DesiredCount,
+			Running:        svc.RunningCount,
+			Pending:        svc.PendingCount,
+		})
+	}
+
+	taskArns, err := listActiveTaskArns(ctx, ecsClient, clusterName, "", retry)
+	if err != nil {
+		return c, err
+	}
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		batch := taskArns[start:min(start+describeTasksBatchSize, len(taskArns))]
+		out, err := withRetry(ctx, retry, "DescribeTasks", func() (*ecs.DescribeTasksOutput, error) {
+			return ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: &clusterName,
+				Tasks:   batch,
+			})
+		})
+		if err != nil {
+			return c, fmt.Errorf("DescribeTasks error: %w", err)
+		}
+		for _, task := range out.Tasks {
+			c.Tasks = append(c.Tasks, taskInventory{
+				ID:             lastPathSegment(aws.ToString(task.TaskArn)),
+				Group:          aws.ToString(task.Group),
+				LastStatus:     aws.ToString(task.LastStatus),
+				TaskDefinition: arnToName(aws.ToString(task.TaskDefinitionArn)),
+				StartedAt:      task.StartedAt,
+			})
+		}
+	}
+	return c, nil
+}
+
+// 調べた結果を出力する (json 形式では1つの JSON、text 形式ではインデントした一覧)
+func (inv inventory) write(w io.Writer, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	fmt.Fprintf(w, "Account: %s\n", inv.Account)
+	fmt.Fprintf(w, "Region:  %s\n", inv.Region)
+	// --cluster があればスタックからはクラスターを探していない
+	clusterOnly := slices.ContainsFunc(inv.Stacks, func(st stackInventory) bool { return st.Stack == clusterOnlySummaryName })
+	for _, st := range inv.Stacks {
+		if st.Region != "" {
+			fmt.Fprintf(w, "Stack: %s (%s, %s)\n", stackDisplayName(st.Stack), st.Status, st.Region)
+		} else {
+			fmt.Fprintf(w, "Stack: %s (%s)\n", stackDisplayName(st.Stack), st.Status)
+		}
+		if len(st.Clusters) == 0 && st.Status != statusNotFound && !clusterOnly {
+			fmt.Fprintln(w, "  Cluster: (none)")
+		}
+		for _, c := range st.Clusters {
+			fmt.Fprintf(w, "  Cluster: %s (%s, services: %d, tasks: %d)\n", c.Name, c.Status, len(c.Services), len(c.Tasks))
+			for _, svc := range c.Services {
+				fmt.Fprintf(w, "    Service: %s (%s, desired %d, running %d, pending %d, %s)\n", svc.Name, svc.Status, svc.Desired, svc.Running, svc.Pending, svc.TaskDefinition)
+			}
+			for _, t := range c.Tasks {
+				started := ""
+				if t.StartedAt != nil {
+					started = ", started " + t.StartedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "    Task: %s (%s, %s, %s%s)\n", t.ID, t.LastStatus, t.Group, t.TaskDefinition, started)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// genregions は aws-sdk-go-v2 の partitions.json から、destroyer が既知とするリージョンの一覧を生成する
+//
+//	go run ./internal/genregions -o regions_generated.go
+//
+// partitions.json は SDK の internal パッケージにあり import できないので、go.mod で使っている
+// バージョンのモジュールのディレクトリ (go list -m) から読む。SDK を更新したら go generate し直す
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const sdkModule = "github.com/aws/aws-sdk-go-v2"
+
+// partitions.json のうち使う部分
+type partitionsFile struct {
+	Partitions []struct {
+		ID      string              `json:"id"`
+		Regions map[string]struct{} `json:"regions"`
+	} `json:"partitions"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("genregions: ")
+	out := flag.String("o", "regions_generated.go", "output file")
+	flag.Parse()
+
+	src, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// go.mod で使っている SDK の partitions.json から生成したソース
+func generate() ([]byte, error) {
+	listed, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}} {{.Version}}", sdkModule).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m %s: %w", sdkModule, err)
+	}
+	dir, version, ok := strings.Cut(strings.TrimSpace(string(listed)), " ")
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("%s is not downloaded (run go mod download)", sdkModule)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "internal", "endpoints", "awsrulesfn", "partitions.json"))
+	if err != nil {
+		return nil, err
+	}
+	var file partitionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("partitions.json: %w", err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by genregions from %s@%s partitions.json; DO NOT EDIT.\n\n", sdkModule, version)
+	b.WriteString("package destroyer\n\n")
+	b.WriteString("var sdkPartitions = []sdkPartition{\n")
+	for _, p := range file.Partitions {
+		regions := []string{}
+		for r := range p.Regions {
+			// aws-global などは API のエンドポイント用の疑似リージョン
+			if !strings.HasSuffix(r, "-global") {
+				regions = append(regions, r)
+			}
+		}
+		slices.Sort(regions)
+		fmt.Fprintf(&b, "{ID: %q, Regions: %#v},\n", p.ID, regions)
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
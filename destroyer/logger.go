@@ -0,0 +1,224 @@
+package destroyer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ログ出力の共通窓口 (--log-format の text / json どちらも同じ呼び出しで出力する)
+type appLogger struct {
+	l *slog.Logger
+}
+
+// 全体で使うロガー (main で --log-format / --verbose / --quiet に合わせて差し替える)
+var logger = newLogger(os.Stderr, "text", slog.LevelInfo, false)
+
+// レベル表示の色 (ANSI エスケープ)
+const (
+	colorReset  = "\x1b[0m"
+	colorGray   = "\x1b[90m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+)
+
+// text 形式のログに色を付けるか (w が端末で、--no-color も NO_COLOR 環境変数も無いときだけ)
+// NO_COLOR は値に関係なく、空でなければ色を付けない (https://no-color.org)
+func useColor(w io.Writer, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// format ("text" / "json") と出力レベルに応じたロガーを作成
+// color は text 形式のときだけ使う (json には色を付けない)
+func newLogger(w io.Writer, format string, level slog.Level, color bool) appLogger {
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) > 0 {
+					return a
+				}
+				switch a.Key {
+				case slog.TimeKey:
+					a.Key = "timestamp"
+				case slog.MessageKey:
+					a.Key = "message"
+				}
+				return a
+			},
+		})
+	default:
+		h = &textHandler{mu: &sync.Mutex{}, w: w, level: level, color: color}
+	}
+	return appLogger{l: slog.New(h)}
+}
+
+// stack / cluster / service などの属性を付けたロガーを返す
+func (a appLogger) With(args ...any) appLogger {
+	return appLogger{l: a.l.With(args...)}
+}
+
+func (a appLogger) Debugf(format string, args ...any) {
+	a.logf(slog.LevelDebug, format, args...)
+}
+
+func (a appLogger) Infof(format string, args ...any) {
+	a.logf(slog.LevelInfo, format, args...)
+}
+
+func (a appLogger) Warnf(format string, args ...any) {
+	a.logf(slog.LevelWarn, format, args...)
+}
+
+func (a appLogger) Errorf(format string, args ...any) {
+	a.logf(slog.LevelError, format, args...)
+}
+
+// 属性付きで1件出力する (json 形式で構造化した値を出すとき用)
+func (a appLogger) Log(level slog.Level, msg string, args ...any) {
+	a.l.Log(context.Background(), level, msg, args...)
+}
+
+// 出力しないレベルならメッセージの組み立ても省く
+// 引数のエラーに AWS のリクエスト ID があれば requestId 属性として付ける
+func (a appLogger) logf(level slog.Level, format string, args ...any) {
+	if !a.l.Enabled(context.Background(), level) {
+		return
+	}
+	var attrs []any
+	var ids []string
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			ids = appendRequestIDs(ids, err)
+		}
+	}
+	if len(ids) > 0 {
+		attrs = append(attrs, "requestId", strings.Join(ids, ","))
+	}
+	a.l.Log(context.Background(), level, fmt.Sprintf(format, args...), attrs...)
+}
+
+// err (errors.Join でまとめたものも含む) に含まれる AWS のリクエスト ID を ids に足す
+// AWS サポートへの問い合わせに使う。S3 などサービスごとのエラー型も ServiceRequestID で取れる
+func appendRequestIDs(ids []string, err error) []string {
+	switch e := err.(type) {
+	case interface{ ServiceRequestID() string }:
+		if id := e.ServiceRequestID(); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			ids = appendRequestIDs(ids, err)
+		}
+	case interface{ Unwrap() error }:
+		if err := e.Unwrap(); err != nil {
+			ids = appendRequestIDs(ids, err)
+		}
+	}
+	return ids
+}
+
+// 従来の log パッケージと同じ見た目のテキスト出力
+// 属性は "[Service: xxx]" のようにメッセージの前に付ける
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	color bool
+	attrs []slog.Attr
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	buf.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	if r.Level != slog.LevelInfo {
+		buf.WriteString(h.colorize(r.Level, r.Level.String()) + " ")
+	}
+	writeAttr := func(a slog.Attr) bool {
+		fmt.Fprintf(&buf, "[%s: %s]", attrLabel(a.Key), a.Value.String())
+		return true
+	}
+	for _, a := range h.attrs {
+		writeAttr(a)
+	}
+	r.Attrs(writeAttr)
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(r.Message)
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &textHandler{mu: h.mu, w: h.w, level: h.level, color: h.color, attrs: merged}
+}
+
+// レベルに応じた色を付ける (色を使わないならそのまま)
+func (h *textHandler) colorize(level slog.Level, s string) string {
+	if !h.color {
+		return s
+	}
+	switch {
+	case level >= slog.LevelError:
+		return colorRed + s + colorReset
+	case level >= slog.LevelWarn:
+		return colorYellow + s + colorReset
+	case level < slog.LevelInfo:
+		return colorGray + s + colorReset
+	}
+	return s
+}
+
+// グループは使っていないのでそのまま返す
+func (h *textHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// "service" → "Service" のように属性名を見出しにする
+func attrLabel(key string) string {
+	if key == "" {
+		return key
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
+
+type loggerKey struct{}
+
+// stack などの属性を付けたロガーを ctx に入れる (--parallel-stacks でスタックごとに分けるため)
+func withLogger(ctx context.Context, l appLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// ctx のロガー (無ければ全体のロガー)
+func loggerFrom(ctx context.Context) appLogger {
+	if l, ok := ctx.Value(loggerKey{}).(appLogger); ok {
+		return l
+	}
+	return logger
+}
@@ -0,0 +1,48 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// スタック内の CloudWatch Logs ロググループ名を取得
+func getLogGroupNamesFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::Logs::LogGroup", retry)
+}
+
+// ロググループを削除する (dryRun 時は対象の表示のみ)。削除したロググループ数を返す
+// 既に存在しないロググループは無視する
+func deleteLogGroups(ctx context.Context, logsClient logsAPI, logGroupNames []string, opts cleanupOptions) (int, error) {
+	var deleted int
+	var errs []error
+	for _, name := range logGroupNames {
+		groupLog := loggerFrom(ctx).With("logGroup", name)
+		if opts.DryRun {
+			groupLog.Infof("[DryRun] Would delete log group")
+			deleted++
+			continue
+		}
+		_, err := withRetry(ctx, opts.Retry, "DeleteLogGroup", func() (*cloudwatchlogs.DeleteLogGroupOutput, error) {
+			return logsClient.DeleteLogGroup(ctx, &cloudwatchlogs.DeleteLogGroupInput{
+				LogGroupName: &name,
+			})
+		})
+		if err != nil {
+			var notFound *logstypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				groupLog.Infof("Log group does not exist, skipping")
+				continue
+			}
+			groupLog.Errorf("Failed to delete log group: %v", err)
+			errs = append(errs, fmt.Errorf("log group(%s): %w", name, err))
+			continue
+		}
+		groupLog.Infof("Deleted log group")
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,28 @@
+package destroyer
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+// 偽物が API インターフェースを満たしていること
+var (
+	_ ecsAPI    = (*fakes.ECS)(nil)
+	_ cfnAPI    = (*fakes.CFN)(nil)
+	_ s3API     = (*fakes.S3)(nil)
+	_ ecrAPI    = (*fakes.ECR)(nil)
+	_ snsAPI    = (*fakes.SNS)(nil)
+	_ sqsAPI    = (*fakes.SQS)(nil)
+	_ elbv2API  = (*fakes.ELBv2)(nil)
+	_ eventsAPI = (*fakes.Events)(nil)
+)
+
+// テスト中はログを出さない
+func TestMain(m *testing.M) {
+	logger = newLogger(io.Discard, "text", slog.LevelInfo, false)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,165 @@
+package destroyer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// 通知の送信にかける時間 (過ぎたら諦める)
+const notifyTimeout = 15 * time.Second
+
+// 通知に載せる失敗メッセージの最大数
+const maxNotifyFailures = 10
+
+// SNS の Subject の最大長 (文字数)
+const maxSNSSubjectLen = 100
+
+// --notify の送信先 (SNS トピック ARN か Slack の Incoming Webhook URL)
+type notifyTarget struct {
+	TopicArn   string
+	Region     string // トピックのリージョン (スタックと違ってもよい)
+	WebhookURL string
+}
+
+// --notify の値を解釈する
+func parseNotifyTarget(value string) (notifyTarget, error) {
+	if arn.IsARN(value) {
+		parsed, err := arn.Parse(value)
+		if err != nil || parsed.Service != "sns" || parsed.Region == "" || parsed.Resource == "" {
+			return notifyTarget{}, fmt.Errorf("not an SNS topic ARN: %s", value)
+		}
+		return notifyTarget{TopicArn: value, Region: parsed.Region}, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return notifyTarget{}, fmt.Errorf("must be an SNS topic ARN or an https:// Slack webhook URL: %s", value)
+	}
+	return notifyTarget{WebhookURL: value}, nil
+}
+
+// 通知の件名と本文を作る
+func notificationMessage(s runSummary, runErr error) (subject, body string) {
+	result := "succeeded"
+	if runErr != nil || s.failed() {
+		result = "FAILED"
+	}
+	prefix := ""
+	if s.DryRun {
+		prefix = "[DryRun] "
+	}
+	var stacks []string
+	for _, st := range s.Stacks {
+		stacks = append(stacks, stackDisplayName(st.Stack))
+	}
+	subject = fmt.Sprintf("%scdk destroy %s: %s", prefix, result, strings.Join(stacks, ", "))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%sTeardown %s\n", prefix, result)
+	fmt.Fprintf(&b, "Account: %s, Region: %s\n", s.Account, s.Region)
+	for _, st := range s.Stacks {
+		fmt.Fprintf(&b, "Stack %s: %s (%d service(s), %d task(s))\n", stackDisplayName(st.Stack), st.Status, st.Services, st.Tasks)
+	}
+	fmt.Fprintf(&b, "cdk destroy: %s\n", s.CdkDestroy)
+	if !s.StartedAt.IsZero() && !s.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, "Duration: %s\n", s.FinishedAt.Sub(s.StartedAt).Round(time.Second))
+	}
+
+	var failures []string
+	for _, st := range s.Stacks {
+		for _, f := range st.Failures {
+			failures = append(failures, fmt.Sprintf("stack(%s): %s", stackDisplayName(st.Stack), f))
+		}
+	}
+	failures = append(failures, s.Failures...)
+	if len(failures) == 0 && runErr != nil {
+		failures = append(failures, runErr.Error())
+	}
+	if len(failures) > 0 {
+		b.WriteString("Failures:\n")
+		for i, f := range failures {
+			if i == maxNotifyFailures {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(failures)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+	}
+	return subject, b.String()
+}
+
+// 実行結果を通知する。失敗しても警告を出すだけで、終了コードには影響させない
+// 中断された場合も送れるよう、ctx のキャンセルは引き継がない
+func sendNotification(ctx context.Context, snsClient snsAPI, target notifyTarget, s runSummary, runErr error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	subject, body := notificationMessage(s, runErr)
+	var err error
+	switch {
+	case target.TopicArn != "":
+		err = publishSNS(ctx, snsClient, target, subject, body)
+	default:
+		err = postSlackWebhook(ctx, target.WebhookURL, body)
+	}
+	if err != nil {
+		logger.Warnf("Failed to send notification (--notify): %v", err)
+		return
+	}
+	logger.Debugf("Notification sent: %s", subject)
+}
+
+func publishSNS(ctx context.Context, snsClient snsAPI, target notifyTarget, subject, body string) error {
+	if snsClient == nil {
+		return errors.New("AWS credentials are not available")
+	}
+	// マルチバイト文字の途中で切らないよう、文字単位で切り詰める
+	if r := []rune(subject); len(r) > maxSNSSubjectLen {
+		subject = string(r[:maxSNSSubjectLen-3]) + "..."
+	}
+	_, err := snsClient.Publish(ctx, &sns.PublishInput{
+		TopicArn: &target.TopicArn,
+		Subject:  aws.String(subject),
+		Message:  aws.String(body),
+	}, func(o *sns.Options) { o.Region = target.Region })
+	if err != nil {
+		return fmt.Errorf("Publish error: %w", err)
+	}
+	return nil
+}
+
+func postSlackWebhook(ctx context.Context, webhookURL, body string) error {
+	payload, err := json.Marshal(map[string]string{"text": body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Webhook URL は秘密情報なのでエラーメッセージに含めない
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to post to the Slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
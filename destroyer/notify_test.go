@@ -0,0 +1,46 @@
+package destroyer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+func TestPublishSNSTruncatesSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"short", "destroy succeeded", "destroy succeeded"},
+		{"exactly the limit", strings.Repeat("a", maxSNSSubjectLen), strings.Repeat("a", maxSNSSubjectLen)},
+		{"ascii", strings.Repeat("a", maxSNSSubjectLen+1), strings.Repeat("a", maxSNSSubjectLen-3) + "..."},
+		{"multibyte", strings.Repeat("削", maxSNSSubjectLen+1), strings.Repeat("削", maxSNSSubjectLen-3) + "..."},
+		{"multibyte within the limit", strings.Repeat("削", maxSNSSubjectLen), strings.Repeat("削", maxSNSSubjectLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := fakes.NewSNS()
+			topicArn := api.AddTopic("notify")
+			if err := publishSNS(context.Background(), api, notifyTarget{TopicArn: topicArn, Region: "us-east-1"}, tt.subject, "body"); err != nil {
+				t.Fatalf("publishSNS: %v", err)
+			}
+			published := api.Published()
+			if len(published) != 1 {
+				t.Fatalf("published %d messages, want 1", len(published))
+			}
+			got := aws.ToString(published[0].Subject)
+			if !utf8.ValidString(got) {
+				t.Errorf("subject %q is not valid UTF-8", got)
+			}
+			if got != tt.want {
+				t.Errorf("subject = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+)
+
+// --check-permissions で権限が足りなかったことを表すエラー
+var errMissingPermissions = errors.New("missing permissions")
+
+// SimulatePrincipalPolicy 1回で指定するアクション数
+const simulateBatchSize = 100
+
+// 有効なオプションで実際に呼ぶ API の IAM アクション (cdk destroy 自体の権限は含まない)
+func requiredActions(opts cleanupOptions) []string {
+	actions := []string{
+		"cloudformation:DescribeStacks",
+		"cloudformation:ListStackResources",
+	}
+	if !opts.SkipDestroy {
+		// cdk destroy の失敗時に DELETE_FAILED のイベントを表示する
+		actions = append(actions, "cloudformation:DescribeStackEvents")
+	}
+	if !opts.SkipDestroy && opts.UseCloudFormation {
+		actions = append(actions, "cloudformation:DeleteStack")
+	}
+	if !opts.SkipDestroy && opts.DisableTermProtect {
+		actions = append(actions, "cloudformation:UpdateTerminationProtection")
+	}
+	if !opts.SkipECS {
+		actions = append(actions,
+			"ecs:DescribeClusters", "ecs:ListServices", "ecs:DescribeServices", "ecs:UpdateService", "ecs:DeleteService",
+			"ecs:ListTasks", "ecs:DescribeTasks", "ecs:StopTask")
+		if len(opts.ClusterLogicalIDs) > 0 {
+			actions = append(actions, "cloudformation:DescribeStackResource")
+		}
+		if opts.ClusterTag != "" {
+			actions = append(actions, "ecs:ListClusters")
+		}
+		if opts.DeleteCluster {
+			actions = append(actions, "ecs:DeleteCluster")
+		}
+		if opts.DeregisterTaskDefs {
+			actions = append(actions, "ecs:ListClusters", "ecs:ListTaskDefinitions", "ecs:DeregisterTaskDefinition")
+		}
+		if opts.ScaleDownASG {
+			actions = append(actions, "ecs:DescribeCapacityProviders", "ecs:ListContainerInstances", "autoscaling:UpdateAutoScalingGroup")
+		}
+		if opts.DrainTargets {
+			actions = append(actions, "elasticloadbalancing:DescribeTargetHealth", "elasticloadbalancing:DeregisterTargets")
+		}
+		if opts.RemoveAutoscaling {
+			actions = append(actions, "application-autoscaling:DescribeScalableTargets", "application-autoscaling:DeregisterScalableTarget")
+		}
+		if opts.DisableScheduledTasks {
+			actions = append(actions, "events:ListRules", "events:ListTargetsByRule", "events:DisableRule", "events:EnableRule")
+		}
+		if opts.CleanupENIs {
+			actions = append(actions, "ec2:DescribeNetworkInterfaces", "ec2:DeleteNetworkInterface")
+		}
+	}
+	if opts.EmptyS3Buckets {
+		actions = append(actions, "s3:ListBucketVersions", "s3:DeleteObject", "s3:DeleteObjectVersion")
+	}
+	if opts.EmptyEcrRepos {
+		actions = append(actions, "cloudformation:GetTemplate", "ecr:ListImages", "ecr:BatchDeleteImage")
+	}
+	if opts.DeleteLogGroups {
+		actions = append(actions, "logs:DeleteLogGroup")
+	}
+	if opts.DisableTableProtect {
+		actions = append(actions, "dynamodb:DescribeTable", "dynamodb:UpdateTable")
+	}
+	if opts.CleanupEFS {
+		actions = append(actions, "elasticfilesystem:DescribeMountTargets", "elasticfilesystem:DeleteMountTarget")
+	}
+	if opts.PurgeQueues {
+		actions = append(actions, "sqs:PurgeQueue")
+	}
+	if opts.DeleteSubscriptions {
+		actions = append(actions, "sns:ListSubscriptionsByTopic", "sns:Unsubscribe")
+	}
+
+	seen := map[string]bool{}
+	var unique []string
+	for _, a := range actions {
+		if !seen[a] {
+			seen[a] = true
+			unique = append(unique, a)
+		}
+	}
+	return unique
+}
+
+// 呼び出し元の ARN を IAM ポリシーのシミュレーションに使えるプリンシパル ARN にする
+// assumed-role のセッション ARN はロール ARN に直す (パス付きのロールは分からないので失敗しうる)
+func simulationPrincipalArn(callerArn string) (string, bool) {
+	parsed, err := arn.Parse(callerArn)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case parsed.Service == "iam" && strings.HasPrefix(parsed.Resource, "user/"):
+		return callerArn, true
+	case parsed.Service == "sts" && strings.HasPrefix(parsed.Resource, "assumed-role/"):
+		parts := strings.Split(parsed.Resource, "/")
+		if len(parts) < 2 {
+			return "", false
+		}
+		return arn.ARN{Partition: parsed.Partition, Service: "iam", AccountID: parsed.AccountID, Resource: "role/" + parts[1]}.String(), true
+	}
+	return "", false
+}
+
+// 変更を伴う API を呼ばずに、必要な権限があるか確認する (--check-permissions)
+//   - 読み取り専用の API を実際に呼んで AccessDenied にならないか確かめる
+//   - 変更を伴う API は iam:SimulatePrincipalPolicy で確かめる (リソースは "*" として評価)
+//
+// 足りない権限があれば一覧を出して errMissingPermissions を返す
+func checkPermissions(ctx context.Context, clients awsClients, target awsTarget, stackNames []string, opts cleanupOptions) error {
+	var missing []string
+
+	// 読み取り専用の呼び出し
+	type probe struct {
+		action string
+		call   func() error
+	}
+	probes := []probe{
+		{"ecs:ListClusters", func() error {
+			_, err := clients.ECS.ListClusters(ctx, &ecs.ListClustersInput{MaxResults: aws.Int32(1)})
+			return err
+		}},
+	}
+	for _, stackName := range stackNames {
+		name := stackDisplayName(stackName)
+		probes = append(probes,
+			probe{"cloudformation:DescribeStacks (" + name + ")", func() error {
+				_, err := describeStack(ctx, clients.CFN, stackName, opts.Retry)
+				return err
+			}},
+			probe{"cloudformation:ListStackResources (" + name + ")", func() error {
+				_, err := listStackResourceIDs(ctx, clients.CFN, stackName, "AWS::ECS::Cluster", opts.Retry)
+				if isStackNotExistError(err) {
+					return nil
+				}
+				return err
+			}},
+		)
+	}
+	for _, p := range probes {
+		err := p.call()
+		switch {
+		case isAccessDeniedError(err):
+			logger.Warnf("Missing permission: %s", p.action)
+			missing = append(missing, p.action)
+		case err != nil:
+			return wrapCancelled(ctx, fmt.Errorf("%s: %w", p.action, err))
+		default:
+			logger.Infof("OK: %s", p.action)
+		}
+	}
+
+	// 変更を伴う呼び出しはシミュレーションで確認する
+	principal, ok := simulationPrincipalArn(target.CallerArn)
+	if !ok {
+		logger.Warnf("Cannot simulate IAM policies for %s; only read-only calls were checked", target.CallerArn)
+	} else {
+		denied, err := simulateActions(ctx, clients.IAM, principal, requiredActions(opts))
+		switch {
+		case isAccessDeniedError(err):
+			logger.Warnf("Cannot simulate IAM policies (iam:SimulatePrincipalPolicy is denied); only read-only calls were checked")
+		case err != nil:
+			return wrapCancelled(ctx, fmt.Errorf("SimulatePrincipalPolicy error: %w", err))
+		default:
+			for _, action := range denied {
+				logger.Warnf("Missing permission: %s (simulated for %s)", action, principal)
+			}
+			missing = append(missing, denied...)
+			if len(denied) == 0 {
+				logger.Infof("OK: %d action(s) allowed for %s (simulated with resource \"*\")", len(requiredActions(opts)), principal)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", errMissingPermissions, strings.Join(missing, ", "))
+	}
+	logger.Infof("All checked permissions are present.")
+	return nil
+}
+
+// アクションを SimulatePrincipalPolicy で評価し、許可されないアクションを返す
+func simulateActions(ctx context.Context, iamClient iamAPI, principalArn string, actions []string) ([]string, error) {
+	var denied []string
+	for start := 0; start < len(actions); start += simulateBatchSize {
+		batch := actions[start:min(start+simulateBatchSize, len(actions))]
+		paginator := iam.NewSimulatePrincipalPolicyPaginator(iamClient, &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     batch,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range page.EvaluationResults {
+				if r.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+					denied = append(denied, aws.ToString(r.EvalActionName))
+				}
+			}
+		}
+	}
+	return denied, nil
+}
+
+// 権限不足のエラーか
+func isAccessDeniedError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "AccessDenied", "AccessDeniedException", "UnauthorizedOperation":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package destroyer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 計画ファイルの形式のバージョン (互換性の無い変更をしたら上げる)
+const planVersion = 1
+
+// --plan-out で書き出し、--plan-in で読み込む実行計画
+// 探索した削除対象と、実行時に使うオプションをそのまま記録する
+type savedPlan struct {
+	Version   int         `json:"version" yaml:"version"`
+	CreatedAt time.Time   `json:"createdAt" yaml:"createdAt"`
+	Account   string      `json:"account" yaml:"account"`
+	Region    string      `json:"region" yaml:"region"`
+	Options   planOptions `json:"options" yaml:"options"`
+	Stacks    []stackPlan `json:"stacks" yaml:"stacks"`
+}
+
+// 計画に記録する処理内容 (--plan-in ではコマンドラインの指定より優先する)
+type planOptions struct {
+	DrainECS              bool `json:"drainEcs" yaml:"drainEcs"`
+	TasksOnly             bool `json:"tasksOnly" yaml:"tasksOnly"`
+	RetainCluster         bool `json:"retainCluster" yaml:"retainCluster"`
+	CdkDestroy            bool `json:"cdkDestroy" yaml:"cdkDestroy"`
+	DeregisterTaskDefs    bool `json:"deregisterTaskDefinitions" yaml:"deregisterTaskDefinitions"`
+	ScaleDownASG          bool `json:"scaleDownAsg" yaml:"scaleDownAsg"`
+	DrainTargets          bool `json:"drainTargets" yaml:"drainTargets"`
+	RemoveAutoscaling     bool `json:"removeAutoscaling" yaml:"removeAutoscaling"`
+	DisableScheduledTasks bool `json:"disableScheduledTasks" yaml:"disableScheduledTasks"`
+	CleanupENIs           bool `json:"cleanupEnis" yaml:"cleanupEnis"`
+	EmptyS3Buckets        bool `json:"emptyS3Buckets" yaml:"emptyS3Buckets"`
+	EmptyEcrRepos         bool `json:"emptyEcrRepositories" yaml:"emptyEcrRepositories"`
+	DeleteLogGroups       bool `json:"deleteLogGroups" yaml:"deleteLogGroups"`
+	DisableTableProtect   bool `json:"disableTableProtection" yaml:"disableTableProtection"`
+	CleanupEFS            bool `json:"cleanupEfs" yaml:"cleanupEfs"`
+	PurgeQueues           bool `json:"purgeQueues" yaml:"purgeQueues"`
+	DeleteSubscriptions   bool `json:"deleteTopicSubscriptions" yaml:"deleteTopicSubscriptions"`
+	DisableTermProtect    bool `json:"disableTerminationProtection" yaml:"disableTerminationProtection"`
+}
+
+// 1スタック分の削除対象
+type stackPlan struct {
+	Stack        string            `json:"stack" yaml:"stack"`
+	Clusters     []clusterServices `json:"clusters,omitempty" yaml:"clusters,omitempty"`
+	Buckets      []string          `json:"s3Buckets,omitempty" yaml:"s3Buckets,omitempty"`
+	Repositories []string          `json:"ecrRepositories,omitempty" yaml:"ecrRepositories,omitempty"`
+	LogGroups    []string          `json:"logGroups,omitempty" yaml:"logGroups,omitempty"`
+	Tables       []string          `json:"dynamodbTables,omitempty" yaml:"dynamodbTables,omitempty"`
+	MountTargets []string          `json:"efsMountTargets,omitempty" yaml:"efsMountTargets,omitempty"`
+	Queues       []string          `json:"sqsQueues,omitempty" yaml:"sqsQueues,omitempty"` // URL
+	Topics       []string          `json:"snsTopics,omitempty" yaml:"snsTopics,omitempty"` // ARN
+}
+
+// 計画に含めるクラスターと、そこで削除するサービス名・止めるタスク
+type clusterServices struct {
+	Name     string   `json:"name" yaml:"name"`
+	Services []string `json:"services" yaml:"services"`
+	// サービスに属さないタスク (RunTask・スケジュールされたタスクなど) の ARN。クラスター全体を片付けるときだけ記録する
+	Tasks []string `json:"tasks,omitempty" yaml:"tasks,omitempty"`
+}
+
+func newPlanOptions(opts cleanupOptions) planOptions {
+	return planOptions{
+		DrainECS:              !opts.SkipECS,
+		TasksOnly:             opts.TasksOnly,
+		RetainCluster:         opts.RetainCluster,
+		CdkDestroy:            !opts.SkipDestroy,
+		DeregisterTaskDefs:    opts.DeregisterTaskDefs,
+		ScaleDownASG:          opts.ScaleDownASG,
+		DrainTargets:          opts.DrainTargets,
+		RemoveAutoscaling:     opts.RemoveAutoscaling,
+		DisableScheduledTasks: opts.DisableScheduledTasks,
+		CleanupENIs:           opts.CleanupENIs,
+		EmptyS3Buckets:        opts.EmptyS3Buckets,
+		EmptyEcrRepos:         opts.EmptyEcrRepos,
+		DeleteLogGroups:       opts.DeleteLogGroups,
+		DisableTableProtect:   opts.DisableTableProtect,
+		CleanupEFS:            opts.CleanupEFS,
+		PurgeQueues:           opts.PurgeQueues,
+		DeleteSubscriptions:   opts.DeleteSubscriptions,
+		DisableTermProtect:    opts.DisableTermProtect,
+	}
+}
+
+// 計画のオプションを opts に反映する
+func (p planOptions) apply(opts *cleanupOptions) {
+	opts.SkipECS = !p.DrainECS
+	opts.TasksOnly = p.TasksOnly
+	opts.RetainCluster = p.RetainCluster
+	opts.SkipDestroy = !p.CdkDestroy
+	opts.DeregisterTaskDefs = p.DeregisterTaskDefs
+	opts.ScaleDownASG = p.ScaleDownASG
+	opts.DrainTargets = p.DrainTargets
+	opts.RemoveAutoscaling = p.RemoveAutoscaling
+	opts.DisableScheduledTasks = p.DisableScheduledTasks
+	opts.CleanupENIs = p.CleanupENIs
+	opts.EmptyS3Buckets = p.EmptyS3Buckets
+	opts.EmptyEcrRepos = p.EmptyEcrRepos
+	opts.DeleteLogGroups = p.DeleteLogGroups
+	opts.DisableTableProtect = p.DisableTableProtect
+	opts.CleanupEFS = p.CleanupEFS
+	opts.PurgeQueues = p.PurgeQueues
+	opts.DeleteSubscriptions = p.DeleteSubscriptions
+	opts.DisableTermProtect = p.DisableTermProtect
+}
+
+// 計画からスタックの削除対象を探す (無ければ nil)
+func (p *savedPlan) stack(name string) *stackPlan {
+	for i := range p.Stacks {
+		if p.Stacks[i].Stack == name {
+			return &p.Stacks[i]
+		}
+	}
+	return nil
+}
+
+func (p *savedPlan) stackNames() []string {
+	names := make([]string, 0, len(p.Stacks))
+	for _, s := range p.Stacks {
+		names = append(names, s.Stack)
+	}
+	return names
+}
+
+// 計画で指定されたクラスターのサービス (計画に無いクラスターなら nil)
+func (s *stackPlan) services(clusterName string) []string {
+	for _, c := range s.Clusters {
+		if c.Name == clusterName {
+			return c.Services
+		}
+	}
+	return nil
+}
+
+// 計画で指定されたクラスターの、サービスに属さないタスク (計画に無いクラスターなら nil)
+func (s *stackPlan) tasks(clusterName string) []string {
+	for _, c := range s.Clusters {
+		if c.Name == clusterName {
+			return c.Tasks
+		}
+	}
+	return nil
+}
+
+// 拡張子が .yaml / .yml なら YAML、それ以外は JSON で書く
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func (p savedPlan) writeFile(path string) error {
+	var data []byte
+	var err error
+	if isYAMLPath(path) {
+		data, err = yaml.Marshal(p)
+	} else {
+		data, err = json.MarshalIndent(p, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// 計画ファイルを読む (JSON は YAML のサブセットなので YAML として読む)
+func readPlanFile(path string) (*savedPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p savedPlan
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("%s: unsupported plan version %d (want %d)", path, p.Version, planVersion)
+	}
+	if p.Account == "" || p.Region == "" {
+		return nil, fmt.Errorf("%s: account and region are required", path)
+	}
+	if len(p.Stacks) == 0 {
+		return nil, fmt.Errorf("%s: no stacks in the plan", path)
+	}
+	return &p, nil
+}
+
+// 探索した削除対象を計画として記録する (サービスは対象外のものを除いた名前で持つ)
+func buildStackPlan(ctx context.Context, ecsClient ecsAPI, stackName string, clusterNames []string, bucketNames []string, repos []ecrRepository, logGroups []string, tables []string, mountTargets []string, opts cleanupOptions) (stackPlan, error) {
+	sp := stackPlan{Stack: stackName, Buckets: bucketNames, LogGroups: logGroups, Tables: tables, MountTargets: mountTargets}
+	for _, clusterName := range clusterNames {
+		serviceArns, err := targetServiceArns(ctx, ecsClient, clusterName, opts)
+		if err != nil {
+			return sp, fmt.Errorf("Failed to list ECS services in cluster(%s): %w", clusterName, err)
+		}
+		services := []string{}
+		for _, arn := range serviceArns {
+			if name := arnToName(arn); opts.ServiceFilter.match(name) {
+				services = append(services, name)
+			}
+		}
+		cs := clusterServices{Name: clusterName, Services: services}
+		// --service などでサービスを絞っていなければ、サービスに属さないタスクも止める対象になる
+		if !opts.servicesSelected() {
+			cs.Tasks, err = listStandaloneTaskArns(ctx, ecsClient, clusterName, opts.Retry)
+			if err != nil {
+				return sp, fmt.Errorf("Failed to list ECS tasks in cluster(%s): %w", clusterName, err)
+			}
+		}
+		sp.Clusters = append(sp.Clusters, cs)
+	}
+	for _, r := range repos {
+		if !r.EmptyOnDelete {
+			sp.Repositories = append(sp.Repositories, r.Name)
+		}
+	}
+	return sp, nil
+}
+
+// 計画にあり、今も存在するものだけを残す
+// 計画に無いもの (計画後に増えたもの) は触らず、消えたものは処理しない
+func keepPlanned[T any](ctx context.Context, kind string, found []T, planned []string, name func(T) string) []T {
+	var kept []T
+	for _, v := range found {
+		if slices.Contains(planned, name(v)) {
+			kept = append(kept, v)
+		} else {
+			loggerFrom(ctx).Warnf("%s %s is not in the plan, leaving it untouched", kind, name(v))
+		}
+	}
+	for _, p := range planned {
+		if !slices.ContainsFunc(found, func(v T) bool { return name(v) == p }) {
+			loggerFrom(ctx).Warnf("%s %s in the plan no longer exists, skipping", kind, p)
+		}
+	}
+	return kept
+}
+
+func identity(s string) string { return s }
+
+// 探索し直した結果を計画の内容に絞り込む
+func restrictToPlan(ctx context.Context, sp *stackPlan, clusterNames []string, bucketNames []string, repos []ecrRepository, logGroups []string, tables []string, mountTargets []string) ([]string, []string, []ecrRepository, []string, []string, []string) {
+	planned := make([]string, 0, len(sp.Clusters))
+	for _, c := range sp.Clusters {
+		planned = append(planned, c.Name)
+	}
+	clusterNames = keepPlanned(ctx, "ECS cluster", clusterNames, planned, identity)
+	bucketNames = keepPlanned(ctx, "S3 bucket", bucketNames, sp.Buckets, identity)
+	// EmptyOnDelete のリポジトリは計画に載らないが、何もしないのでそのまま残す
+	var emptyOnDelete, others []ecrRepository
+	for _, r := range repos {
+		if r.EmptyOnDelete {
+			emptyOnDelete = append(emptyOnDelete, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	repos = append(emptyOnDelete, keepPlanned(ctx, "ECR repository", others, sp.Repositories, func(r ecrRepository) string { return r.Name })...)
+	logGroups = keepPlanned(ctx, "Log group", logGroups, sp.LogGroups, identity)
+	tables = keepPlanned(ctx, "DynamoDB table", tables, sp.Tables, identity)
+	mountTargets = keepPlanned(ctx, "EFS mount target", mountTargets, sp.MountTargets, identity)
+	return clusterNames, bucketNames, repos, logGroups, tables, mountTargets
+}
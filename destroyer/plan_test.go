@@ -0,0 +1,40 @@
+package destroyer
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+func TestBuildStackPlanRecordsStandaloneTasks(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	api.AddService("app", "web", 2)
+	standalone := api.AddTask("app", ecstypes.Task{})
+
+	sp, err := buildStackPlan(context.Background(), api, "stack", []string{"app"}, nil, nil, nil, nil, nil, testCleanupOptions())
+	if err != nil {
+		t.Fatalf("buildStackPlan: %v", err)
+	}
+	if got, want := sp.services("app"), []string{"web"}; !slices.Equal(got, want) {
+		t.Errorf("services = %v, want %v", got, want)
+	}
+	if got, want := sp.tasks("app"), []string{standalone}; !slices.Equal(got, want) {
+		t.Errorf("tasks = %v, want %v (only the task outside services)", got, want)
+	}
+
+	// サービスを絞っているときはサービスに属さないタスクを止めないので、記録もしない
+	opts := testCleanupOptions()
+	opts.Services = []string{"web"}
+	sp, err = buildStackPlan(context.Background(), api, "stack", []string{"app"}, nil, nil, nil, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("buildStackPlan with --service: %v", err)
+	}
+	if got := sp.tasks("app"); len(got) != 0 {
+		t.Errorf("tasks with --service = %v, want none", got)
+	}
+}
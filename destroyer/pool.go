@@ -0,0 +1,63 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// items を最大 limit 並列で fn に渡し、失敗したものをまとめて返す
+func runConcurrently[T any](items []T, limit int, fn func(item T) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, max(limit, 1))
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(item); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// 名前ごとの排他 (--parallel-stacks で同じクラスターを複数のスタックが同時に処理しないようにする)
+// nil なら排他しない
+type keyedLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func newKeyedLocks() *keyedLocks {
+	return &keyedLocks{locks: map[string]chan struct{}{}}
+}
+
+// key のロックを取れるまで待ち、解放する関数を返す (ctx が終わったらそのエラーを返す)
+func (k *keyedLocks) lock(ctx context.Context, key string) (unlock func(), err error) {
+	if k == nil {
+		return func() {}, nil
+	}
+	k.mu.Lock()
+	ch, ok := k.locks[key]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		k.locks[key] = ch
+	}
+	k.mu.Unlock()
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package destroyer
+
+import (
+	"context"
+	"time"
+)
+
+// 長い待機の間、interval ごとに経過時間と status() の内容をログに出す (interval が 0 なら何もしない)
+// 並列に複数の待機が走るので、端末でも1行を書き換えず通常のログ行として出す
+// 戻り値の関数で止める
+func startProgress(ctx context.Context, log appLogger, interval time.Duration, status func(ctx context.Context) string) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			elapsed := time.Since(start).Round(time.Second)
+			if s := status(ctx); s != "" {
+				log.Infof("Still waiting (%s elapsed): %s", elapsed, s)
+			} else {
+				log.Infof("Still waiting (%s elapsed)...", elapsed)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,161 @@
+package destroyer
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 確認プロンプトに表示する削除対象
+type destroyPlan struct {
+	StackName    string
+	UpdatedAt    time.Time // スタックを最後に更新した時刻
+	Account      string
+	Region       string
+	Clusters     []clusterPlan
+	Buckets      []string
+	Repos        []ecrRepository
+	LogGroups    []string
+	Tables       []string
+	MountTargets []string
+	Queues       []string // URL
+	Topics       []string // ARN
+}
+
+// 確認プロンプト用のクラスターごとの削除対象数
+type clusterPlan struct {
+	Name     string
+	Services int
+	Tasks    int
+	Status   string // 削除済み・削除中で処理しないときだけその状態
+}
+
+// 各クラスターの処理対象のサービス数・実行中タスク数を数える
+// --service・計画・--retain-cluster と --service-include / --service-exclude を、削除するときと同じように反映する
+func describeClusterPlans(ctx context.Context, ecsClient ecsAPI, stackName string, clusterNames []string, opts cleanupOptions) ([]clusterPlan, error) {
+	var plans []clusterPlan
+	for _, clusterName := range clusterNames {
+		status, err := describeClusterStatus(ctx, ecsClient, clusterName, opts.Retry)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case "", "INACTIVE", "DEPROVISIONING":
+			plans = append(plans, clusterPlan{Name: clusterName, Status: cmp.Or(status, "not found")})
+			continue
+		}
+		clusterOpts := opts.forCluster(stackName, clusterName)
+		serviceArns, err := targetServiceArns(ctx, ecsClient, clusterName, clusterOpts)
+		if err != nil {
+			return nil, err
+		}
+		var services []string
+		for _, svc := range serviceArns {
+			if clusterOpts.ServiceFilter.match(arnToName(svc)) {
+				services = append(services, svc)
+			}
+		}
+		tasks, err := countTargetTasks(ctx, ecsClient, clusterName, services, clusterOpts)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, clusterPlan{Name: clusterName, Services: len(services), Tasks: tasks})
+	}
+	return plans, nil
+}
+
+// 止める対象の実行中・起動中のタスク数 (stopRemainingTasks と同じ範囲)
+func countTargetTasks(ctx context.Context, ecsClient ecsAPI, clusterName string, services []string, opts cleanupOptions) (int, error) {
+	// サービスを絞らず、絞り込みも無ければクラスターの全タスクが対象
+	if !opts.servicesSelected() && !opts.ServiceFilter.enabled() {
+		taskArns, err := listActiveTaskArns(ctx, ecsClient, clusterName, "", opts.Retry)
+		return len(taskArns), err
+	}
+	count := 0
+	for _, svc := range services {
+		taskArns, err := listActiveTaskArns(ctx, ecsClient, clusterName, arnToName(svc), opts.Retry)
+		if err != nil {
+			return 0, err
+		}
+		count += len(taskArns)
+	}
+	// サービスを絞っていなければサービスに属さないタスクも、計画があれば計画にあるタスクも止める
+	var others []string
+	var err error
+	switch {
+	case !opts.servicesSelected():
+		others, err = listStandaloneTaskArns(ctx, ecsClient, clusterName, opts.Retry)
+	case len(opts.Tasks) > 0:
+		others, err = listNotStoppedTasks(ctx, ecsClient, clusterName, opts.Tasks, opts.Retry)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count + len(others), nil
+}
+
+// 削除内容を表示し、スタック名の入力で確認する (EOF や不一致なら false)
+// in は実行全体で共有する (プロンプトごとに作ると、先読みされた次の回答が捨てられる)
+func confirmDestroy(in *bufio.Reader, out io.Writer, plan destroyPlan) bool {
+	fmt.Fprintln(out, "The following resources will be deleted:")
+	if plan.StackName != "" {
+		fmt.Fprintf(out, "  Stack:   %s\n", plan.StackName)
+		if !plan.UpdatedAt.IsZero() {
+			fmt.Fprintf(out, "  Last updated: %s (%s ago)\n", plan.UpdatedAt.Local().Format(time.RFC3339), time.Since(plan.UpdatedAt).Round(time.Second))
+		}
+	}
+	fmt.Fprintf(out, "  Account: %s\n", plan.Account)
+	fmt.Fprintf(out, "  Region:  %s\n", plan.Region)
+	if len(plan.Clusters) == 0 {
+		fmt.Fprintln(out, "  Cluster: (none)")
+	}
+	for _, c := range plan.Clusters {
+		if c.Status != "" {
+			fmt.Fprintf(out, "  Cluster: %s (%s, will be skipped)\n", c.Name, c.Status)
+			continue
+		}
+		fmt.Fprintf(out, "  Cluster: %s (services: %d, running tasks: %d)\n", c.Name, c.Services, c.Tasks)
+	}
+	for _, b := range plan.Buckets {
+		fmt.Fprintf(out, "  S3 bucket to empty: %s\n", b)
+	}
+	for _, r := range plan.Repos {
+		if !r.EmptyOnDelete {
+			fmt.Fprintf(out, "  ECR repository to empty: %s\n", r.Name)
+		}
+	}
+	for _, g := range plan.LogGroups {
+		fmt.Fprintf(out, "  Log group to delete: %s\n", g)
+	}
+	for _, t := range plan.Tables {
+		fmt.Fprintf(out, "  DynamoDB table to unprotect: %s\n", t)
+	}
+	for _, mt := range plan.MountTargets {
+		fmt.Fprintf(out, "  EFS mount target to delete: %s\n", mt)
+	}
+	for _, t := range plan.Topics {
+		fmt.Fprintf(out, "  SNS topic to unsubscribe all from: %s\n", arnToName(t))
+	}
+	for _, q := range plan.Queues {
+		fmt.Fprintf(out, "  SQS queue to purge: %s\n", lastPathSegment(q))
+	}
+	// スタックが無い (--cluster のみ) ときは "yes" の入力で確認する
+	want := plan.StackName
+	if want == "" {
+		want = "yes"
+		fmt.Fprint(out, "Type yes to proceed: ")
+	} else {
+		fmt.Fprintf(out, "Type the stack name (%s) to proceed: ", want)
+	}
+
+	line, err := in.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(out)
+		return false
+	}
+	return strings.TrimSpace(line) == want
+}
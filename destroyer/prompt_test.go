@@ -0,0 +1,95 @@
+package destroyer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+func TestConfirmDestroySharesInputAcrossPrompts(t *testing.T) {
+	// 2つの回答がまとめて届いても、2回目のプロンプトが2行目を読めること
+	in := bufio.NewReader(strings.NewReader("first\nsecond\n"))
+	if !confirmDestroy(in, io.Discard, destroyPlan{StackName: "first"}) {
+		t.Error("first prompt: got false, want true")
+	}
+	if !confirmDestroy(in, io.Discard, destroyPlan{StackName: "second"}) {
+		t.Error("second prompt: got false, want true (the answer was lost)")
+	}
+	if confirmDestroy(in, io.Discard, destroyPlan{StackName: "third"}) {
+		t.Error("third prompt at EOF: got true, want false")
+	}
+}
+
+func TestConfirmDestroyAnswers(t *testing.T) {
+	tests := []struct {
+		name  string
+		plan  destroyPlan
+		input string
+		want  bool
+	}{
+		{"stack name", destroyPlan{StackName: "app"}, "app\n", true},
+		{"surrounding spaces", destroyPlan{StackName: "app"}, "  app  \n", true},
+		{"wrong stack name", destroyPlan{StackName: "app"}, "ap\n", false},
+		{"yes without a stack", destroyPlan{}, "yes\n", true},
+		{"yes for a stack", destroyPlan{StackName: "app"}, "yes\n", false},
+		{"no newline", destroyPlan{StackName: "app"}, "app", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := confirmDestroy(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, tt.plan); got != tt.want {
+				t.Errorf("confirmDestroy(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeClusterPlansCountsOnlyTargetServices(t *testing.T) {
+	// 共有クラスター: スタックのサービス web・worker と、他のサービス other、サービスに属さないタスク1つ
+	api := fakes.NewECS()
+	api.AddCluster("shared")
+	web := api.AddService("shared", "web", 2)
+	api.AddService("shared", "worker", 1)
+	api.AddService("shared", "other", 3)
+	api.AddTask("shared", ecstypes.Task{})
+	elsewhere := "arn:aws:ecs:us-east-1:123456789012:service/batch/job"
+
+	tests := []struct {
+		name         string
+		opts         func(*cleanupOptions)
+		wantServices int
+		wantTasks    int
+	}{
+		{"whole cluster", func(o *cleanupOptions) {}, 3, 7},
+		{"service exclude", func(o *cleanupOptions) { o.ServiceFilter.Exclude = regexp.MustCompile("^other$") }, 2, 4},
+		{"service include", func(o *cleanupOptions) { o.ServiceFilter.Include = regexp.MustCompile("^w") }, 2, 4},
+		{"selected service", func(o *cleanupOptions) { o.Services = []string{"worker"} }, 1, 1},
+		{"retain cluster", func(o *cleanupOptions) { o.RetainCluster, o.Services = true, []string{web, elsewhere} }, 1, 2},
+		{"retain cluster and filter", func(o *cleanupOptions) {
+			o.RetainCluster, o.Services = true, []string{web, elsewhere}
+			o.ServiceFilter.Exclude = regexp.MustCompile("^web$")
+		}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testCleanupOptions()
+			tt.opts(&opts)
+			plans, err := describeClusterPlans(context.Background(), api, "app", []string{"shared"}, opts)
+			if err != nil {
+				t.Fatalf("describeClusterPlans: %v", err)
+			}
+			if len(plans) != 1 {
+				t.Fatalf("plans = %+v, want 1 cluster", plans)
+			}
+			if p := plans[0]; p.Services != tt.wantServices || p.Tasks != tt.wantTasks {
+				t.Errorf("services, tasks = %d, %d, want %d, %d", p.Services, p.Tasks, tt.wantServices, tt.wantTasks)
+			}
+		})
+	}
+}
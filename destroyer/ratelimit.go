@@ -0,0 +1,98 @@
+package destroyer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// AWS API 呼び出しの全体の上限 (--qps) を守るトークンバケット
+// サービス・クラスターの並列処理やリージョンをまたいで1つを共有する。nil なら制限しない
+type rateLimiter struct {
+	mu     sync.Mutex
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// 1秒あたり qps 回まで呼べる limiter (qps が 0 以下なら nil)
+// 一度に使えるのは最大 1 秒分 (1 未満なら 1 回) まで
+func newRateLimiter(qps float64) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	burst := max(qps, 1)
+	return &rateLimiter{qps: qps, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// トークンを1つ取れるまで待つ (ctx が終わったらそのエラーを返す)
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.qps)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.qps * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// 呼び出し回数の多い ECS の API を limiter に通す ecsAPI (waiter の DescribeServices も含む)
+type rateLimitedECS struct {
+	ecsAPI
+	limiter *rateLimiter
+}
+
+func (c rateLimitedECS) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.ecsAPI.ListServices(ctx, params, optFns...)
+}
+
+func (c rateLimitedECS) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.ecsAPI.DescribeServices(ctx, params, optFns...)
+}
+
+func (c rateLimitedECS) UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.ecsAPI.UpdateService(ctx, params, optFns...)
+}
+
+func (c rateLimitedECS) DeleteService(ctx context.Context, params *ecs.DeleteServiceInput, optFns ...func(*ecs.Options)) (*ecs.DeleteServiceOutput, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.ecsAPI.DeleteService(ctx, params, optFns...)
+}
+
+func (c rateLimitedECS) StopTask(ctx context.Context, params *ecs.StopTaskInput, optFns ...func(*ecs.Options)) (*ecs.StopTaskOutput, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.ecsAPI.StopTask(ctx, params, optFns...)
+}
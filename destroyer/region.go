@@ -0,0 +1,84 @@
+package destroyer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ./internal/genregions -o regions_generated.go
+
+// SDK のパーティション (aws、aws-cn など) とそのリージョン
+type sdkPartition struct {
+	ID      string
+	Regions []string
+}
+
+// SDK (aws-sdk-go-v2 の partitions.json) が知っているリージョン
+// 一覧は regions_generated.go に go generate で生成する。新しいリージョンや独自パーティションは --allow-unknown-region で通す
+var knownRegions = map[string]bool{}
+
+func init() {
+	for _, p := range sdkPartitions {
+		for _, r := range p.Regions {
+			knownRegions[r] = true
+		}
+	}
+}
+
+// リージョン名が既知か確認し、タイプミスなら近い候補を挙げる
+func validateRegion(region string) error {
+	if knownRegions[region] {
+		return nil
+	}
+	if close := closeRegions(region); len(close) > 0 {
+		return fmt.Errorf("unknown region %q (did you mean %s?)", region, strings.Join(close, " / "))
+	}
+	return fmt.Errorf("unknown region %q", region)
+}
+
+// 編集距離が2以下の既知リージョン (近い順)
+func closeRegions(region string) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+	var candidates []candidate
+	for r := range knownRegions {
+		if d := editDistance(region, r); d <= 2 {
+			candidates = append(candidates, candidate{r, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.name
+	}
+	return names
+}
+
+// レーベンシュタイン距離
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
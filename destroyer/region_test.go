@@ -0,0 +1,54 @@
+package destroyer
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateRegion(t *testing.T) {
+	for _, region := range []string{"us-east-1", "ap-northeast-1", "cn-north-1", "us-gov-west-1"} {
+		if err := validateRegion(region); err != nil {
+			t.Errorf("validateRegion(%q): %v", region, err)
+		}
+	}
+
+	err := validateRegion("us-east-11")
+	if err == nil {
+		t.Fatal("validateRegion(us-east-11) succeeded, want an unknown region error")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `unknown region "us-east-11"`) || !strings.Contains(msg, "us-east-1") {
+		t.Errorf("error %q does not suggest us-east-1", msg)
+	}
+	if err := validateRegion("mars-north-1"); err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("validateRegion(mars-north-1) = %v, want an unknown region error without suggestions", err)
+	}
+}
+
+// regions_generated.go が go.mod の SDK の partitions.json と一致していること (SDK を更新したら go generate する)
+func TestRegionsGeneratedUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the generator")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	out := filepath.Join(t.TempDir(), "regions_generated.go")
+	if b, err := exec.Command("go", "run", "./internal/genregions", "-o", out).CombinedOutput(); err != nil {
+		t.Skipf("genregions failed (SDK module not available?): %v\n%s", err, b)
+	}
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("regions_generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("regions_generated.go is out of date; run go generate ./destroyer")
+	}
+}
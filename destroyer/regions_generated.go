@@ -0,0 +1,13 @@
+// Code generated by genregions from github.com/aws/aws-sdk-go-v2@v1.32.7 partitions.json; DO NOT EDIT.
+
+package destroyer
+
+var sdkPartitions = []sdkPartition{
+	{ID: "aws", Regions: []string{"af-south-1", "ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-south-2", "ap-southeast-1", "ap-southeast-2", "ap-southeast-3", "ap-southeast-4", "ap-southeast-5", "ca-central-1", "ca-west-1", "eu-central-1", "eu-central-2", "eu-north-1", "eu-south-1", "eu-south-2", "eu-west-1", "eu-west-2", "eu-west-3", "il-central-1", "me-central-1", "me-south-1", "sa-east-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2"}},
+	{ID: "aws-cn", Regions: []string{"cn-north-1", "cn-northwest-1"}},
+	{ID: "aws-us-gov", Regions: []string{"us-gov-east-1", "us-gov-west-1"}},
+	{ID: "aws-iso", Regions: []string{"us-iso-east-1", "us-iso-west-1"}},
+	{ID: "aws-iso-b", Regions: []string{"us-isob-east-1"}},
+	{ID: "aws-iso-e", Regions: []string{"eu-isoe-west-1"}},
+	{ID: "aws-iso-f", Regions: []string{}},
+}
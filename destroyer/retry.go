@@ -0,0 +1,68 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// スロットリングとみなす AWS API のエラーコード
+var throttlingErrorCodes = map[string]bool{
+	"Throttling":                true,
+	"ThrottlingException":       true,
+	"ThrottledException":        true,
+	"RequestThrottled":          true,
+	"RequestThrottledException": true,
+	"RequestLimitExceeded":      true,
+	"TooManyRequestsException":  true,
+}
+
+// AWS API 呼び出しのリトライ設定
+type retryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func newRetryPolicy(maxRetries int) retryPolicy {
+	return retryPolicy{
+		MaxRetries: maxRetries,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   20 * time.Second,
+	}
+}
+
+// スロットリングエラーか判定 (権限エラーなどはリトライしない)
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && throttlingErrorCodes[apiErr.ErrorCode()]
+}
+
+// fn をスロットリング時のみ指数バックオフ + ジッターでリトライする
+func withRetry[T any](ctx context.Context, p retryPolicy, op string, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		out, err := fn()
+		if err == nil || !isThrottlingError(err) || attempt >= p.MaxRetries {
+			return out, wrapCancelled(ctx, err)
+		}
+		delay := p.backoff(attempt)
+		loggerFrom(ctx).Warnf("%s throttled, retrying in %s (%d/%d): %v", op, delay.Round(time.Millisecond), attempt+1, p.MaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return out, wrapCancelled(ctx, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt 回目の待ち時間 (full jitter)
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(d))) + 1
+}
@@ -0,0 +1,59 @@
+package destroyer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+// 最初の n 回だけ err で失敗させる fakes の Err
+func failFirst(n int, err error) func(op string, input any) error {
+	var calls atomic.Int32
+	return func(string, any) error {
+		if calls.Add(1) <= int32(n) {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestWithRetryThrottledThenSucceeds(t *testing.T) {
+	api := fakes.NewECS()
+	api.AddCluster("app")
+	api.Err = failFirst(2, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"})
+
+	ctx := context.Background()
+	out, err := withRetry(ctx, testCleanupOptions().Retry, "ListClusters", func() (*ecs.ListClustersOutput, error) {
+		return api.ListClusters(ctx, &ecs.ListClustersInput{})
+	})
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if len(out.ClusterArns) != 1 {
+		t.Errorf("clusters = %v, want 1", out.ClusterArns)
+	}
+	if got := api.CallCount("ListClusters"); got != 3 {
+		t.Errorf("ListClusters called %d times, want 3", got)
+	}
+}
+
+func TestWithRetryDoesNotRetryOtherErrors(t *testing.T) {
+	api := fakes.NewECS()
+	api.Err = failFirst(1, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"})
+
+	ctx := context.Background()
+	_, err := withRetry(ctx, testCleanupOptions().Retry, "ListClusters", func() (*ecs.ListClustersOutput, error) {
+		return api.ListClusters(ctx, &ecs.ListClustersInput{})
+	})
+	if err == nil {
+		t.Fatal("withRetry succeeded, want AccessDeniedException")
+	}
+	if got := api.CallCount("ListClusters"); got != 1 {
+		t.Errorf("ListClusters called %d times, want 1", got)
+	}
+}
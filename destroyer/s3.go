@@ -0,0 +1,105 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DeleteObjects 1回で削除できる最大件数
+const s3DeleteBatchSize = 1000
+
+// スタック内の S3 バケット名を取得
+func getS3BucketNamesFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::S3::Bucket", retry)
+}
+
+// S3 クライアントを作成
+func newS3Client(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// カスタムエンドポイント (LocalStack など) は仮想ホスト形式に対応していないことが多い
+		if cfg.BaseEndpoint != nil {
+			o.UsePathStyle = true
+		}
+	})
+}
+
+// 各バケットを空にする (dryRun 時は削除対象数の表示のみ)。削除したオブジェクト数の合計を返す
+func emptyS3Buckets(ctx context.Context, s3Client s3API, bucketNames []string, opts cleanupOptions) (int, error) {
+	var total int
+	var errs []error
+	for _, bucket := range bucketNames {
+		bucketLog := loggerFrom(ctx).With("bucket", bucket)
+		n, err := emptyS3Bucket(ctx, s3Client, bucket, opts)
+		total += n
+		if err != nil {
+			bucketLog.Errorf("Failed to empty bucket: %v", err)
+			errs = append(errs, fmt.Errorf("bucket(%s): %w", bucket, err))
+			continue
+		}
+		if opts.DryRun {
+			bucketLog.Infof("[DryRun] Would delete %d object version(s)/delete marker(s)", n)
+		} else {
+			bucketLog.Infof("Deleted %d object version(s)/delete marker(s)", n)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// バケット内の全オブジェクトのバージョンと削除マーカーを削除
+func emptyS3Bucket(ctx context.Context, s3Client s3API, bucket string, opts cleanupOptions) (int, error) {
+	paginator := s3.NewListObjectVersionsPaginator(s3Client, &s3.ListObjectVersionsInput{
+		Bucket: &bucket,
+	})
+
+	var deleted int
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, opts.Retry, "ListObjectVersions", func() (*s3.ListObjectVersionsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			var noSuchBucket *s3types.NoSuchBucket
+			if errors.As(err, &noSuchBucket) {
+				loggerFrom(ctx).With("bucket", bucket).Infof("Bucket does not exist, skipping")
+				return deleted, nil
+			}
+			return deleted, fmt.Errorf("ListObjectVersions error: %w", err)
+		}
+
+		var objects []s3types.ObjectIdentifier
+		for _, v := range page.Versions {
+			objects = append(objects, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+		if opts.DryRun {
+			deleted += len(objects)
+			continue
+		}
+
+		for start := 0; start < len(objects); start += s3DeleteBatchSize {
+			batch := objects[start:min(start+s3DeleteBatchSize, len(objects))]
+			out, err := withRetry(ctx, opts.Retry, "DeleteObjects", func() (*s3.DeleteObjectsOutput, error) {
+				return s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+					Bucket: &bucket,
+					Delete: &s3types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+				})
+			})
+			if err != nil {
+				return deleted, fmt.Errorf("DeleteObjects error: %w", err)
+			}
+			if len(out.Errors) > 0 {
+				e := out.Errors[0]
+				return deleted, fmt.Errorf("DeleteObjects failed for %d object(s), e.g. %s: %s",
+					len(out.Errors), aws.ToString(e.Key), aws.ToString(e.Message))
+			}
+			deleted += len(batch)
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,36 @@
+package destroyer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+func TestEmptyS3BucketDeletesVersionsAndDeleteMarkers(t *testing.T) {
+	api := fakes.NewS3()
+	api.PageSize = 2
+	api.PutObject("assets", "a.txt")
+	api.PutObject("assets", "a.txt")
+	api.DeleteObject("assets", "a.txt")
+	api.PutObject("assets", "b.txt")
+	api.DeleteObject("assets", "c.txt")
+
+	deleted, err := emptyS3Bucket(context.Background(), api, "assets", testCleanupOptions())
+	if err != nil {
+		t.Fatalf("emptyS3Bucket: %v", err)
+	}
+	if deleted != 5 {
+		t.Errorf("deleted = %d, want 5", deleted)
+	}
+	if versions, markers := api.Versions("assets"); versions != 0 || markers != 0 {
+		t.Errorf("left %d version(s) and %d delete marker(s), want none", versions, markers)
+	}
+}
+
+func TestEmptyS3BucketMissingBucket(t *testing.T) {
+	deleted, err := emptyS3Bucket(context.Background(), fakes.NewS3(), "gone", testCleanupOptions())
+	if err != nil || deleted != 0 {
+		t.Errorf("emptyS3Bucket = %d, %v; want 0, nil", deleted, err)
+	}
+}
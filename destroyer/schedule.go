@@ -0,0 +1,118 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// クラスターで ECS タスクを起動する EventBridge ルール (default バス) の名前を取得
+// 有効なルールだけを返す (無効なルールは新しいタスクを起動しない)
+func listScheduledTaskRules(ctx context.Context, eventsClient eventsAPI, clusterName string, retry retryPolicy) ([]string, error) {
+	var rules []string
+	input := &eventbridge.ListRulesInput{}
+	for {
+		page, err := withRetry(ctx, retry, "ListRules", func() (*eventbridge.ListRulesOutput, error) {
+			return eventsClient.ListRules(ctx, input)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListRules error: %w", err)
+		}
+		for _, rule := range page.Rules {
+			if rule.State != ebtypes.RuleStateEnabled {
+				continue
+			}
+			targetsCluster, err := ruleTargetsCluster(ctx, eventsClient, aws.ToString(rule.Name), clusterName, retry)
+			if err != nil {
+				return nil, err
+			}
+			if targetsCluster {
+				rules = append(rules, aws.ToString(rule.Name))
+			}
+		}
+		if page.NextToken == nil {
+			return rules, nil
+		}
+		input.NextToken = page.NextToken
+	}
+}
+
+// ルールのターゲットにクラスターでの ECS タスク起動が含まれるか
+func ruleTargetsCluster(ctx context.Context, eventsClient eventsAPI, ruleName, clusterName string, retry retryPolicy) (bool, error) {
+	input := &eventbridge.ListTargetsByRuleInput{Rule: aws.String(ruleName)}
+	for {
+		page, err := withRetry(ctx, retry, "ListTargetsByRule", func() (*eventbridge.ListTargetsByRuleOutput, error) {
+			return eventsClient.ListTargetsByRule(ctx, input)
+		})
+		if err != nil {
+			return false, fmt.Errorf("ListTargetsByRule(%s) error: %w", ruleName, err)
+		}
+		for _, t := range page.Targets {
+			// ECS タスクのターゲットの Arn はクラスター ARN
+			arn := aws.ToString(t.Arn)
+			if t.EcsParameters != nil && strings.Contains(arn, ":ecs:") && arnToName(arn) == clusterName {
+				return true, nil
+			}
+		}
+		if page.NextToken == nil {
+			return false, nil
+		}
+		input.NextToken = page.NextToken
+	}
+}
+
+// クラスターでタスクを起動する EventBridge ルールを無効化する (dryRun 時は表示のみ)
+// 無効化したルール名を返す。失敗しても、それまでに無効化したルールは返す
+func disableScheduledTaskRules(ctx context.Context, eventsClient eventsAPI, clusterName string, opts cleanupOptions) ([]string, error) {
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	rules, err := listScheduledTaskRules(ctx, eventsClient, clusterName, opts.Retry)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		clusterLog.Infof("No EventBridge rules start tasks in cluster: %s", clusterName)
+		return nil, nil
+	}
+
+	var disabled []string
+	for _, rule := range rules {
+		ruleLog := clusterLog.With("rule", rule)
+		if opts.DryRun {
+			ruleLog.Infof("[DryRun] Would disable EventBridge rule")
+			disabled = append(disabled, rule)
+			continue
+		}
+		ruleLog.Infof("Disabling EventBridge rule...")
+		_, err := withRetry(ctx, opts.Retry, "DisableRule", func() (*eventbridge.DisableRuleOutput, error) {
+			return eventsClient.DisableRule(ctx, &eventbridge.DisableRuleInput{Name: aws.String(rule)})
+		})
+		if err != nil {
+			return disabled, fmt.Errorf("rule(%s): DisableRule error: %w", rule, err)
+		}
+		disabled = append(disabled, rule)
+	}
+	return disabled, nil
+}
+
+// クリーンアップに失敗したときに、無効化したルールを元に戻す
+// 中断された場合も戻せるよう ctx のキャンセルは引き継がない
+func enableScheduledTaskRules(ctx context.Context, eventsClient eventsAPI, clusterName string, rules []string, retry retryPolicy) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, rule := range rules {
+		loggerFrom(ctx).With("cluster", clusterName, "rule", rule).Infof("Re-enabling EventBridge rule...")
+		_, err := withRetry(ctx, retry, "EnableRule", func() (*eventbridge.EnableRuleOutput, error) {
+			return eventsClient.EnableRule(ctx, &eventbridge.EnableRuleInput{Name: aws.String(rule)})
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule(%s): EnableRule error: %w", rule, err))
+		}
+	}
+	return errors.Join(errs...)
+}
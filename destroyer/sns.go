@@ -0,0 +1,101 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// 確認待ちのサブスクリプションは ARN の代わりにこの値になり、削除できない
+const pendingSubscriptionArn = "PendingConfirmation"
+
+// スタック内の SNS トピックの ARN を取得 (AWS::SNS::Topic の物理 ID は ARN)
+func getSnsTopicArnsFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::SNS::Topic", retry)
+}
+
+// トピックのサブスクリプションを削除する (dryRun 時は対象の表示のみ)
+// 処理したトピックの名前と、削除したサブスクリプションの数を返す。既に存在しないトピックは無視する
+func deleteTopicSubscriptions(ctx context.Context, snsClient snsAPI, topicArns []string, opts cleanupOptions) ([]string, int, error) {
+	var topics []string
+	var deleted int
+	var errs []error
+	for _, topicArn := range topicArns {
+		name := arnToName(topicArn)
+		topicLog := loggerFrom(ctx).With("topic", name)
+		subscriptions, err := listTopicSubscriptions(ctx, snsClient, topicArn, opts.Retry)
+		var notFound *snstypes.NotFoundException
+		switch {
+		case errors.As(err, &notFound):
+			topicLog.Infof("Topic does not exist, skipping")
+			continue
+		case err != nil:
+			if isInterrupted(err) {
+				return topics, deleted, err
+			}
+			topicLog.Errorf("Failed to list subscriptions: %v", err)
+			errs = append(errs, fmt.Errorf("topic(%s): %w", name, err))
+			continue
+		}
+		topics = append(topics, name)
+		if len(subscriptions) == 0 {
+			topicLog.Debugf("No subscriptions")
+			continue
+		}
+
+		var failed bool
+		for _, sub := range subscriptions {
+			subArn := aws.ToString(sub.SubscriptionArn)
+			subLog := topicLog.With("protocol", aws.ToString(sub.Protocol), "endpoint", aws.ToString(sub.Endpoint))
+			if subArn == pendingSubscriptionArn {
+				subLog.Infof("Subscription is pending confirmation and cannot be deleted, skipping")
+				continue
+			}
+			if opts.DryRun {
+				subLog.Infof("[DryRun] Would delete subscription")
+				deleted++
+				continue
+			}
+			_, err := withRetry(ctx, opts.Retry, "Unsubscribe", func() (*sns.UnsubscribeOutput, error) {
+				return snsClient.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: &subArn})
+			})
+			if errors.As(err, &notFound) {
+				continue
+			}
+			if err != nil {
+				if isInterrupted(err) {
+					return topics, deleted, err
+				}
+				subLog.Errorf("Failed to delete subscription: %v", err)
+				errs = append(errs, fmt.Errorf("topic(%s): subscription(%s): %w", name, subArn, err))
+				failed = true
+				continue
+			}
+			deleted++
+		}
+		if !opts.DryRun && !failed {
+			topicLog.Infof("Deleted subscriptions of SNS topic")
+		}
+	}
+	return topics, deleted, errors.Join(errs...)
+}
+
+// トピックのサブスクリプション (全ページ分)
+func listTopicSubscriptions(ctx context.Context, snsClient snsAPI, topicArn string, retry retryPolicy) ([]snstypes.Subscription, error) {
+	var subscriptions []snstypes.Subscription
+	paginator := sns.NewListSubscriptionsByTopicPaginator(snsClient, &sns.ListSubscriptionsByTopicInput{TopicArn: &topicArn})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListSubscriptionsByTopic", func() (*sns.ListSubscriptionsByTopicOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListSubscriptionsByTopic error: %w", err)
+		}
+		subscriptions = append(subscriptions, page.Subscriptions...)
+	}
+	return subscriptions, nil
+}
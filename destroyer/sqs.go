@@ -0,0 +1,55 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// スタック内の SQS キューの URL を取得 (AWS::SQS::Queue の物理 ID は URL)
+func getSqsQueueUrlsFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	return listStackResourceIDs(ctx, cfnClient, stackName, "AWS::SQS::Queue", retry)
+}
+
+// キューのメッセージを PurgeQueue で削除する (dryRun 時は対象の表示のみ)。処理したキューの名前を返す
+// PurgeQueue は 60 秒に1回しか呼べないが、その間は前回のパージが進んでいるので処理済みとして扱う
+// 既に存在しないキューは無視する
+func purgeSqsQueues(ctx context.Context, sqsClient sqsAPI, queueUrls []string, opts cleanupOptions) ([]string, error) {
+	var purged []string
+	var errs []error
+	for _, url := range queueUrls {
+		name := lastPathSegment(url)
+		queueLog := loggerFrom(ctx).With("queue", name)
+		if opts.DryRun {
+			queueLog.Infof("[DryRun] Would purge SQS queue")
+			purged = append(purged, name)
+			continue
+		}
+		_, err := withRetry(ctx, opts.Retry, "PurgeQueue", func() (*sqs.PurgeQueueOutput, error) {
+			return sqsClient.PurgeQueue(ctx, &sqs.PurgeQueueInput{QueueUrl: &url})
+		})
+		var inProgress *sqstypes.PurgeQueueInProgress
+		var notFound *sqstypes.QueueDoesNotExist
+		switch {
+		case errors.As(err, &inProgress):
+			queueLog.Infof("Queue was purged within the last 60 seconds, the purge is still in progress")
+		case errors.As(err, &notFound):
+			queueLog.Infof("Queue does not exist, skipping")
+			continue
+		case err != nil:
+			if isInterrupted(err) {
+				return purged, err
+			}
+			queueLog.Errorf("Failed to purge queue: %v", err)
+			errs = append(errs, fmt.Errorf("queue(%s): %w", name, err))
+			continue
+		default:
+			queueLog.Infof("Purged SQS queue")
+		}
+		purged = append(purged, name)
+	}
+	return purged, errors.Join(errs...)
+}
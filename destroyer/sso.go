@@ -0,0 +1,44 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+// profile 名として受け付ける文字 (aws sso login / cdk にそのまま渡すため制限する)
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.+@/-]+$`)
+
+// --profile の形式チェック
+func validateProfileName(profile string) error {
+	if !profileNamePattern.MatchString(profile) {
+		return fmt.Errorf("invalid profile name %q (allowed: letters, digits and _ . + @ / -)", profile)
+	}
+	return nil
+}
+
+// IAM Identity Center (SSO) のトークンが期限切れ・無効で認証に失敗したか判定
+func isSSOTokenError(err error) bool {
+	var invalidToken *ssocreds.InvalidTokenError
+	var unauthorized *ssotypes.UnauthorizedException
+	return errors.As(err, &invalidToken) || errors.As(err, &unauthorized)
+}
+
+// aws sso login を実行してトークンを更新する (ブラウザでの認証を待つ)
+func runSSOLogin(ctx context.Context, profile string) error {
+	logger.Infof("SSO session has expired, running: aws sso login --profile %s", profile)
+	cmd := exec.CommandContext(ctx, "aws", "sso", "login", "--profile", profile)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return wrapCancelled(ctx, fmt.Errorf("aws sso login failed: %w", err))
+	}
+	return nil
+}
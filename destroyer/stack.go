@@ -0,0 +1,454 @@
+package destroyer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// 同じリージョンで処理するスタック (AWS の接続と cdk destroy はリージョンごとに行う)
+type regionGroup struct {
+	Region string // 空なら profile / 環境変数の region
+	Stacks []string
+}
+
+// --stack の "name@region" を名前とリージョンに分ける (@ が無ければリージョンは空)
+// スタック名・ARN には @ を使えないので、最後の @ で区切る
+func splitStackSpec(spec string) (name, region string) {
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
+
+// --stack の各スタックのリージョンを決め、リージョンごとにまとめる (--stack で最初に現れた順)
+// リージョンは "name@region" > スタック ARN の region > 既定のリージョンの順に決める
+// スタック ARN の region が "@region" や --region と食い違う場合はエラーにする
+// 既定のリージョンは --region で、未指定ならスタック ARN の region (全て同じ場合のみ) を使う
+// 既定のリージョンも返す。そのグループは --cluster で使うので、スタックが無くても (先頭に) 含める
+func groupStacksByRegion(specs []string, explicitRegion string) ([]regionGroup, string, error) {
+	type stackRegion struct{ name, region, arnRegion string }
+	var stacks []stackRegion
+	arnRegions := map[string]bool{}
+	for _, spec := range specs {
+		name, region := splitStackSpec(spec)
+		if name == "" {
+			return nil, "", fmt.Errorf("stack name is empty: %q", spec)
+		}
+		sr := stackRegion{name: name, region: region}
+		if arn.IsARN(name) {
+			parsed, err := arn.Parse(name)
+			if err != nil || parsed.Service != "cloudformation" || parsed.Region == "" {
+				return nil, "", fmt.Errorf("not a CloudFormation stack ARN: %s", name)
+			}
+			if region != "" && parsed.Region != region {
+				return nil, "", fmt.Errorf("stack %s is in region %s, but %s was given", name, parsed.Region, region)
+			}
+			if region == "" && explicitRegion != "" && parsed.Region != explicitRegion {
+				return nil, "", fmt.Errorf("stack %s is in region %s, but --region is %s", name, parsed.Region, explicitRegion)
+			}
+			sr.arnRegion = parsed.Region
+			if region == "" {
+				arnRegions[parsed.Region] = true
+			}
+			logger.Debugf("Stack %s: account %s, region %s", stackDisplayName(name), parsed.AccountID, parsed.Region)
+		}
+		stacks = append(stacks, sr)
+	}
+
+	defaultRegion := explicitRegion
+	if defaultRegion == "" && len(arnRegions) == 1 {
+		for region := range arnRegions {
+			defaultRegion = region
+		}
+	}
+
+	var groups []regionGroup
+	for _, sr := range stacks {
+		region := cmp.Or(sr.region, sr.arnRegion, defaultRegion)
+		i := slices.IndexFunc(groups, func(g regionGroup) bool { return g.Region == region })
+		if i < 0 {
+			groups = append(groups, regionGroup{Region: region})
+			i = len(groups) - 1
+		}
+		groups[i].Stacks = append(groups[i].Stacks, sr.name)
+	}
+	if !slices.ContainsFunc(groups, func(g regionGroup) bool { return g.Region == defaultRegion }) {
+		groups = append([]regionGroup{{Region: defaultRegion}}, groups...)
+	}
+	return groups, defaultRegion, nil
+}
+
+// スタックの情報を取得 (スタックが存在しない・削除済みなら nil)
+func describeStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) (*cfntypes.Stack, error) {
+	out, err := withRetry(ctx, retry, "DescribeStacks", func() (*cfn.DescribeStacksOutput, error) {
+		return cfnClient.DescribeStacks(ctx, &cfn.DescribeStacksInput{StackName: &stackName})
+	})
+	if err != nil {
+		if isStackNotExistError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("DescribeStacks error: %w", err)
+	}
+	if len(out.Stacks) == 0 || out.Stacks[0].StackStatus == cfntypes.StackStatusDeleteComplete {
+		return nil, nil
+	}
+	return &out.Stacks[0], nil
+}
+
+// --wait-for-stable-stack でスタックの状態を確認する間隔
+const stackStablePollInterval = 15 * time.Second
+
+// 進行中の操作 (デプロイ・ロールバック・削除など) がある状態か
+func stackInProgress(status cfntypes.StackStatus) bool {
+	return strings.HasSuffix(string(status), "_IN_PROGRESS")
+}
+
+// スタックが進行中の操作を終える (*_COMPLETE / *_FAILED になる) まで待ち、その時点の情報を返す
+// 待っている間に削除されたら nil を返す。maxWait を過ぎたとき、削除できない状態で止まったときはエラー
+func waitForStackStable(ctx context.Context, cfnClient cfnAPI, stack *cfntypes.Stack, maxWait time.Duration, retry retryPolicy) (*cfntypes.Stack, error) {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	stackID := aws.ToString(stack.StackId)
+	waited := false
+	ticker := time.NewTicker(stackStablePollInterval)
+	defer ticker.Stop()
+	for {
+		switch {
+		case stack == nil:
+			return nil, nil
+		case stack.StackStatus == cfntypes.StackStatusUpdateRollbackFailed:
+			// ロールバックを最後まで進めるまで DeleteStack は失敗する
+			return stack, fmt.Errorf("stack is %s and cannot be deleted until its rollback is continued (aws cloudformation continue-update-rollback)%s",
+				stack.StackStatus, formatReason(stack.StackStatusReason))
+		case !stackInProgress(stack.StackStatus):
+			if waited {
+				loggerFrom(ctx).Infof("Stack is now %s", stack.StackStatus)
+			}
+			if strings.HasSuffix(string(stack.StackStatus), "_FAILED") {
+				loggerFrom(ctx).Warnf("Stack is %s%s; some of its resources may be left after destroy", stack.StackStatus, formatReason(stack.StackStatusReason))
+			}
+			return stack, nil
+		case !waited:
+			loggerFrom(ctx).Infof("Stack is %s, waiting for the operation to finish (up to %s)...", stack.StackStatus, maxWait)
+			waited = true
+		default:
+			loggerFrom(ctx).Debugf("Stack is still %s", stack.StackStatus)
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return stack, fmt.Errorf("stack is still %s after %s (--stack-wait-timeout)", stack.StackStatus, maxWait)
+			}
+			return stack, wrapCancelled(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+		next, err := describeStack(ctx, cfnClient, stackID, retry)
+		if err != nil {
+			if ctx.Err() == nil {
+				return stack, err
+			}
+			continue
+		}
+		stack = next
+	}
+}
+
+// "Stack with id xxx does not exist" の ValidationError か判定 (権限エラーなどは false)
+func isStackNotExistError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "does not exist")
+}
+
+// スタックを最後に更新 (デプロイ) した時刻 (更新されていなければ作成時刻)
+func stackLastUpdated(stack *cfntypes.Stack) time.Time {
+	if stack.LastUpdatedTime != nil {
+		return *stack.LastUpdatedTime
+	}
+	return aws.ToTime(stack.CreationTime)
+}
+
+// 直前に更新されたスタックは誰かが作業中かもしれないので、opts.MinStackAge より新しければエラーにする
+// --force なら確認しない。dryRun 時は警告だけ出す
+func checkStackAge(ctx context.Context, stack *cfntypes.Stack, opts cleanupOptions) error {
+	if opts.MinStackAge <= 0 || opts.Force {
+		return nil
+	}
+	updated := stackLastUpdated(stack)
+	age := time.Since(updated)
+	if age >= opts.MinStackAge {
+		return nil
+	}
+	msg := fmt.Sprintf("the stack was updated %s ago (at %s), more recently than --min-stack-age %s; someone may be working on it. Pass --force to destroy it anyway",
+		age.Round(time.Second), updated.Format(time.RFC3339), opts.MinStackAge)
+	if opts.DryRun {
+		loggerFrom(ctx).Warnf("[DryRun] Would abort: %s", msg)
+		return nil
+	}
+	return errors.New(msg)
+}
+
+// スタックの削除保護 (termination protection) が有効だと cdk destroy がすぐに失敗するので事前に確認する
+// opts.DisableTermProtect が true なら無効化し (dryRun 時は表示のみ)、false ならエラーにする
+func ensureTerminationProtectionDisabled(ctx context.Context, cfnClient cfnAPI, stack *cfntypes.Stack, opts cleanupOptions) error {
+	enabled := aws.ToBool(stack.EnableTerminationProtection)
+	loggerFrom(ctx).Infof("Termination protection: %t", enabled)
+	if !enabled {
+		return nil
+	}
+	if !opts.DisableTermProtect {
+		return errors.New("termination protection is enabled on the stack, so cdk destroy would fail. Pass --disable-termination-protection to disable it before destroy")
+	}
+	if opts.DryRun {
+		loggerFrom(ctx).Infof("[DryRun] Would disable termination protection")
+		return nil
+	}
+
+	loggerFrom(ctx).Warnf("Disabling termination protection (was enabled)...")
+	_, err := withRetry(ctx, opts.Retry, "UpdateTerminationProtection", func() (*cfn.UpdateTerminationProtectionOutput, error) {
+		return cfnClient.UpdateTerminationProtection(ctx, &cfn.UpdateTerminationProtectionInput{
+			StackName:                   stack.StackId,
+			EnableTerminationProtection: aws.Bool(false),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateTerminationProtection error: %w", err)
+	}
+	return nil
+}
+
+// CloudFormation から ECS Cluster名を取得 (スタック内の全クラスター)
+func getEcsClusterNamesFromStack(ctx context.Context, cfnClient cfnAPI, stackName string, retry retryPolicy) ([]string, error) {
+	ids, err := listStackResourceIDs(ctx, cfnClient, stackName, "AWS::ECS::Cluster", retry)
+	if err != nil {
+		return nil, err
+	}
+	return clusterNames(ids), nil
+}
+
+// --cluster-logical-id の論理 ID のリソースからクラスター名を取得 (スタックに無い論理 ID は無視する)
+// カスタムリソースなどでクラスターを作るコンストラクト向けで、物理 ID はクラスター名か ARN とみなす
+func getEcsClusterNamesByLogicalIDs(ctx context.Context, cfnClient cfnAPI, stackName string, logicalIDs []string, retry retryPolicy) ([]string, error) {
+	var ids []string
+	for _, logicalID := range logicalIDs {
+		out, err := withRetry(ctx, retry, "DescribeStackResource", func() (*cfn.DescribeStackResourceOutput, error) {
+			return cfnClient.DescribeStackResource(ctx, &cfn.DescribeStackResourceInput{
+				StackName:         &stackName,
+				LogicalResourceId: &logicalID,
+			})
+		})
+		// 論理 ID が無いときも "Resource ... does not exist for stack ..." の ValidationError になる
+		if isStackNotExistError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("DescribeStackResource(%s) error: %w", logicalID, err)
+		}
+		detail := out.StackResourceDetail
+		if detail == nil || aws.ToString(detail.PhysicalResourceId) == "" {
+			continue
+		}
+		id := aws.ToString(detail.PhysicalResourceId)
+		loggerFrom(ctx).With("cluster", clusterNameFromID(id)).Infof("Found ECS cluster by logical ID %s (%s) in stack %s", logicalID, aws.ToString(detail.ResourceType), stackDisplayName(stackName))
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// AWS::ECS::Cluster の物理 ID や --cluster の値 (名前かクラスター ARN) をクラスター名にそろえる
+// ECS の API は同じアカウント・リージョンならどちらでも受け付けるが、ログ・サマリー・計画・フィルタは名前で比べる
+func clusterNames(ids []string) []string {
+	var names []string
+	for _, id := range ids {
+		if name := clusterNameFromID(id); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// "arn:aws:ecs:<region>:<account>:cluster/<name>" なら <name>、それ以外はそのまま
+func clusterNameFromID(id string) string {
+	parsed, err := arn.Parse(id)
+	if err != nil || parsed.Service != "ecs" {
+		return id
+	}
+	if name, ok := strings.CutPrefix(parsed.Resource, "cluster/"); ok && name != "" {
+		return name
+	}
+	return id
+}
+
+// ルートスタックとネストされたスタックの一覧を取得 (親 → 子の順)
+// 循環参照は一度訪れたスタックを無視し、maxDepth より深い階層は辿らない
+func listStackTree(ctx context.Context, cfnClient cfnAPI, rootStack string, maxDepth int, retry retryPolicy) ([]string, error) {
+	visited := map[string]bool{}
+	var stacks []string
+
+	var walk func(stack string, depth int) error
+	walk = func(stack string, depth int) error {
+		if visited[stack] {
+			loggerFrom(ctx).Warnf("Nested stack %s was already visited, skipping (cycle?)", stack)
+			return nil
+		}
+		visited[stack] = true
+		stacks = append(stacks, stack)
+		if depth > 0 {
+			loggerFrom(ctx).Infof("%s└ Nested stack: %s (depth %d)", strings.Repeat("  ", depth-1), stackDisplayName(stack), depth)
+		}
+
+		children, err := listStackResourceIDs(ctx, cfnClient, stack, "AWS::CloudFormation::Stack", retry)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 && depth >= maxDepth {
+			loggerFrom(ctx).Warnf("Stack %s has %d nested stack(s) beyond --max-stack-depth=%d, not descending", stackDisplayName(stack), len(children), maxDepth)
+			return nil
+		}
+		for _, child := range children {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(rootStack, 0); err != nil {
+		return nil, err
+	}
+	return stacks, nil
+}
+
+// スタック ID (ARN: arn:aws:cloudformation:...:stack/<name>/<uuid>) からスタック名を取り出す
+func stackDisplayName(stackID string) string {
+	parsed, err := arn.Parse(stackID)
+	if err != nil {
+		return stackID
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	if len(parts) >= 2 && parts[0] == "stack" {
+		return parts[1]
+	}
+	return stackID
+}
+
+// 各スタックに対して fn を実行し、結果をまとめる
+func collectFromStacks[T any](stacks []string, fn func(stack string) ([]T, error)) ([]T, error) {
+	var all []T
+	for _, stack := range stacks {
+		items, err := fn(stack)
+		if err != nil {
+			return nil, fmt.Errorf("stack(%s): %w", stackDisplayName(stack), err)
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
+
+// スタック内の指定タイプのリソースの物理 ID を取得
+func listStackResourceIDs(ctx context.Context, cfnClient cfnAPI, stackName, resourceType string, retry retryPolicy) ([]string, error) {
+	resources, err := listStackResources(ctx, cfnClient, stackName, resourceType, retry)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, r := range resources {
+		ids = append(ids, *r.PhysicalResourceId)
+	}
+	return ids, nil
+}
+
+// スタック内の指定タイプのリソースを取得 (全ページ分、物理 ID が未確定のものは除く)
+func listStackResources(ctx context.Context, cfnClient cfnAPI, stackName, resourceType string, retry retryPolicy) ([]cfntypes.StackResourceSummary, error) {
+	paginator := cfn.NewListStackResourcesPaginator(cfnClient, &cfn.ListStackResourcesInput{
+		StackName: &stackName,
+	})
+
+	var resources []cfntypes.StackResourceSummary
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListStackResources", func() (*cfn.ListStackResourcesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.StackResourceSummaries {
+			if r.ResourceType != nil && *r.ResourceType == resourceType && r.PhysicalResourceId != nil {
+				resources = append(resources, r)
+			}
+		}
+	}
+	return resources, nil
+}
+
+// 直近の削除で DELETE_FAILED になったリソースのイベントを新しい順に最大 limit 件返す
+// スタックの DELETE_IN_PROGRESS (今回の削除の開始) より古いイベントは見ない
+func recentDeleteFailedEvents(ctx context.Context, cfnClient cfnAPI, stackName string, limit int, retry retryPolicy) ([]cfntypes.StackEvent, error) {
+	var failed []cfntypes.StackEvent
+	paginator := cfn.NewDescribeStackEventsPaginator(cfnClient, &cfn.DescribeStackEventsInput{StackName: &stackName})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "DescribeStackEvents", func() (*cfn.DescribeStackEventsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeStackEvents error: %w", err)
+		}
+		for _, event := range page.StackEvents {
+			isStack := aws.ToString(event.ResourceType) == "AWS::CloudFormation::Stack" && aws.ToString(event.PhysicalResourceId) == aws.ToString(event.StackId)
+			if isStack && event.ResourceStatus == cfntypes.ResourceStatusDeleteInProgress {
+				return failed, nil
+			}
+			if event.ResourceStatus == cfntypes.ResourceStatusDeleteFailed && !isStack {
+				failed = append(failed, event)
+				if len(failed) >= limit {
+					return failed, nil
+				}
+			}
+		}
+	}
+	return failed, nil
+}
+
+// DELETE_FAILED のイベントを1行にまとめる
+func formatDeleteFailedEvent(event cfntypes.StackEvent) string {
+	return fmt.Sprintf("%s (%s, %s) DELETE_FAILED at %s: %s",
+		aws.ToString(event.LogicalResourceId), aws.ToString(event.ResourceType), aws.ToString(event.PhysicalResourceId),
+		aws.ToTime(event.Timestamp).Format(time.RFC3339), aws.ToString(event.ResourceStatusReason))
+}
+
+// cdk destroy の失敗時に、削除を止めたリソースをスタックのイベントから探して表示する
+// 表示したメッセージを返す (取得に失敗しても警告を出すだけ)
+func reportDeleteFailures(ctx context.Context, cfnClient cfnAPI, stackNames []string, limit int, retry retryPolicy) []string {
+	var msgs []string
+	for _, stackName := range stackNames {
+		events, err := recentDeleteFailedEvents(ctx, cfnClient, stackName, limit, retry)
+		if err != nil {
+			if !isStackNotExistError(err) {
+				loggerFrom(ctx).Warnf("Failed to get stack events for %s: %v", stackDisplayName(stackName), err)
+			}
+			continue
+		}
+		if len(events) == 0 {
+			continue
+		}
+		loggerFrom(ctx).Errorf("Resources that failed to delete in stack %s (latest first):", stackDisplayName(stackName))
+		for _, event := range events {
+			msg := formatDeleteFailedEvent(event)
+			loggerFrom(ctx).Errorf("  %s", msg)
+			msgs = append(msgs, fmt.Sprintf("stack(%s): %s", stackDisplayName(stackName), msg))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,130 @@
+package destroyer
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer/fakes"
+)
+
+func TestClusterNameFromID(t *testing.T) {
+	tests := []struct {
+		id, want string
+	}{
+		{"app-cluster", "app-cluster"},
+		{"arn:aws:ecs:us-east-1:123456789012:cluster/app-cluster", "app-cluster"},
+		{"arn:aws-cn:ecs:cn-north-1:123456789012:cluster/app-cluster", "app-cluster"},
+		{"arn:aws:ecs:us-east-1:123456789012:cluster/", "arn:aws:ecs:us-east-1:123456789012:cluster/"},
+		{"arn:aws:ecs:us-east-1:123456789012:service/app-cluster/web", "arn:aws:ecs:us-east-1:123456789012:service/app-cluster/web"},
+		{"arn:aws:eks:us-east-1:123456789012:cluster/app-cluster", "arn:aws:eks:us-east-1:123456789012:cluster/app-cluster"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := clusterNameFromID(tt.id); got != tt.want {
+			t.Errorf("clusterNameFromID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestGetEcsClusterNamesFromStackPaginates(t *testing.T) {
+	api := fakes.NewCFN()
+	api.PageSize = 2
+	api.AddStack("stack")
+	api.AddResource("stack", "A", "AWS::ECS::Cluster", "first")
+	api.AddResource("stack", "B", "AWS::S3::Bucket", "bucket")
+	api.AddResource("stack", "C", "AWS::ECS::Cluster", "second")
+
+	names, err := getEcsClusterNamesFromStack(context.Background(), api, "stack", testCleanupOptions().Retry)
+	if err != nil {
+		t.Fatalf("getEcsClusterNamesFromStack: %v", err)
+	}
+	if want := []string{"first", "second"}; !slices.Equal(names, want) {
+		t.Errorf("clusters = %v, want %v (the cluster on the second page must be found)", names, want)
+	}
+	if got := api.CallCount("ListStackResources"); got != 2 {
+		t.Errorf("ListStackResources called %d times, want 2 (one per page)", got)
+	}
+}
+
+func TestGroupStacksByRegion(t *testing.T) {
+	const (
+		eastArn = "arn:aws:cloudformation:us-east-1:123456789012:stack/East/00000001-0000-0000-0000-000000000000"
+		westArn = "arn:aws:cloudformation:us-west-2:123456789012:stack/West/00000002-0000-0000-0000-000000000000"
+	)
+	tests := []struct {
+		name        string
+		specs       []string
+		region      string
+		wantGroups  []regionGroup
+		wantDefault string
+		wantErr     string
+	}{
+		{
+			name:        "names use --region",
+			specs:       []string{"A", "B@eu-west-1", "C"},
+			region:      "us-east-1",
+			wantGroups:  []regionGroup{{Region: "us-east-1", Stacks: []string{"A", "C"}}, {Region: "eu-west-1", Stacks: []string{"B"}}},
+			wantDefault: "us-east-1",
+		},
+		{
+			name:        "ARN region becomes the default",
+			specs:       []string{eastArn, "A"},
+			wantGroups:  []regionGroup{{Region: "us-east-1", Stacks: []string{eastArn, "A"}}},
+			wantDefault: "us-east-1",
+		},
+		{
+			name:        "ARN matches --region",
+			specs:       []string{eastArn},
+			region:      "us-east-1",
+			wantGroups:  []regionGroup{{Region: "us-east-1", Stacks: []string{eastArn}}},
+			wantDefault: "us-east-1",
+		},
+		{
+			name:    "ARN differs from --region",
+			specs:   []string{westArn},
+			region:  "us-east-1",
+			wantErr: "stack " + westArn + " is in region us-west-2, but --region is us-east-1",
+		},
+		{
+			name:        "@region agrees with the ARN over --region",
+			specs:       []string{westArn + "@us-west-2"},
+			region:      "us-east-1",
+			wantGroups:  []regionGroup{{Region: "us-east-1"}, {Region: "us-west-2", Stacks: []string{westArn}}},
+			wantDefault: "us-east-1",
+		},
+		{
+			name:    "ARN differs from @region",
+			specs:   []string{westArn + "@us-east-1"},
+			wantErr: "is in region us-west-2, but us-east-1 was given",
+		},
+		{
+			name:    "not a stack ARN",
+			specs:   []string{"arn:aws:ecs:us-east-1:123456789012:cluster/app"},
+			wantErr: "not a CloudFormation stack ARN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, def, err := groupStacksByRegion(tt.specs, tt.region)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("groupStacksByRegion: %v", err)
+			}
+			if def != tt.wantDefault {
+				t.Errorf("default region = %q, want %q", def, tt.wantDefault)
+			}
+			if !slices.EqualFunc(groups, tt.wantGroups, func(a, b regionGroup) bool {
+				return a.Region == b.Region && slices.Equal(a.Stacks, b.Stacks)
+			}) {
+				t.Errorf("groups = %+v, want %+v", groups, tt.wantGroups)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package destroyer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// スタックのクリーンアップ・cdk destroy の実行結果 (サマリー用)
+const (
+	statusNotRun    = "not run"
+	statusSkipped   = "skipped"
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusNotFound  = "not found" // スタックが既に削除済み
+	statusPlanned   = "planned"   // --plan-out で計画に記録しただけ
+	// サービスを安定 (タスク 0) を確認できないまま削除した
+	statusUnconfirmed = "deleted without confirmed stability"
+)
+
+// 実行結果のまとめ (最後に1回だけ出力する)
+type runSummary struct {
+	Account    string         `json:"account"`
+	Region     string         `json:"region"`
+	DryRun     bool           `json:"dryRun"`
+	Stacks     []stackSummary `json:"stacks"`
+	CdkDestroy string         `json:"cdkDestroy"`
+	// cdk の終了コード (実行しなかった・起動できなかった場合は無し)
+	CdkExitCode *int      `json:"cdkExitCode,omitempty"`
+	Failures    []string  `json:"failures,omitempty"` // スタック以外 (cdk destroy) の失敗
+	Error       string    `json:"error,omitempty"`    // run が返したエラー
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	// 段階ごとの所要時間 (探索・サービスの縮退・安定待ち・削除・タスク停止・cdk destroy)
+	Phases []phaseTiming `json:"phases,omitempty"`
+}
+
+// スタックごとの処理結果
+type stackSummary struct {
+	Stack    string `json:"stack"`
+	Region   string `json:"region,omitempty"` // 複数のリージョンのスタックを処理したときだけ
+	Status   string `json:"status"`
+	Clusters int    `json:"clusters"`
+	drainStats
+	Buckets         int      `json:"buckets"`
+	Objects         int      `json:"objects"`
+	Repositories    int      `json:"repositories"`
+	Images          int      `json:"images"`
+	LogGroups       int      `json:"logGroups"`
+	Tables          int      `json:"tables"`                    // 削除保護を無効にした DynamoDB テーブル
+	MountTargets    int      `json:"mountTargets"`              // 削除した EFS マウントターゲット
+	DeletedClusters int      `json:"deletedClusters,omitempty"` // --delete-cluster で削除したクラスター
+	Queues          []string `json:"purgedQueues,omitempty"`    // --purge-queues でパージした SQS キュー
+	Topics          []string `json:"topics,omitempty"`          // --delete-topic-subscriptions で処理した SNS トピック
+	Subscriptions   int      `json:"subscriptions,omitempty"`   // 削除した SNS サブスクリプション
+	Failures        []string `json:"failures,omitempty"`
+	// クラスターごとの内訳 (削除したサービス・停止したタスク)
+	ClusterResults []clusterResult `json:"clusterResults,omitempty"`
+}
+
+// 失敗が1つでもあるか
+func (s runSummary) failed() bool {
+	if len(s.Failures) > 0 {
+		return true
+	}
+	for _, st := range s.Stacks {
+		if len(st.Failures) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 停止できなかったタスクがあるか
+func (s stackSummary) hasStuckTasks() bool {
+	for _, c := range s.ClusterResults {
+		if len(c.StuckTasks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 安定を確認できないまま削除したサービスの数
+func (s runSummary) unstableServices() int {
+	n := 0
+	for _, st := range s.Stacks {
+		n += st.UnstableServices
+	}
+	return n
+}
+
+// サマリーを出力する (json 形式では1つの JSON オブジェクト、text 形式ではスタックごとの行)
+func (s runSummary) log(format string) {
+	level := slog.LevelInfo
+	if s.failed() || s.unstableServices() > 0 {
+		level = slog.LevelWarn
+	}
+	if format == "json" {
+		logger.Log(level, "Summary", "summary", s)
+		return
+	}
+
+	prefix, would := "", ""
+	if s.DryRun {
+		prefix, would = "[DryRun] ", "would be "
+	}
+	for _, st := range s.Stacks {
+		stackLog := logger.With("stack", st.Stack)
+		stackLog.Infof("%sSummary (%s): %d cluster(s), %d service(s) %sdeleted, %d task(s) %sstopped, %d task definition revision(s) %sderegistered, %d Auto Scaling Group(s) %sscaled down, %d EventBridge rule(s) %sdisabled, %d ENI(s) %sdeleted, %d S3 object(s) in %d bucket(s) and %d ECR image(s) in %d repository(ies) %sdeleted, %d log group(s) %sdeleted, %d DynamoDB table(s) %sunprotected, %d EFS mount target(s) %sdeleted",
+			prefix, st.Status, st.Clusters, st.Services, would, st.Tasks, would, st.TaskDefinitions, would, st.AutoScalingGroups, would, st.ScheduledRules, would, st.NetworkInterfaces, would,
+			st.Objects, st.Buckets, st.Images, st.Repositories, would, st.LogGroups, would, st.Tables, would, st.MountTargets, would)
+		if st.DeletedClusters > 0 {
+			stackLog.Infof("%s%d cluster(s) %sdeleted (--delete-cluster)", prefix, st.DeletedClusters, would)
+		}
+		if len(st.Topics) > 0 {
+			stackLog.Infof("%s%d SNS subscription(s) %sdeleted from topic(s): %s", prefix, st.Subscriptions, would, strings.Join(st.Topics, ", "))
+		}
+		if len(st.Queues) > 0 {
+			stackLog.Infof("%sSQS queue(s) %spurged: %s", prefix, would, strings.Join(st.Queues, ", "))
+		}
+		for _, f := range st.Failures {
+			stackLog.Warnf("%sFailed: %s", prefix, f)
+		}
+		for _, c := range st.ClusterResults {
+			for _, svc := range c.ServiceResults {
+				if svc.Status == statusUnconfirmed {
+					stackLog.Warnf("Service %s in cluster %s was deleted without confirmed stability (exceeded --service-wait-timeout); check for leftover tasks", svc.Name, c.Name)
+				}
+			}
+			if len(c.StuckTasks) > 0 {
+				stackLog.Warnf("%d task(s) in cluster %s did not stop: %s", len(c.StuckTasks), c.Name, strings.Join(taskNames(c.StuckTasks), ", "))
+			}
+		}
+	}
+	logger.Infof("%sSummary: cdk destroy: %s", prefix, s.CdkDestroy)
+	if len(s.Phases) > 0 {
+		logger.Infof("%sPhase timings: %s", prefix, formatPhaseTimings(s.Phases))
+	}
+	for _, f := range s.Failures {
+		logger.Warnf("%sFailed: %s", prefix, f)
+	}
+}
+
+// エラーをリソースごとの失敗メッセージに分解する
+// errors.Join でまとめたエラーは要素ごとに分け、それを包んだエラーの文脈は各要素の前に付ける
+func failureMessages(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var msgs []string
+		for _, e := range joined.Unwrap() {
+			msgs = append(msgs, failureMessages(e)...)
+		}
+		return msgs
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		msgs := failureMessages(inner)
+		outer, innerMsg := err.Error(), inner.Error()
+		if len(msgs) > 1 && strings.HasSuffix(outer, innerMsg) {
+			context := strings.TrimSuffix(outer, innerMsg)
+			for i := range msgs {
+				msgs[i] = context + msgs[i]
+			}
+			return msgs
+		}
+	}
+	return []string{fmt.Sprint(err)}
+}
+
+// サマリーを整形した JSON でファイルに書き出す (--output)
+func (s runSummary) writeFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
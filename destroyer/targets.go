@@ -0,0 +1,75 @@
+package destroyer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// サービスに紐づくロードバランサーのターゲットグループ ARN を取得
+func serviceTargetGroups(ctx context.Context, ecsClient ecsAPI, clusterName, svcArn string, retry retryPolicy) ([]string, error) {
+	services, err := describeServices(ctx, ecsClient, clusterName, []string{svcArn}, retry)
+	if err != nil {
+		return nil, err
+	}
+	var arns []string
+	for _, svc := range services {
+		for _, lb := range svc.LoadBalancers {
+			if lb.TargetGroupArn != nil {
+				arns = append(arns, *lb.TargetGroupArn)
+			}
+		}
+	}
+	return arns, nil
+}
+
+// ターゲットグループに残っているターゲットを登録解除し、draining が終わるまで待つ (全体で最大 maxWait)
+// deregistration_delay が長いと ECS の Force 削除後もターゲットが残り、cdk destroy でターゲットグループの削除に失敗する
+func drainTargetGroups(ctx context.Context, svcLog appLogger, elbClient elbv2API, targetGroupArns []string, opts cleanupOptions) error {
+	waiter := elbv2.NewTargetDeregisteredWaiter(elbClient)
+	for _, tgArn := range targetGroupArns {
+		tgLog := svcLog.With("targetGroup", arnToName(tgArn))
+		health, err := withRetry(ctx, opts.Retry, "DescribeTargetHealth", func() (*elbv2.DescribeTargetHealthOutput, error) {
+			return elbClient.DescribeTargetHealth(ctx, &elbv2.DescribeTargetHealthInput{
+				TargetGroupArn: aws.String(tgArn),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("DescribeTargetHealth error: %w", err)
+		}
+
+		var targets []elbv2types.TargetDescription
+		for _, d := range health.TargetHealthDescriptions {
+			if d.Target != nil {
+				targets = append(targets, *d.Target)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		tgLog.Infof("Deregistering %d target(s)...", len(targets))
+		_, err = withRetry(ctx, opts.Retry, "DeregisterTargets", func() (*elbv2.DeregisterTargetsOutput, error) {
+			return elbClient.DeregisterTargets(ctx, &elbv2.DeregisterTargetsInput{
+				TargetGroupArn: aws.String(tgArn),
+				Targets:        targets,
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("DeregisterTargets error: %w", err)
+		}
+
+		tgLog.Infof("Waiting for target(s) to finish draining...")
+		input := &elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(tgArn),
+			Targets:        targets,
+		}
+		if err := waiter.Wait(ctx, input, opts.ServiceWaitTimeout); err != nil {
+			return wrapCancelled(ctx, fmt.Errorf("targets in %s did not finish draining: %w", arnToName(tgArn), err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// DescribeServices 1回で指定できる最大サービス数
+const describeServicesBatchSize = 10
+
+// サービスの一覧を DescribeServices で取得 (10件ずつ)
+func describeServices(ctx context.Context, ecsClient ecsAPI, clusterName string, serviceArns []string, retry retryPolicy) ([]ecstypes.Service, error) {
+	var services []ecstypes.Service
+	for start := 0; start < len(serviceArns); start += describeServicesBatchSize {
+		batch := serviceArns[start:min(start+describeServicesBatchSize, len(serviceArns))]
+		out, err := withRetry(ctx, retry, "DescribeServices", func() (*ecs.DescribeServicesOutput, error) {
+			return ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Cluster:  &clusterName,
+				Services: batch,
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeServices error: %w", err)
+		}
+		services = append(services, out.Services...)
+	}
+	return services, nil
+}
+
+// クラスター内の (処理対象の) サービスが使っているタスク定義ファミリーを取得
+func listServiceTaskDefinitionFamilies(ctx context.Context, ecsClient ecsAPI, clusterName string, opts cleanupOptions) ([]string, error) {
+	serviceArns, err := targetServiceArns(ctx, ecsClient, clusterName, opts)
+	if err != nil {
+		return nil, err
+	}
+	services, err := describeServices(ctx, ecsClient, clusterName, serviceArns, opts.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var families []string
+	for _, svc := range services {
+		if !opts.ServiceFilter.match(aws.ToString(svc.ServiceName)) {
+			continue
+		}
+		family := taskDefinitionFamily(aws.ToString(svc.TaskDefinition))
+		if family != "" && !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	return families, nil
+}
+
+// 指定ファミリーの ACTIVE なリビジョンを全て登録解除する (dryRun 時は対象数の表示のみ)
+// 他のクラスターのサービスが使っているリビジョンは残す。登録解除した数を返す
+func deregisterTaskDefinitions(ctx context.Context, ecsClient ecsAPI, clusterName string, families []string, opts cleanupOptions) (int, error) {
+	if len(families) == 0 {
+		return 0, nil
+	}
+	clusterLog := loggerFrom(ctx).With("cluster", clusterName)
+
+	inUse, err := taskDefinitionsInUseByOtherClusters(ctx, ecsClient, clusterName, opts.Retry)
+	if err != nil {
+		return 0, err
+	}
+
+	var deregistered int
+	var errs []error
+	for _, family := range families {
+		familyLog := clusterLog.With("family", family)
+		revisions, err := listActiveTaskDefinitions(ctx, ecsClient, family, opts.Retry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("family(%s): %w", family, err))
+			continue
+		}
+
+		var count int
+		for _, tdArn := range revisions {
+			if inUse[tdArn] {
+				familyLog.Infof("Keeping %s (used by a service in another cluster)", arnToName(tdArn))
+				continue
+			}
+			if opts.DryRun {
+				count++
+				continue
+			}
+			_, err := withRetry(ctx, opts.Retry, "DeregisterTaskDefinition", func() (*ecs.DeregisterTaskDefinitionOutput, error) {
+				return ecsClient.DeregisterTaskDefinition(ctx, &ecs.DeregisterTaskDefinitionInput{
+					TaskDefinition: aws.String(tdArn),
+				})
+			})
+			if err != nil {
+				familyLog.Errorf("Failed to deregister %s: %v", arnToName(tdArn), err)
+				errs = append(errs, fmt.Errorf("task definition(%s): %w", tdArn, err))
+				continue
+			}
+			count++
+		}
+		if opts.DryRun {
+			familyLog.Infof("[DryRun] Would deregister %d task definition revision(s)", count)
+		} else {
+			familyLog.Infof("Deregistered %d task definition revision(s)", count)
+		}
+		deregistered += count
+	}
+	return deregistered, errors.Join(errs...)
+}
+
+// ファミリーの ACTIVE なタスク定義 ARN を取得 (全ページ分)
+func listActiveTaskDefinitions(ctx context.Context, ecsClient ecsAPI, family string, retry retryPolicy) ([]string, error) {
+	paginator := ecs.NewListTaskDefinitionsPaginator(ecsClient, &ecs.ListTaskDefinitionsInput{
+		FamilyPrefix: &family,
+		Status:       ecstypes.TaskDefinitionStatusActive,
+	})
+	var arns []string
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListTaskDefinitions", func() (*ecs.ListTaskDefinitionsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListTaskDefinitions error: %w", err)
+		}
+		for _, tdArn := range page.TaskDefinitionArns {
+			// FamilyPrefix は前方一致なので、同じファミリーのものだけに絞る
+			if taskDefinitionFamily(tdArn) == family {
+				arns = append(arns, tdArn)
+			}
+		}
+	}
+	return arns, nil
+}
+
+// 対象クラスター以外のサービスが使っているタスク定義 ARN の集合
+func taskDefinitionsInUseByOtherClusters(ctx context.Context, ecsClient ecsAPI, clusterName string, retry retryPolicy) (map[string]bool, error) {
+	inUse := map[string]bool{}
+	paginator := ecs.NewListClustersPaginator(ecsClient, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := withRetry(ctx, retry, "ListClusters", func() (*ecs.ListClustersOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListClusters error: %w", err)
+		}
+		for _, clusterArn := range page.ClusterArns {
+			if clusterArn == clusterName || arnToName(clusterArn) == clusterName {
+				continue
+			}
+			serviceArns, err := listServiceArns(ctx, ecsClient, clusterArn, retry)
+			if err != nil {
+				return nil, err
+			}
+			services, err := describeServices(ctx, ecsClient, clusterArn, serviceArns, retry)
+			if err != nil {
+				return nil, err
+			}
+			for _, svc := range services {
+				for _, d := range svc.Deployments {
+					inUse[aws.ToString(d.TaskDefinition)] = true
+				}
+				inUse[aws.ToString(svc.TaskDefinition)] = true
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// タスク定義 ARN (…:task-definition/<family>:<revision>) からファミリー名を取り出す
+func taskDefinitionFamily(taskDefinitionArn string) string {
+	name := arnToName(taskDefinitionArn)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
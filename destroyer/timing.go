@@ -0,0 +1,103 @@
+package destroyer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 所要時間を計測する処理の段階 (サマリーにはこの順ではなく最初に始まった順で出す)
+const (
+	phaseDiscovery     = "discovery"
+	phaseScaleDown     = "service scale-down"
+	phaseStabilityWait = "stability wait"
+	phaseServiceDelete = "service delete"
+	phaseTaskStop      = "task stop"
+	phaseCdkDestroy    = "cdk destroy"
+	phaseStackDelete   = "stack delete" // --use-cloudformation
+)
+
+// 段階ごとの所要時間
+// サービスごとの処理のように並列・複数回実行される段階は、最初の開始から最後の終了までを 1 つにまとめる
+type phaseTiming struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Seconds    float64   `json:"seconds"`      // 最初の開始から最後の終了まで
+	Total      float64   `json:"totalSeconds"` // 各回の所要時間の合計 (並列実行なら Seconds より長い)
+	Count      int       `json:"count"`        // 実行した回数
+
+	total time.Duration
+}
+
+// 段階ごとの所要時間を集める (複数の goroutine から使える)。nil なら何もしない
+type phaseTimings struct {
+	mu     sync.Mutex
+	phases []*phaseTiming
+}
+
+// 段階の計測を始め、終了時に呼ぶ関数を返す
+func (t *phaseTimings) start(name string) (stop func()) {
+	if t == nil {
+		return func() {}
+	}
+	started := time.Now()
+	return func() { t.record(name, started, time.Now()) }
+}
+
+func (t *phaseTimings) record(name string, started, finished time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var p *phaseTiming
+	for _, existing := range t.phases {
+		if existing.Name == name {
+			p = existing
+			break
+		}
+	}
+	if p == nil {
+		p = &phaseTiming{Name: name, StartedAt: started, FinishedAt: finished}
+		t.phases = append(t.phases, p)
+	}
+	if started.Before(p.StartedAt) {
+		p.StartedAt = started
+	}
+	if finished.After(p.FinishedAt) {
+		p.FinishedAt = finished
+	}
+	p.total += finished.Sub(started)
+	p.Count++
+}
+
+// 計測結果を最初に始まった順で返す
+func (t *phaseTimings) list() []phaseTiming {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]phaseTiming, 0, len(t.phases))
+	for _, p := range t.phases {
+		v := *p
+		v.Seconds = v.FinishedAt.Sub(v.StartedAt).Seconds()
+		v.Total = v.total.Seconds()
+		list = append(list, v)
+	}
+	return list
+}
+
+// サマリー用に "discovery 3s, stability wait 2m10s (total 6m30s, 3x), ..." の形にする
+func formatPhaseTimings(phases []phaseTiming) string {
+	parts := make([]string, 0, len(phases))
+	for _, p := range phases {
+		wall := p.FinishedAt.Sub(p.StartedAt).Round(time.Second)
+		if p.Count > 1 {
+			total := time.Duration(p.Total * float64(time.Second)).Round(time.Second)
+			parts = append(parts, fmt.Sprintf("%s %s (total %s, %dx)", p.Name, wall, total, p.Count))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s", p.Name, wall))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
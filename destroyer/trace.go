@@ -0,0 +1,96 @@
+package destroyer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// トレースの service.name の既定値 (OTEL_SERVICE_NAME で上書きできる)
+const traceServiceName = "cdk-destroy-with-running-ecs"
+
+// 終了時に残りのスパンを送る時間の上限
+const traceShutdownTimeout = 5 * time.Second
+
+// OpenTelemetry のトレーサー
+// setupTracing がエクスポーターを設定するまでは何も記録しない (no-op)
+var tracer = otel.Tracer("github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer")
+
+// OTEL_EXPORTER_OTLP_ENDPOINT (または OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) があれば OTLP/HTTP でトレースを送る
+// 送り先・ヘッダー・プロトコル以外の設定も OTEL_* の環境変数に従う。無ければ何もしない
+// 返す関数は残りのスパンを送って終了する (設定しなかった場合は何もしない)
+func setupTracing(ctx context.Context) (shutdown func(), err error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return func() {}, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", traceServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return func() {}, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	// 送信の失敗は標準の log ではなく、他のログと同じ形式で出す
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warnf("Failed to export traces: %v", err)
+	}))
+	return func() {
+		// 中断された場合も送れるよう、ctx のキャンセルは引き継がない
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), traceShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Warnf("Failed to export traces: %v", err)
+		}
+	}, nil
+}
+
+// スパンを開始する (トレースを送らない設定なら何も記録しない)
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// スパンを終える。err があればエラーとして記録する (ユーザーの中断はエラーにしない)
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, errAbortedByUser) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// スパンの属性 (stack / cluster / service / region / account)
+func stackAttr(stackName string) attribute.KeyValue {
+	return attribute.String("stack", stackDisplayName(stackName))
+}
+
+func clusterAttr(clusterName string) attribute.KeyValue {
+	return attribute.String("cluster", clusterName)
+}
+
+func serviceAttr(svcArn string) attribute.KeyValue {
+	return attribute.String("service", arnToName(svcArn))
+}
+
+func regionAttr(region string) attribute.KeyValue {
+	return attribute.String("region", region)
+}
+
+func targetAttrs(target awsTarget) []attribute.KeyValue {
+	return []attribute.KeyValue{regionAttr(target.Region), attribute.String("account", target.Account)}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 環境変数名の接頭辞 (例: --stack → CDK_DESTROY_STACK, --cdk-app-path → CDK_DESTROY_CDK_APP_PATH)
+const envPrefix = "CDK_DESTROY_"
+
+// フラグ名に対応する環境変数名
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// コマンドラインで指定していないフラグに環境変数の値を反映する
+// 設定ファイルより先に反映するので、優先順位はコマンドライン > 環境変数 > 設定ファイル > 既定値
+func applyEnv(fset *flag.FlagSet) error {
+	explicitFlags := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+
+	var err error
+	fset.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicitFlags[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || value == "" {
+			return
+		}
+		if setErr := fset.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("environment variable %s: invalid value %q: %w", envName(f.Name), value, setErr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// テスト用のフラグ (main の一部だけ)
+type testFlags struct {
+	fset            *flag.FlagSet
+	region, profile string
+	appPath         string
+	stacks          stringList
+	dryRun          bool
+}
+
+func newTestFlags(t *testing.T, args ...string) *testFlags {
+	t.Helper()
+	f := &testFlags{fset: flag.NewFlagSet("test", flag.ContinueOnError)}
+	f.fset.StringVar(&f.region, "region", "", "")
+	f.fset.StringVar(&f.profile, "profile", "", "")
+	f.fset.StringVar(&f.appPath, "cdk-app-path", "bin/app.ts", "")
+	f.fset.Var(&f.stacks, "stack", "")
+	f.fset.BoolVar(&f.dryRun, "dry-run", false, "")
+	if err := f.fset.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestEnvName(t *testing.T) {
+	for flagName, want := range map[string]string{
+		"stack":        "CDK_DESTROY_STACK",
+		"cdk-app-path": "CDK_DESTROY_CDK_APP_PATH",
+	} {
+		if got := envName(flagName); got != want {
+			t.Errorf("envName(%q) = %q, want %q", flagName, got, want)
+		}
+	}
+}
+
+func TestApplyEnvFallback(t *testing.T) {
+	t.Setenv("CDK_DESTROY_REGION", "eu-west-1")
+	t.Setenv("CDK_DESTROY_STACK", "StackA,StackB")
+	t.Setenv("CDK_DESTROY_DRY_RUN", "true")
+	t.Setenv("CDK_DESTROY_PROFILE", "")
+
+	f := newTestFlags(t)
+	if err := applyEnv(f.fset); err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+	if f.region != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", f.region)
+	}
+	if len(f.stacks) != 2 || f.stacks[0] != "StackA" || f.stacks[1] != "StackB" {
+		t.Errorf("stacks = %v, want [StackA StackB]", f.stacks)
+	}
+	if !f.dryRun {
+		t.Error("dry-run = false, want true")
+	}
+	// 空の環境変数は未設定と同じ
+	if f.profile != "" || f.appPath != "bin/app.ts" {
+		t.Errorf("profile = %q, cdk-app-path = %q; want defaults", f.profile, f.appPath)
+	}
+}
+
+func TestApplyEnvInvalidValue(t *testing.T) {
+	t.Setenv("CDK_DESTROY_DRY_RUN", "maybe")
+	if err := applyEnv(newTestFlags(t).fset); err == nil {
+		t.Error("applyEnv succeeded, want an error for an invalid bool")
+	}
+}
+
+// コマンドライン > 環境変数 > 設定ファイル > 既定値
+func TestFlagPrecedence(t *testing.T) {
+	t.Setenv("CDK_DESTROY_REGION", "eu-west-1")
+	t.Setenv("CDK_DESTROY_PROFILE", "env-profile")
+	path := filepath.Join(t.TempDir(), "cdk-destroy.yaml")
+	config := "region: ap-northeast-1\nprofile: config-profile\ncdk-app-path: bin/config.ts\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newTestFlags(t, "--region", "us-east-1")
+	if err := applyEnv(f.fset); err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+	if err := applyConfigFile(f.fset, path, true); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if f.region != "us-east-1" {
+		t.Errorf("region = %q, want the command-line value us-east-1", f.region)
+	}
+	if f.profile != "env-profile" {
+		t.Errorf("profile = %q, want the environment value env-profile", f.profile)
+	}
+	if f.appPath != "bin/config.ts" {
+		t.Errorf("cdk-app-path = %q, want the config file value bin/config.ts", f.appPath)
+	}
+}
@@ -4,208 +4,167 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"strings"
-	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
-	"github.com/aws/aws-sdk-go-v2/service/ecs"
-	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/KazuhiroTakahashiAQ/destroy-with-dependency/destroyer"
 )
 
-// コマンドライン フラグ
+// コマンドライン フラグ (値はそのまま destroyer.Config に入る)
 var (
-	stackName  = flag.String("stack", "", "CloudFormation stack name (required)")
-	profile    = flag.String("profile", "", "AWS CLI profile name (optional)")
-	cdkAppPath = flag.String("cdk-app-path", "", "Full path to the CDK app entry file, e.g. /path/to/bin/app.ts (required)")
-	cdkAppRoot = flag.String("cdk-app-root", ".", "CDK project root path (where cdk.json is). Defaults to current directory.")
+	cfg        = destroyer.DefaultConfig()
+	configPath = flag.String("config", "", "YAML or JSON file supplying defaults for any flag (keys are flag names). Defaults to "+defaultConfigFile+" if it exists")
 )
 
-func main() {
-	flag.Parse()
-
-	if *stackName == "" {
-		log.Fatal("Error: --stack を指定してください。")
-	}
-	if *cdkAppPath == "" {
-		log.Fatal("Error: --cdk-app-path を指定してください。")
-	}
-
-	ctx := context.Background()
-
-	// AWS Config をロード (profile のみ反映、region 引数は省略)
-	cfg, err := loadAWSConfig(ctx, *profile)
-	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
-	}
-
-	// ECS クラスター名の取得
-	clusterName, err := getEcsClusterNameFromStack(ctx, cfg, *stackName)
-	if err != nil {
-		log.Fatalf("Failed to get ECS cluster name: %v", err)
-	}
-	if clusterName == "" {
-		log.Printf("No ECS::Cluster in stack: %s", *stackName)
-	} else {
-		// ECSサービスを停止・削除
-		if err := deleteEcsServices(ctx, cfg, clusterName); err != nil {
-			log.Fatalf("Failed to delete ECS services: %v", err)
-		}
-		// タスクを停止
-		if err := stopRemainingTasks(ctx, cfg, clusterName); err != nil {
-			log.Fatalf("Failed to stop tasks: %v", err)
-		}
-	}
-
-	// 4. cdk destroy (--all) 実行
-	if err := runCdkDestroy(*profile, *cdkAppRoot, *cdkAppPath); err != nil {
-		log.Fatalf("Failed to run cdk destroy: %v", err)
-	}
-	log.Println("All done.")
+func init() {
+	flag.Var((*stringList)(&cfg.Stacks), "stack", "CloudFormation stack name (required unless --cluster is given). Repeat the flag or separate names with commas to process several stacks in order. Use name@region for a stack in another region than --region")
+	flag.StringVar(&cfg.Profile, "profile", cfg.Profile, "AWS CLI profile name (optional)")
+	flag.BoolVar(&cfg.SSOLogin, "sso-login", cfg.SSOLogin, "Run \"aws sso login --profile <profile>\" and retry when the SSO session has expired")
+	flag.StringVar(&cfg.Region, "region", cfg.Region, "AWS region (optional). Default for stacks without name@region; defaults to the region of the stack ARNs given to --stack, then the profile/environment, and us-east-1 only when none of them has a region")
+	flag.BoolVar(&cfg.AllowUnknownRegion, "allow-unknown-region", cfg.AllowUnknownRegion, "Accept a --region the SDK does not know (new regions, custom partitions)")
+	flag.StringVar(&cfg.CdkAppPath, "cdk-app-path", cfg.CdkAppPath, "Full path to the CDK app entry file, e.g. /path/to/bin/app.ts or app.py (required unless --cdk-app-command is set)")
+	flag.StringVar(&cfg.CdkAppCommand, "cdk-app-command", cfg.CdkAppCommand, `Full CDK app command passed to cdk --app, e.g. "python app.py". Inferred from --cdk-app-path's extension when empty`)
+	flag.StringVar(&cfg.CdkAppRoot, "cdk-app-root", cfg.CdkAppRoot, "CDK project root path (where cdk.json is). Defaults to current directory. cdk runs here and a relative --cdk-app-path is resolved from here")
+	flag.BoolVar(&cfg.Strict, "strict", cfg.Strict, "Fail instead of warning when --cdk-app-root has no cdk.json")
+	flag.StringVar(&cfg.CdkCwd, "cdk-cwd", cfg.CdkCwd, "Directory to run cdk in, if different from --cdk-app-root (the --app entry file path is made relative to it)")
+	flag.Var((*argList)(&cfg.CdkArgs), "cdk-arg", `Extra argument appended to the cdk destroy command, e.g. --cdk-arg=--exclusively or --cdk-arg "-c env=dev" (repeatable). Arguments after "--" are appended too`)
+	flag.StringVar(&cfg.CdkBin, "cdk-bin", cfg.CdkBin, `cdk command to run, e.g. "npx cdk" or /path/to/node_modules/.bin/cdk. Relative paths are resolved from --cdk-app-root`)
+	flag.DurationVar(&cfg.MaxTotalTimeout, "max-total-timeout", cfg.MaxTotalTimeout, "Overall deadline for the whole cleanup + cdk destroy run; aborts when exceeded (0 = no limit)")
+	flag.DurationVar(&cfg.CdkTimeout, "cdk-timeout", cfg.CdkTimeout, "Maximum time to wait for cdk destroy to finish before killing it (with --use-cloudformation, for each stack deletion to complete)")
+	flag.BoolVar(&cfg.UseCloudFormation, "use-cloudformation", cfg.UseCloudFormation, "Delete the --stack stacks with CloudFormation DeleteStack (in reverse --stack order, waiting for each) instead of running cdk destroy; no CDK app, node or cdk CLI is needed")
+	flag.BoolVar(&cfg.Inspect, "inspect", cfg.Inspect, "Only print the clusters, services and running tasks found for the stacks (text or JSON per --log-format) to stdout, then exit without changing anything or running cdk")
+	flag.BoolVar(&cfg.CheckPermissions, "check-permissions", cfg.CheckPermissions, "Only check that the caller has the IAM permissions the enabled options need (read-only probes + iam:SimulatePrincipalPolicy), then exit without changing anything")
+	flag.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Only report what would be deleted, without changing anything")
+	flag.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "Number of ECS services processed in parallel")
+	flag.IntVar(&cfg.ClusterConcurrency, "cluster-concurrency", cfg.ClusterConcurrency, "Number of ECS clusters drained in parallel (each still processes --concurrency services at a time). With more than 1, all clusters are attempted and failures are reported together")
+	flag.IntVar(&cfg.ParallelStacks, "parallel-stacks", cfg.ParallelStacks, "Number of stacks (in the same region) cleaned up at the same time. Use it only for stacks that do not depend on each other; clusters shared by several stacks are still drained one stack at a time. Requires --yes (or --dry-run); all stacks of the region are attempted and failures are reported together. With --use-cloudformation the stacks are also deleted in parallel")
+	flag.DurationVar(&cfg.ProgressInterval, "progress-interval", cfg.ProgressInterval, "Log progress (elapsed time, running/desired counts) at this interval while waiting for services and tasks (0 = off)")
+	flag.DurationVar(&cfg.ServiceWaitTimeout, "service-wait-timeout", cfg.ServiceWaitTimeout, "Maximum time to wait for each ECS service to become stable after scaling to 0 (a deployment circuit breaker rollback ends the wait early)")
+	flag.BoolVar(&cfg.CleanupOnly, "cleanup-only", cfg.CleanupOnly, "Only drain ECS services/tasks and skip cdk destroy")
+	flag.BoolVar(&cfg.DestroyOnly, "destroy-only", cfg.DestroyOnly, "Skip the ECS cleanup and only run cdk destroy")
+	flag.BoolVar(&cfg.SkipECS, "skip-ecs", cfg.SkipECS, "Same as --destroy-only: skip the ECS discovery and cleanup (no ListServices/ListTasks calls and no ECS permissions needed) and go straight to cdk destroy")
+	flag.BoolVar(&cfg.TasksOnly, "tasks-only", cfg.TasksOnly, "Do not touch ECS services; only stop the remaining (standalone) tasks and wait for them, then run cdk destroy. Other ECS cleanup options are ignored")
+	flag.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, `Log output format: "text" or "json"`)
+	flag.BoolVar(&cfg.NoColor, "no-color", cfg.NoColor, "Do not color log levels. Colors are only used for text logs on a terminal, and never when NO_COLOR is set")
+	flag.BoolVar(&cfg.Verbose, "verbose", cfg.Verbose, "Enable debug logs (AWS request IDs, raw ARNs)")
+	flag.BoolVar(&cfg.Quiet, "quiet", cfg.Quiet, "Only log warnings and errors")
+	flag.IntVar(&cfg.DestroyRetries, "destroy-retries", cfg.DestroyRetries, "Maximum number of cdk destroy attempts; re-runs it with a backoff delay when it exits non-zero (1 = no retry). With --use-cloudformation, DeleteStack attempts per stack")
+	flag.IntVar(&cfg.FailedEvents, "failed-events", cfg.FailedEvents, "When cdk destroy (or --use-cloudformation) fails, show up to this many of the latest DELETE_FAILED stack events with their reason (0 = off)")
+	flag.Float64Var(&cfg.QPS, "qps", cfg.QPS, "Maximum ECS API calls per second across all workers (ListServices, DescribeServices, UpdateService, DeleteService, StopTask; includes wait polling). 0 = unlimited")
+	flag.IntVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "Maximum number of retries for throttled AWS API calls")
+	flag.StringVar(&cfg.ExpectedAccountID, "expected-account-id", cfg.ExpectedAccountID, "Abort before any change unless the credentials resolve to this AWS account ID")
+	flag.StringVar(&cfg.AssumeRoleArn, "assume-role-arn", cfg.AssumeRoleArn, "IAM role ARN to assume for all AWS API calls (optional)")
+	flag.StringVar(&cfg.ExternalID, "external-id", cfg.ExternalID, "External ID used when assuming --assume-role-arn (optional)")
+	flag.StringVar(&cfg.RoleSessionName, "role-session-name", cfg.RoleSessionName, "Role session name used when assuming --assume-role-arn")
+	flag.StringVar(&cfg.EndpointURL, "endpoint-url", cfg.EndpointURL, "Custom AWS endpoint URL for all API calls, e.g. http://localhost:4566 for LocalStack (optional)")
+	flag.BoolVar(&cfg.EmptyS3Buckets, "empty-s3-buckets", cfg.EmptyS3Buckets, "Delete all objects (including versions and delete markers) from S3 buckets in the stack before destroy")
+	flag.BoolVar(&cfg.EmptyEcrRepos, "empty-ecr-repos", cfg.EmptyEcrRepos, "Delete all images from ECR repositories in the stack before destroy")
+	flag.BoolVar(&cfg.DisableTableProtection, "disable-table-protection", cfg.DisableTableProtection, "Disable deletion protection on DynamoDB tables (AWS::DynamoDB::Table) in the stack so cdk destroy can delete them")
+	flag.BoolVar(&cfg.RetainCluster, "retain-cluster", cfg.RetainCluster, "Keep a shared cluster: delete only the stack's own services (AWS::ECS::Service) and their tasks, and skip cdk destroy. The stack remains with drifted services")
+	flag.BoolVar(&cfg.PurgeQueues, "purge-queues", cfg.PurgeQueues, "Purge the messages of the SQS queues (AWS::SQS::Queue) in the stack before destroy; a queue purged within the last 60 seconds is left to finish its purge")
+	flag.BoolVar(&cfg.DeleteTopicSubscriptions, "delete-topic-subscriptions", cfg.DeleteTopicSubscriptions, "Delete all subscriptions of the SNS topics (AWS::SNS::Topic) in the stack before destroy, including ones created outside the stack (pending confirmations cannot be deleted)")
+	flag.BoolVar(&cfg.CleanupEFS, "cleanup-efs", cfg.CleanupEFS, "After draining the clusters, delete the EFS mount targets of the stack (AWS::EFS::MountTarget, and those of its AWS::EFS::FileSystem) and wait for them to go away, so cdk destroy does not fail on them")
+	flag.BoolVar(&cfg.DeleteLogGroups, "delete-log-groups", cfg.DeleteLogGroups, "Delete CloudWatch Logs log groups (AWS::Logs::LogGroup) in the stack before destroy")
+	flag.StringVar(&cfg.StopReason, "stop-reason", cfg.StopReason, "Reason recorded on every StopTask call (default \"Cleanup before destroy (stack: <name>)\", truncated to 255 characters)")
+	flag.DurationVar(&cfg.TaskWaitTimeout, "task-wait-timeout", cfg.TaskWaitTimeout, "Maximum time to wait for stopped ECS tasks to reach STOPPED")
+	flag.BoolVar(&cfg.ForceStop, "force-stop", cfg.ForceStop, "Call StopTask again for tasks still running after --task-wait-timeout and wait once more (tasks that still do not stop are reported as failures)")
+	flag.BoolVar(&cfg.DeregisterTaskDefs, "deregister-task-defs", cfg.DeregisterTaskDefs, "Deregister all ACTIVE revisions of the task definition families used by the deleted services")
+	flag.BoolVar(&cfg.ScaleDownASG, "scale-down-asg", cfg.ScaleDownASG, "Scale the Auto Scaling Groups of the cluster's EC2 capacity providers to 0 and wait for container instances to deregister")
+	flag.BoolVar(&cfg.StepDown, "step-down", cfg.StepDown, "Reduce each service's desired count gradually (waiting for stability between steps) instead of jumping straight to 0")
+	flag.IntVar(&cfg.StepDownSize, "step-down-size", cfg.StepDownSize, "Tasks to remove per step with --step-down")
+	flag.DurationVar(&cfg.StepDownDelay, "step-down-delay", cfg.StepDownDelay, "Delay between steps with --step-down")
+	flag.BoolVar(&cfg.CleanupENIs, "cleanup-enis", cfg.CleanupENIs, "After stopping tasks, delete available ENIs tagged with the cluster (aws:ecs:clusterName) that would block security group deletion")
+	flag.BoolVar(&cfg.DisableScheduledTasks, "disable-scheduled-tasks", cfg.DisableScheduledTasks, "Disable EventBridge rules that start ECS tasks in the cluster before draining it (re-enabled if the cleanup fails)")
+	flag.BoolVar(&cfg.DrainTargets, "drain-targets", cfg.DrainTargets, "Deregister the services' load balancer targets and wait for draining (up to --service-wait-timeout) before deleting them")
+	flag.BoolVar(&cfg.RemoveAutoscaling, "remove-autoscaling", cfg.RemoveAutoscaling, "Deregister each service's Application Auto Scaling scalable target before setting its desired count to 0, so scaling policies cannot scale it back up")
+	flag.BoolVar(&cfg.ContinueOnError, "continue-on-error", cfg.ContinueOnError, "Keep going after a cleanup failure (remaining steps, clusters and stacks) and run cdk destroy anyway; all failures are reported at the end. By default the first failure stops the cleanup and cdk destroy is skipped")
+	flag.BoolVar(&cfg.WaitForStableStack, "wait-for-stable-stack", cfg.WaitForStableStack, "Before cleaning up a stack that is in progress (e.g. UPDATE_IN_PROGRESS during a deploy), poll it until it reaches a *_COMPLETE or *_FAILED state (up to --stack-wait-timeout). A stack in UPDATE_ROLLBACK_FAILED cannot be deleted and is an error")
+	flag.DurationVar(&cfg.StackWaitTimeout, "stack-wait-timeout", cfg.StackWaitTimeout, "Maximum time to wait for a stack with --wait-for-stable-stack")
+	flag.DurationVar(&cfg.InstanceWaitTimeout, "instance-wait-timeout", cfg.InstanceWaitTimeout, "Maximum time to wait for container instances to deregister after --scale-down-asg")
+	flag.Var((*stringList)(&cfg.Clusters), "cluster", "ECS cluster name to drain directly, skipping discovery from the stack. Repeat the flag or separate names with commas")
+	flag.BoolVar(&cfg.DeleteCluster, "delete-cluster", cfg.DeleteCluster, "After draining the --cluster clusters (standalone clusters that cdk does not own), delete them with DeleteCluster. If services or tasks appeared in the meantime, the cluster is drained again and the deletion retried")
+	flag.Var((*stringList)(&cfg.CrossAccountBuckets), "cross-account-bucket", "S3 bucket in another account to empty before cdk destroy, as <bucket>=<profile or role ARN> (see Cross-account resources). Repeat the flag or separate entries with commas")
+	flag.Var((*stringList)(&cfg.CrossAccountRepos), "cross-account-repo", "ECR repository in another account to empty before cdk destroy, as <repository>=<profile or role ARN> (see Cross-account resources). Repeat the flag or separate entries with commas")
+	flag.StringVar(&cfg.Output, "output", cfg.Output, "Write a pretty-printed JSON result file (stacks, clusters, services, stopped tasks, cdk exit code, timestamps) to this path, even when the run fails")
+	flag.StringVar(&cfg.PlanOut, "plan-out", cfg.PlanOut, "Discover the resources and write a plan (JSON, or YAML for .yaml/.yml) of what would be drained and destroyed to this path, without changing anything")
+	flag.StringVar(&cfg.Notify, "notify", cfg.Notify, "Send a summary (stacks, result, duration, failures) on success and failure to this SNS topic ARN or Slack webhook URL (best effort; never changes the exit code)")
+	flag.StringVar(&cfg.PlanIn, "plan-in", cfg.PlanIn, "Apply a plan written by --plan-out: only the resources in the plan that still exist are touched, using the plan's options, stacks, account and region")
+	flag.Var((*stringList)(&cfg.Services), "service", "ECS service name or ARN to drain; only these services and their tasks are touched (repeatable or comma-separated)")
+	flag.StringVar(&cfg.ServiceInclude, "service-include", cfg.ServiceInclude, "Only delete services whose name matches this regular expression (other services and their tasks are left untouched)")
+	flag.StringVar(&cfg.ServiceExclude, "service-exclude", cfg.ServiceExclude, "Do not delete services whose name matches this regular expression (their tasks are left untouched)")
+	flag.StringVar(&cfg.TasksStartedAfter, "tasks-started-after", cfg.TasksStartedAfter, "Only stop tasks started after this time: an RFC3339 time (2024-05-01T09:00:00Z) or a duration before now (2h). Older tasks, for example long-running tasks of other teams in a shared cluster, are left running. Applies to the tasks stopped directly (not the tasks of deleted services) and together with --service-include/--service-exclude")
+	flag.StringVar(&cfg.ClusterTag, "cluster-tag", cfg.ClusterTag, "Find ECS clusters by this tag (key=value) when the stack has no AWS::ECS::Cluster resource")
+	flag.Var((*stringList)(&cfg.ClusterLogicalIDs), "cluster-logical-id", "Logical ID of a resource (in the stack or its nested stacks) whose physical ID is the ECS cluster name or ARN, used when the stack has no AWS::ECS::Cluster resource (repeatable or comma-separated)")
+	flag.BoolVar(&cfg.RequireStack, "require-stack", cfg.RequireStack, "Fail when the stack does not exist (by default a missing stack is treated as already deleted)")
+	flag.BoolVar(&cfg.DisableTerminationProtection, "disable-termination-protection", cfg.DisableTerminationProtection, "Disable CloudFormation termination protection on the stack before cdk destroy (otherwise a protected stack is an error)")
+	flag.IntVar(&cfg.MaxStackDepth, "max-stack-depth", cfg.MaxStackDepth, "Maximum depth of nested stacks (AWS::CloudFormation::Stack) to descend into. 0 disables nested stack discovery")
+	flag.BoolVar(&cfg.Yes, "yes", cfg.Yes, "Skip the interactive confirmation prompt (for CI)")
+	flag.BoolVar(&cfg.Force, "force", cfg.Force, "Same as --yes, and also destroy stacks updated more recently than --min-stack-age")
+	flag.DurationVar(&cfg.MinStackAge, "min-stack-age", cfg.MinStackAge, "Refuse to touch a stack whose LastUpdatedTime is more recent than this (e.g. 1h), in case someone just deployed it; --force overrides. 0 = no check")
 }
 
-// AWS Config ロード (profile のみ考慮)
-func loadAWSConfig(ctx context.Context, profile string) (aws.Config, error) {
-	opts := []func(*config.LoadOptions) error{}
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	return config.LoadDefaultConfig(ctx, opts...)
-}
+// 繰り返し指定・カンマ区切りで複数の値を受け取るフラグ
+type stringList []string
 
-// CloudFormation から ECS Cluster名を取得
-func getEcsClusterNameFromStack(ctx context.Context, cfg aws.Config, stackName string) (string, error) {
-	cfnClient := cfn.NewFromConfig(cfg)
-	res, err := cfnClient.ListStackResources(ctx, &cfn.ListStackResourcesInput{
-		StackName: &stackName,
-	})
-	if err != nil {
-		return "", err
-	}
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	for _, r := range res.StackResourceSummaries {
-		if r.ResourceType != nil && *r.ResourceType == "AWS::ECS::Cluster" {
-			return *r.PhysicalResourceId, nil
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
 		}
 	}
-	return "", nil
+	return nil
 }
 
-// ECSサービスを停止（DesiredCount=0）→ 削除
-func deleteEcsServices(ctx context.Context, cfg aws.Config, clusterName string) error {
-	ecsClient := ecs.NewFromConfig(cfg)
-
-	listOut, err := ecsClient.ListServices(ctx, &ecs.ListServicesInput{
-		Cluster: &clusterName,
-	})
-	if err != nil {
-		return fmt.Errorf("ListServices error: %w", err)
-	}
-
-	if len(listOut.ServiceArns) == 0 {
-		log.Printf("No ECS services found in cluster: %s", clusterName)
-		return nil
-	}
-
-	for _, svcArn := range listOut.ServiceArns {
-		svcName := arnToName(svcArn)
-		log.Printf("[Service: %s] Setting desired count to 0...", svcName)
+// 繰り返し指定で複数の値を受け取るフラグ (値にカンマを含められるよう分割しない)
+type argList []string
 
-		_, err := ecsClient.UpdateService(ctx, &ecs.UpdateServiceInput{
-			Cluster:      &clusterName,
-			Service:      &svcName,
-			DesiredCount: aws.Int32(0),
-		})
-		if err != nil {
-			log.Printf("Failed to update service(%s) desiredCount=0: %v", svcName, err)
-			continue
-		}
-
-		if err := waitForServiceStable(ctx, ecsClient, clusterName, svcName); err != nil {
-			log.Printf("waitForServiceStable failed for service(%s): %v", svcName, err)
-		}
+func (l *argList) String() string {
+	return strings.Join(*l, " ")
+}
 
-		log.Printf("[Service: %s] Deleting...", svcName)
-		_, err = ecsClient.DeleteService(ctx, &ecs.DeleteServiceInput{
-			Cluster: &clusterName,
-			Service: &svcName,
-			Force:   aws.Bool(true),
-		})
-		if err != nil {
-			log.Printf("Failed to delete service(%s): %v", svcName, err)
-		}
-	}
+func (l *argList) Set(value string) error {
+	*l = append(*l, value)
 	return nil
 }
 
-// クラスターに残っているタスクを停止
-func stopRemainingTasks(ctx context.Context, cfg aws.Config, clusterName string) error {
-	ecsClient := ecs.NewFromConfig(cfg)
-
-	listOut, err := ecsClient.ListTasks(ctx, &ecs.ListTasksInput{
-		Cluster:       &clusterName,
-		DesiredStatus: ecstypes.DesiredStatusRunning,
-	})
-	if err != nil {
-		return fmt.Errorf("ListTasks error: %w", err)
-	}
-	if len(listOut.TaskArns) == 0 {
-		log.Printf("No running tasks in cluster: %s", clusterName)
-		return nil
-	}
+func main() {
+	flag.Usage = usage
+	flag.Parse()
 
-	for _, taskArn := range listOut.TaskArns {
-		taskName := arnToName(taskArn)
-		log.Printf("[Task: %s] Stopping...", taskName)
-		_, err := ecsClient.StopTask(ctx, &ecs.StopTaskInput{
-			Cluster: &clusterName,
-			Task:    &taskArn,
-			Reason:  aws.String("Cleanup before destroy"),
-		})
-		if err != nil {
-			log.Printf("Failed to stop task(%s): %v", taskName, err)
+	// "--" より後ろの引数は cdk destroy にそのまま渡す
+	if flag.NArg() > 0 {
+		if os.Args[len(os.Args)-flag.NArg()-1] != "--" {
+			fmt.Fprintf(os.Stderr, "Error: unexpected argument %q (pass arguments for cdk after \"--\" or with --cdk-arg)\n", flag.Arg(0))
+			os.Exit(destroyer.ExitInvalidFlags)
 		}
 	}
-	return nil
-}
 
-// コマンド実行
-func runCdkDestroy(profile, cdkAppRoot, cdkAppPath string) error {
-	args := []string{"destroy", "--all", "--force"}
-	if profile != "" {
-		args = append(args, "--profile", profile)
+	// 環境変数・設定ファイルの値はコマンドラインで指定していないフラグにだけ反映する
+	err := applyEnv(flag.CommandLine)
+	switch {
+	case err != nil:
+	case *configPath != "":
+		err = applyConfigFile(flag.CommandLine, *configPath, true)
+	default:
+		err = applyConfigFile(flag.CommandLine, defaultConfigFile, false)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(destroyer.ExitInvalidFlags)
+	}
+	cfg.CdkArgs = append(cfg.CdkArgs, flag.Args()...)
 
-	// --app 引数
-	appArg := fmt.Sprintf("npx ts-node %s", cdkAppPath)
-	args = append(args, "--app", appArg)
-
-	log.Printf("Executing: cdk %s", strings.Join(args, " "))
-
-	cmd := exec.Command("cdk", args...)
-	cmd.Dir = cdkAppRoot
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-// ARN末尾からリソース名を取り出す
-func arnToName(arn string) string {
-	parts := strings.Split(arn, "/")
-	return parts[len(parts)-1]
-}
+	// Ctrl+C / SIGTERM で AWS 呼び出し・待機・cdk を中断する
+	ctx, stop := newSignalContext(context.Background())
+	defer stop()
 
-// サービスが STABLE になるまで待機
-func waitForServiceStable(ctx context.Context, ecsClient *ecs.Client, clusterName, serviceName string) error {
-	svcWaiter := ecs.NewServicesStableWaiter(ecsClient)
-	input := &ecs.DescribeServicesInput{
-		Cluster:  &clusterName,
-		Services: []string{serviceName},
+	// エラーは destroyer.Run がログに出力する
+	if err := destroyer.Run(ctx, cfg); err != nil {
+		stop()
+		os.Exit(destroyer.ExitCode(err))
 	}
-	maxWait := 10 * time.Minute
-	return svcWaiter.Wait(ctx, input, maxWait)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// --help の出力 (終了コードの説明付き)
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s --stack <name> [--cluster <name>] --cdk-app-path <path> [flags] [-- <cdk destroy args>]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, `
+Environment variables:
+  Any flag can be given as `+envPrefix+`<FLAG_NAME> (e.g. `+envName("stack")+`, `+envName("cdk-app-path")+`).
+
+Config file:
+  Any flag can be given in a YAML/JSON file (--config, or ./`+defaultConfigFile+`).
+  Keys are flag names. Stack entries may be {name: <stack>, region: <region>} (same as --stack <stack>@<region>).
+
+Error handling:
+  By default the first cleanup failure stops the run and cdk destroy is skipped.
+  With --continue-on-error the following keep going after a failure, and cdk destroy runs anyway:
+    - the remaining stacks (stacks of a region cleaned up with --parallel-stacks always all run)
+    - the remaining clusters of a stack (clusters drained in parallel always all run)
+    - the remaining steps of a cluster (task stop, ASG scale-down, ENI cleanup, task definitions)
+    - the remaining cleanups of a stack (EFS, S3, ECR, log groups, DynamoDB)
+  Not affected: services of a cluster (always all attempted), stack discovery and
+  termination protection errors, interruption and the confirmation prompt.
+  All failures are listed in the summary either way.
+
+Cluster discovery:
+  With --cluster, only those clusters are drained and the stacks are not searched.
+  Otherwise the clusters of each --stack are found in this order; a later step is only
+  used when the earlier ones find nothing:
+    1. AWS::ECS::Cluster resources of the stack and its nested stacks (up to --max-stack-depth)
+    2. --cluster-logical-id: resources with these logical IDs in the stack or its nested stacks,
+       for constructs that create the cluster under another resource type (e.g. a custom
+       resource); their physical ID is taken as the cluster name or ARN
+    3. --cluster-tag: clusters in the account and region that have this tag
+
+Conflicting flags:
+  Flags that contradict each other are rejected before anything runs (exit code 4) instead of
+  one of them silently taking effect, and every conflicting pair found is listed. For example:
+    --verbose with --quiet, --cleanup-only / --tasks-only with --destroy-only,
+    --plan-out with --plan-in, --plan-in with --stack, --inspect with --destroy-only,
+    --retain-cluster with --tasks-only, --use-cloudformation with --cdk-arg.
+  $ `+os.Args[0]+` --stack app --inspect --destroy-only --verbose --quiet
+  Error: 同時に指定できないフラグがあります:
+    --verbose と --quiet
+    --inspect と --destroy-only (--inspect は何も変更しません)
+
+Cross-account resources:
+  The stacks, their clusters and their own resources always use the primary credentials
+  (--profile, then --assume-role-arn). Buckets and repositories given with
+  --cross-account-bucket / --cross-account-repo use the credentials after "=":
+    - a profile name: that profile of the shared config, on its own (--assume-role-arn is not applied)
+    - a role ARN: the role is assumed with the --profile credentials (without --external-id)
+  The --region of the run and --endpoint-url are used for both. Entries with the same
+  credentials share one session. They are emptied after the stacks are cleaned up and
+  before cdk destroy, with their own confirmation prompt.
+
+Tracing:
+  When OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set, a trace of the run
+  is sent over OTLP/HTTP: a root span with child spans for each stack, its discovery, each cluster
+  drain, service drain and task stop, and cdk destroy. The other OTEL_* variables (headers,
+  OTEL_SERVICE_NAME, ...) apply as usual. Without them nothing is recorded.
+
+Precedence: command line > environment variables > config file > defaults.
+
+Exit codes:
+  0  success
+  1  other failure (e.g. aborted at the confirmation prompt)
+  2  AWS cleanup failed (stack/cluster discovery, ECS/S3/ECR cleanup)
+  3  cdk destroy failed
+  4  invalid flags`)
+}